@@ -0,0 +1,264 @@
+import argparse
+from typing import Dict, Any, List, Optional
+
+COMPLETION_SHELLS = ["bash", "zsh", "fish", "powershell"]
+
+# Options whose value is a path, completed from the filesystem unless they have choices
+PATH_OPTIONS = {"--config", "--file", "--summary-file", "--output", "--output-dir", "--probe-set-file"}
+
+# Names the scripts register for: the installed entry point and the script itself
+PROGRAM_NAMES = ["sintra", "sintra.py"]
+
+
+def _option_spec(action: argparse.Action) -> Optional[Dict[str, Any]]:
+    long_options = [opt for opt in action.option_strings if opt.startswith("--")]
+    if not long_options or isinstance(action, argparse._HelpAction):
+        return None
+    option = long_options[0]
+    return {
+        "option": option,
+        "help": (action.help or "").replace("%%", "%"),
+        "takes_value": action.nargs != 0,
+        "choices": [str(choice) for choice in action.choices] if action.choices else [],
+        "path": option in PATH_OPTIONS
+    }
+
+
+def completion_spec(parser: argparse.ArgumentParser) -> Dict[str, Any]:
+    """Describe the parser's global options, commands and their options.
+
+    Value lists come from each option's argparse `choices`, which are the same
+    constants the handlers validate against, so completions cannot drift.
+    """
+    spec: Dict[str, Any] = {"options": [], "commands": {}}
+    for action in parser._actions:
+        if isinstance(action, argparse._SubParsersAction):
+            helps = {choice.dest: choice.help for choice in action._choices_actions}
+            for name, subparser in action.choices.items():
+                positional_choices = [
+                    str(choice) for a in subparser._actions
+                    if not a.option_strings and a.choices for choice in a.choices
+                ]
+                spec["commands"][name] = {
+                    "help": helps.get(name) or "",
+                    "options": [o for o in map(_option_spec, subparser._actions) if o],
+                    "arguments": positional_choices
+                }
+        else:
+            option = _option_spec(action)
+            if option:
+                spec["options"].append(option)
+    return spec
+
+
+def _words(values: List[str]) -> str:
+    return " ".join(values)
+
+
+def _bash_value_cases(options: List[Dict[str, Any]], indent: str) -> List[str]:
+    lines = []
+    for opt in options:
+        if opt["choices"]:
+            lines.append(f'{indent}{opt["option"]}) COMPREPLY=($(compgen -W "{_words(opt["choices"])}" -- "$cur")); return ;;')
+        elif opt["path"]:
+            lines.append(f'{indent}{opt["option"]}) COMPREPLY=($(compgen -f -- "$cur")); return ;;')
+        elif opt["takes_value"]:
+            lines.append(f'{indent}{opt["option"]}) return ;;')
+    return lines
+
+
+def bash_script(spec: Dict[str, Any]) -> str:
+    commands = list(spec["commands"])
+    global_words = [o["option"] for o in spec["options"]] + commands
+    lines = [
+        "# bash completion for sintra",
+        "_sintra_completion() {",
+        "    local cur prev cmd i",
+        '    cur="${COMP_WORDS[COMP_CWORD]}"',
+        '    prev="${COMP_WORDS[COMP_CWORD-1]}"',
+        '    cmd=""',
+        "    for ((i=1; i<COMP_CWORD; i++)); do",
+        '        case "${COMP_WORDS[i]}" in',
+        f'            {"|".join(commands)}) cmd="${{COMP_WORDS[i]}}"; break ;;',
+        "        esac",
+        "    done",
+        "",
+        '    case "$cmd" in',
+        '        "")',
+        '            case "$prev" in',
+        *_bash_value_cases(spec["options"], "                "),
+        "            esac",
+        f'            COMPREPLY=($(compgen -W "{_words(global_words)}" -- "$cur"))',
+        "            ;;",
+    ]
+    for name, command in spec["commands"].items():
+        lines += [
+            f"        {name})",
+            '            case "$prev" in',
+            *_bash_value_cases(command["options"], "                "),
+            "            esac",
+            f'            COMPREPLY=($(compgen -W "{_words(command["arguments"] + [o["option"] for o in command["options"]])}" -- "$cur"))',
+            "            ;;",
+        ]
+    lines += [
+        "    esac",
+        "}",
+        f"complete -F _sintra_completion {_words(PROGRAM_NAMES)}",
+        ""
+    ]
+    return "\n".join(lines)
+
+
+def _zsh_escape(text: str) -> str:
+    return (text.replace("\\", "\\\\").replace("'", "'\\''")
+            .replace("[", "\\[").replace("]", "\\]"))
+
+
+def _zsh_option(opt: Dict[str, Any]) -> str:
+    spec = f'{opt["option"]}[{_zsh_escape(opt["help"])}]'
+    if opt["choices"]:
+        spec += f':value:({_words(opt["choices"])})'
+    elif opt["path"]:
+        spec += ":file:_files"
+    elif opt["takes_value"]:
+        spec += ":value:"
+    return f"'{spec}'"
+
+
+def zsh_script(spec: Dict[str, Any]) -> str:
+    lines = [
+        f"#compdef {_words(PROGRAM_NAMES)}",
+        "",
+        "_sintra() {",
+        "    local line state",
+        "    _arguments -C \\",
+        *[f"        {_zsh_option(o)} \\" for o in spec["options"]],
+        "        '1: :->command' \\",
+        "        '*:: :->args'",
+        "",
+        "    case $state in",
+        "        command)",
+        "            local -a commands",
+        "            commands=(",
+        *[f"                '{name}:{_zsh_escape(c['help'])}'" for name, c in spec["commands"].items()],
+        "            )",
+        "            _describe 'command' commands",
+        "            ;;",
+        "        args)",
+        "            case $line[1] in",
+    ]
+    for name, command in spec["commands"].items():
+        options = [_zsh_option(o) for o in command["options"]]
+        if command["arguments"]:
+            options.append(f"'1:argument:({_words(command['arguments'])})'")
+        lines.append(f"                {name})")
+        if options:
+            lines.append("                    _arguments \\")
+            lines += [f"                        {o} \\" for o in options[:-1]]
+            lines.append(f"                        {options[-1]}")
+        lines.append("                    ;;")
+    lines += [
+        "            esac",
+        "            ;;",
+        "    esac",
+        "}",
+        "",
+        '_sintra "$@"',
+        ""
+    ]
+    return "\n".join(lines)
+
+
+def _fish_quote(text: str) -> str:
+    return "'" + text.replace("\\", "\\\\").replace("'", "\\'") + "'"
+
+
+def _fish_option(program: str, condition: str, opt: Dict[str, Any]) -> str:
+    line = f"complete -c {program} -n {_fish_quote(condition)} -l {opt['option'][2:]}"
+    if opt["choices"]:
+        line += f" -x -a {_fish_quote(_words(opt['choices']))}"
+    elif opt["path"]:
+        line += " -r -F"
+    elif opt["takes_value"]:
+        line += " -x"
+    return line + f" -d {_fish_quote(opt['help'])}"
+
+
+def fish_script(spec: Dict[str, Any]) -> str:
+    lines = ["# fish completion for sintra"]
+    for program in PROGRAM_NAMES:
+        lines.append(f"complete -c {program} -f")
+        for opt in spec["options"]:
+            lines.append(_fish_option(program, "__fish_use_subcommand", opt))
+        for name, command in spec["commands"].items():
+            lines.append(
+                f"complete -c {program} -n __fish_use_subcommand -a {name} -d {_fish_quote(command['help'])}"
+            )
+            condition = _fish_quote(f"__fish_seen_subcommand_from {name}")
+            if command["arguments"]:
+                lines.append(f"complete -c {program} -n {condition} -a {_fish_quote(_words(command['arguments']))}")
+            for opt in command["options"]:
+                lines.append(_fish_option(program, f"__fish_seen_subcommand_from {name}", opt))
+    lines.append("")
+    return "\n".join(lines)
+
+
+def _ps_array(values) -> str:
+    return "@(" + ", ".join("'" + v.replace("'", "''") + "'" for v in values) + ")"
+
+
+def powershell_script(spec: Dict[str, Any]) -> str:
+    values = {o["option"]: o["choices"] for o in spec["options"] if o["choices"]}
+    for name, command in spec["commands"].items():
+        for opt in command["options"]:
+            if opt["choices"]:
+                values[f"{name} {opt['option']}"] = opt["choices"]
+
+    lines = [
+        "# PowerShell completion for sintra",
+        f"Register-ArgumentCompleter -Native -CommandName {_ps_array(PROGRAM_NAMES)} -ScriptBlock {{",
+        "    param($wordToComplete, $commandAst, $cursorPosition)",
+        "",
+        f"    $globalOptions = {_ps_array(o['option'] for o in spec['options'])}",
+        "    $commands = @{",
+        *[f"        '{name}' = {_ps_array(c['arguments'] + [o['option'] for o in c['options']])}"
+          for name, c in spec["commands"].items()],
+        "    }",
+        "    $values = @{",
+        *[f"        '{key}' = {_ps_array(choices)}" for key, choices in values.items()],
+        "    }",
+        "",
+        "    $elements = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })",
+        "    if ($wordToComplete -ne '' -and $elements.Count -gt 0) {",
+        "        $elements = @($elements | Select-Object -First ($elements.Count - 1))",
+        "    }",
+        "    $command = $elements | Where-Object { $commands.ContainsKey($_) } | Select-Object -First 1",
+        "    $previous = if ($elements.Count -gt 0) { $elements[-1] } else { '' }",
+        "",
+        "    if ($command -and $values.ContainsKey(\"$command $previous\")) { $candidates = $values[\"$command $previous\"] }",
+        "    elseif (-not $command -and $values.ContainsKey($previous)) { $candidates = $values[$previous] }",
+        "    elseif ($command) { $candidates = $commands[$command] }",
+        "    else { $candidates = $globalOptions + @($commands.Keys) }",
+        "",
+        "    $candidates | Where-Object { $_ -like \"$wordToComplete*\" } | ForEach-Object {",
+        "        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)",
+        "    }",
+        "}",
+        ""
+    ]
+    return "\n".join(lines)
+
+
+COMPLETION_GENERATORS = {
+    "bash": bash_script,
+    "zsh": zsh_script,
+    "fish": fish_script,
+    "powershell": powershell_script
+}
+
+
+def completion_script(parser: argparse.ArgumentParser, shell: str) -> str:
+    """Generate the completion script for a shell from the CLI parser."""
+    if shell not in COMPLETION_GENERATORS:
+        raise ValueError(f"Unsupported shell '{shell}'. Must be one of: {', '.join(COMPLETION_SHELLS)}")
+    return COMPLETION_GENERATORS[shell](completion_spec(parser))
@@ -4,6 +4,11 @@ ANOMALY_TYPES = {
         "measurement_type": ["ping"],
         "latency_related": True
     },
+    "high_tail_latency": {
+        "description": "A high percentile (e.g., P95) of recent RTTs exceeds a threshold even if the average does not",
+        "measurement_type": ["ping"],
+        "latency_related": True
+    },
     "packet_loss": {
         "description": "% of lost packets > threshold (e.g., 5-10%)",
         "measurement_type": ["ping"],
@@ -48,5 +53,10 @@ ANOMALY_TYPES = {
         "description": "Latency spike correlated with a route change on the same probe (probable routing cause)",
         "measurement_type": ["ping", "traceroute"],
         "latency_related": True
+    },
+    "certificate_expiry": {
+        "description": "TLS certificate seen by a probe expires within a threshold (e.g., 14 days) or has expired",
+        "measurement_type": ["sslcert"],
+        "latency_related": False
     }
 }
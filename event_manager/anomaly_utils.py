@@ -18,3 +18,15 @@ def geo_anomaly_check(dist1, lat1, dist2, lat2, margin=50):
     if dist1 > dist2 and lat1 < lat2 - margin:
         return True
     return False
+
+def percentile(values, pct):
+    """Linearly interpolated percentile (0-100) of the non-None values, or None if there are none."""
+    if not 0 <= pct <= 100:
+        raise ValueError(f"Percentile must be between 0 and 100, got {pct}")
+    valid = sorted(v for v in values if v is not None)
+    if not valid:
+        return None
+    rank = (len(valid) - 1) * pct / 100
+    lower = int(rank)
+    upper = min(lower + 1, len(valid) - 1)
+    return valid[lower] + (valid[upper] - valid[lower]) * (rank - lower)
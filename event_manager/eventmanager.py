@@ -9,7 +9,7 @@ from typing import Dict, List, Any, Optional, Tuple
 from urllib.parse import urlparse
 from measurement_client.logger import logger
 from .anomaly_types import ANOMALY_TYPES
-from .anomaly_utils import calculate_jitter, is_outlier, geo_anomaly_check
+from .anomaly_utils import calculate_jitter, is_outlier, geo_anomaly_check, percentile
 
 
 class SintraEventManager:
@@ -60,7 +60,12 @@ class SintraEventManager:
                 "jitter_spike_ms": 15.0,
                 "outlier_factor": 2.0,
                 "geo_anomaly_margin_ms": 50.0,
-                "path_flapping_window": 3
+                "path_flapping_window": 3,
+                "tail_latency_percentile": 95.0,
+                "tail_latency_ms": 300.0,
+                "tail_latency_window": 100,
+                "tail_latency_min_samples": 10,
+                "certificate_expiry_days": 14.0
             },
             "detection": {
                 "enable_outlier_detection": True,
@@ -165,6 +170,8 @@ class SintraEventManager:
             'distances': {},
             'losses': {},
             'jitters': {},
+            'tail_latencies': {},
+            'cert_expiry_days': {},
             'targets': {},
             'traceroute_hops': {},
             'baseline_rtts': {},
@@ -188,6 +195,8 @@ class SintraEventManager:
                 self._process_ping_data(result, probe_id, target_addr, probe_data)
             elif measurement_type == "traceroute":
                 self._process_traceroute_data(result, probe_id, target_addr, probe_data)
+            elif measurement_type == "sslcert":
+                probe_data['cert_expiry_days'][probe_id] = result.get("days_to_expiry")
                 
         return probe_data
 
@@ -201,12 +210,26 @@ class SintraEventManager:
         probe_data['latencies'][probe_id] = latency
         probe_data['losses'][probe_id] = loss
         probe_data['jitters'][probe_id] = calculate_jitter(rtts)
+        probe_data['tail_latencies'][probe_id] = self._tail_latency(rtts)
         probe_data['distances'][probe_id] = result.get("distance_km")
         
         if self.config["detection"]["enable_adaptive_baseline"]:
             baseline_rtt = self._get_and_update_baseline_rtt(probe_id, target_addr, latency)
             probe_data['baseline_rtts'][probe_id] = baseline_rtt
 
+    def _tail_latency(self, rtts: List[float]) -> Optional[float]:
+        """Configured percentile of the probe's most recent RTT samples.
+
+        Only the last tail_latency_window samples count, and None is returned
+        below tail_latency_min_samples since a percentile of a handful of
+        samples says little about the tail.
+        """
+        thresholds = self.config["thresholds"]
+        window = [rtt for rtt in rtts if rtt is not None][-int(thresholds["tail_latency_window"]):]
+        if len(window) < thresholds["tail_latency_min_samples"]:
+            return None
+        return percentile(window, thresholds["tail_latency_percentile"])
+
     def _process_traceroute_data(self, result: Dict[str, Any], probe_id: str,
                                 target_addr: str, probe_data: Dict[str, Any]) -> None:
         hops = result.get("hops", [])
@@ -410,6 +433,16 @@ class SintraEventManager:
                 if spike_event:
                     events.append(spike_event)
             
+            # Tail latency detection (percentile of recent RTTs, catches spikes the average hides)
+            tail_latency = probe_data['tail_latencies'].get(probe_id)
+            tail_threshold = target_config.get("tail_latency_ms", thresholds["tail_latency_ms"])
+            if tail_latency is not None and tail_latency > tail_threshold:
+                events.append(self._create_event(
+                    timestamp, "high_tail_latency", probe_id, target_addr,
+                    f"ping_rtt_p{thresholds['tail_latency_percentile']:g}_ms", tail_latency, tail_threshold,
+                    "ms", "warning"
+                ))
+
             # Packet loss detection
             loss = probe_data['losses'].get(probe_id)
             if loss is not None and loss > thresholds["packet_loss_percentage"]:
@@ -434,6 +467,18 @@ class SintraEventManager:
                     "ping_jitter_ms", jitter, thresholds["jitter_spike_ms"],
                     "ms", "warning"
                 ))
+
+            # Certificate expiry detection (sslcert); already expired is critical
+            days_to_expiry = probe_data['cert_expiry_days'].get(probe_id)
+            expiry_threshold = target_config.get(
+                "certificate_expiry_days", thresholds["certificate_expiry_days"]
+            )
+            if days_to_expiry is not None and days_to_expiry < expiry_threshold:
+                events.append(self._create_event(
+                    timestamp, "certificate_expiry", probe_id, target_addr,
+                    "certificate_days_to_expiry", days_to_expiry, expiry_threshold,
+                    "days", "critical" if days_to_expiry <= 0 else "warning"
+                ))
         
         return events
 
@@ -0,0 +1,228 @@
+import bisect
+import threading
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+from typing import Any, Dict, Iterable, List, Optional, Tuple, Union
+from .processors import summarize_result
+
+# Upper bounds, in seconds, of the request duration histogram buckets
+DURATION_BUCKETS = [0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0, 60.0]
+
+# Status class of requests that never got a response (timeouts, refused connections, ...)
+NETWORK_ERROR = "network"
+
+# Upper bounds, in milliseconds, of the measured RTT histogram buckets
+RTT_BUCKETS = [5.0, 10.0, 25.0, 50.0, 100.0, 250.0, 500.0, 1000.0, 2500.0]
+
+# Port the prometheus.yml scrape config expects the exporter on
+DEFAULT_METRICS_PORT = 8000
+
+# Content types of the classic Prometheus text format and of OpenMetrics (needed for exemplars)
+PROMETHEUS_CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+OPENMETRICS_CONTENT_TYPE = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+
+def status_class(status_code: Optional[int]) -> str:
+    """Map an HTTP status code to its class label ("2xx", "4xx", ...), or "network" without one."""
+    if status_code is None:
+        return NETWORK_ERROR
+    return f"{status_code // 100}xx"
+
+
+class ApiMetrics:
+    """Counters describing Sintra's own RIPE Atlas API traffic.
+
+    Every attempt counts as a request, labelled by status class, and feeds
+    the duration histogram; retries counts attempts repeated after a 429,
+    5xx or network failure, and errors counts requests that finally failed.
+    Safe to update from several threads.
+    """
+
+    def __init__(self):
+        self._lock = threading.Lock()
+        self.requests: Dict[str, int] = {}
+        self.errors: Dict[str, int] = {}
+        self.retries = 0
+        self.duration_counts: List[int] = [0] * len(DURATION_BUCKETS)
+        self.duration_sum = 0.0
+        self.duration_count = 0
+
+    def observe_request(self, status_code: Optional[int], duration: float) -> None:
+        with self._lock:
+            label = status_class(status_code)
+            self.requests[label] = self.requests.get(label, 0) + 1
+            self.duration_sum += duration
+            self.duration_count += 1
+            bucket = bisect.bisect_left(DURATION_BUCKETS, duration)
+            if bucket < len(DURATION_BUCKETS):
+                self.duration_counts[bucket] += 1
+
+    def observe_retry(self) -> None:
+        with self._lock:
+            self.retries += 1
+
+    def observe_error(self, status_code: Optional[int]) -> None:
+        with self._lock:
+            label = status_class(status_code)
+            self.errors[label] = self.errors.get(label, 0) + 1
+
+    def render(self, openmetrics: bool = False) -> str:
+        """Render the counters in the Prometheus text format, or as OpenMetrics.
+
+        OpenMetrics names counter families without their _total suffix.
+        """
+        requests_family, retries_family, errors_family = (
+            _counter_family(name, openmetrics)
+            for name in ("sintra_api_requests_total", "sintra_api_retries_total", "sintra_api_errors_total")
+        )
+        with self._lock:
+            lines = [
+                f"# HELP {requests_family} RIPE Atlas API requests made, by status class",
+                f"# TYPE {requests_family} counter",
+            ]
+            lines += [f'sintra_api_requests_total{{status_class="{label}"}} {count}'
+                      for label, count in sorted(self.requests.items())]
+            lines += [
+                f"# HELP {retries_family} RIPE Atlas API requests retried after a transient failure",
+                f"# TYPE {retries_family} counter",
+                f"sintra_api_retries_total {self.retries}",
+                f"# HELP {errors_family} RIPE Atlas API requests that failed after any retries, by status class",
+                f"# TYPE {errors_family} counter",
+            ]
+            lines += [f'sintra_api_errors_total{{status_class="{label}"}} {count}'
+                      for label, count in sorted(self.errors.items())]
+            lines += [
+                "# HELP sintra_api_request_duration_seconds Time taken by each RIPE Atlas API request",
+                "# TYPE sintra_api_request_duration_seconds histogram",
+            ]
+            cumulative = 0
+            for bound, count in zip(DURATION_BUCKETS, self.duration_counts):
+                cumulative += count
+                lines.append(f'sintra_api_request_duration_seconds_bucket{{le="{bound:g}"}} {cumulative}')
+            lines += [
+                f'sintra_api_request_duration_seconds_bucket{{le="+Inf"}} {self.duration_count}',
+                f"sintra_api_request_duration_seconds_sum {self.duration_sum:g}",
+                f"sintra_api_request_duration_seconds_count {self.duration_count}",
+            ]
+        return "\n".join(lines) + "\n"
+
+
+def _counter_family(name: str, openmetrics: bool) -> str:
+    return name[:-len("_total")] if openmetrics and name.endswith("_total") else name
+
+
+def _label_value(value: Any) -> str:
+    return str(value).replace("\\", "\\\\").replace('"', '\\"').replace("\n", "\\n")
+
+
+class RttHistogram:
+    """Histogram of measured RTTs (sintra_rtt_milliseconds) from the latest Atlas results.
+
+    Every probe result is counted once, however often the same latest result
+    is polled. Each bucket remembers the last result that landed in it, which
+    render(openmetrics=True) attaches as an OpenMetrics exemplar carrying the
+    measurement_id and probe_id, so a latency spike on a dashboard leads back
+    to the exact measurement and probe. Safe to update from several threads.
+    """
+
+    def __init__(self, buckets: Iterable[float] = RTT_BUCKETS):
+        self.buckets = sorted(buckets)
+        self._lock = threading.RLock()
+        self.counts: List[int] = [0] * (len(self.buckets) + 1)  # last entry is +Inf
+        self.sum = 0.0
+        self.count = 0
+        self.exemplars: List[Optional[Tuple[Dict[str, Any], float, Optional[float]]]] = [None] * len(self.counts)
+        self._seen: Dict[Tuple[int, int], Any] = {}
+
+    def observe(self, rtt_ms: float, measurement_id: int, probe_id: int,
+                timestamp: Optional[float] = None) -> None:
+        with self._lock:
+            bucket = bisect.bisect_left(self.buckets, rtt_ms)
+            self.counts[bucket] += 1
+            self.sum += rtt_ms
+            self.count += 1
+            labels = {"measurement_id": measurement_id, "probe_id": probe_id}
+            self.exemplars[bucket] = (labels, rtt_ms, timestamp)
+
+    def observe_results(self, measurement_id: int, results: Iterable[Dict[str, Any]]) -> int:
+        """Count the average RTT of each raw Atlas result not seen before. Returns how many were new."""
+        added = 0
+        for result in results:
+            probe_id = result.get("prb_id")
+            key = (measurement_id, probe_id)
+            with self._lock:
+                if probe_id is None or self._seen.get(key) == result.get("timestamp"):
+                    continue
+                self._seen[key] = result.get("timestamp")
+                rtt = summarize_result(result)["latency_stats"]["avg"]
+                if rtt is not None:
+                    self.observe(rtt, measurement_id, probe_id, result.get("timestamp"))
+                    added += 1
+        return added
+
+    @staticmethod
+    def _exemplar(exemplar) -> str:
+        labels, value, timestamp = exemplar
+        text = ",".join(f'{name}="{_label_value(label)}"' for name, label in labels.items())
+        text = f" # {{{text}}} {value:g}"
+        return text + (f" {timestamp:.3f}" if timestamp is not None else "")
+
+    def render(self, openmetrics: bool = False) -> str:
+        """Render the histogram; exemplars are only part of the OpenMetrics output."""
+        with self._lock:
+            lines = [
+                "# HELP sintra_rtt_milliseconds Average RTT of the latest result of each probe",
+                "# TYPE sintra_rtt_milliseconds histogram",
+            ]
+            if openmetrics:
+                lines.append("# UNIT sintra_rtt_milliseconds milliseconds")
+            cumulative = 0
+            bounds = [f"{bound:g}" for bound in self.buckets] + ["+Inf"]
+            for bound, count, exemplar in zip(bounds, self.counts, self.exemplars):
+                cumulative += count
+                line = f'sintra_rtt_milliseconds_bucket{{le="{bound}"}} {cumulative}'
+                if openmetrics and exemplar:
+                    line += self._exemplar(exemplar)
+                lines.append(line)
+            lines += [
+                f"sintra_rtt_milliseconds_sum {self.sum:g}",
+                f"sintra_rtt_milliseconds_count {self.count}",
+            ]
+        return "\n".join(lines) + "\n"
+
+
+def render_metrics(collectors: Iterable, openmetrics: bool = False) -> str:
+    """Render every collector into one exposition; OpenMetrics output ends with # EOF."""
+    body = "".join(collector.render(openmetrics) for collector in collectors)
+    return body + "# EOF\n" if openmetrics else body
+
+
+def serve_metrics(collectors: Union[ApiMetrics, RttHistogram, Iterable], port: int = DEFAULT_METRICS_PORT,
+                  host: str = "", openmetrics: bool = False) -> ThreadingHTTPServer:
+    """Serve the collectors' metrics at /metrics on a background thread. Call shutdown() on the result to stop.
+
+    With openmetrics the OpenMetrics format is served, which scrapers need
+    in order to read exemplars; not every scraper accepts it.
+    """
+    if hasattr(collectors, "render"):
+        collectors = [collectors]
+    collectors = list(collectors)
+    content_type = OPENMETRICS_CONTENT_TYPE if openmetrics else PROMETHEUS_CONTENT_TYPE
+
+    class MetricsHandler(BaseHTTPRequestHandler):
+        def do_GET(self):
+            if self.path.split("?")[0] != "/metrics":
+                self.send_error(404)
+                return
+            body = render_metrics(collectors, openmetrics).encode()
+            self.send_response(200)
+            self.send_header("Content-Type", content_type)
+            self.send_header("Content-Length", str(len(body)))
+            self.end_headers()
+            self.wfile.write(body)
+
+        def log_message(self, *args):
+            pass
+
+    server = ThreadingHTTPServer((host, port), MetricsHandler)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    return server
@@ -0,0 +1,23 @@
+from typing import Any, Protocol
+import requests
+
+
+class AtlasAPI(Protocol):
+    """What SintraMeasurementClient needs to talk to the RIPE Atlas API: sending one request.
+
+    requests.Session satisfies it and is what the client uses unless given
+    another implementation through its api argument, such as FakeAtlasAPI
+    from measurement_client.fake_atlas for tests and offline runs. Retries,
+    rate limiting, the circuit breaker and typed errors all sit in the
+    client above this seam, so they behave the same whatever the API is.
+
+    url is absolute (the client's base URL plus the endpoint path); keyword
+    arguments are those of requests.Session.request that the client passes:
+    headers, timeout (seconds or a (connect, read) pair), params, json and
+    stream, plus proxies and verify when a proxy or CA bundle is set. The
+    response must be a requests.Response, with content readable through
+    iter_content when stream is set.
+    """
+
+    def request(self, method: str, url: str, **kwargs: Any) -> requests.Response:
+        ...
@@ -0,0 +1,316 @@
+import base64
+import hashlib
+import json
+import os
+import queue
+import socket
+import ssl
+import struct
+import threading
+from typing import Any, Callable, Dict, Iterable, List, Optional
+from urllib.parse import unquote, urlparse
+from measurement_client.logger import logger
+
+# Atlas result stream (plain WebSocket, JSON messages)
+DEFAULT_STREAM_URL = "wss://atlas-stream.ripe.net/stream/"
+
+# Seconds allowed for connecting and the opening handshake
+STREAM_CONNECT_TIMEOUT = 30.0
+
+# Seconds of silence after which the server is pinged; if it stays silent as
+# long again the connection is taken as dead (a half-open TCP connection
+# would otherwise block reads forever)
+STREAM_PING_INTERVAL = 30.0
+
+# Largest message accepted; Atlas results are far smaller, so a bigger
+# length means a broken or hostile server rather than a result
+STREAM_MAX_MESSAGE_SIZE = 4 * 1024 * 1024
+
+# Delay before reconnecting after the stream drops, doubled per failed attempt up to the maximum
+STREAM_RECONNECT_DELAY = 1.0
+STREAM_MAX_RECONNECT_DELAY = 60.0
+
+# RFC 6455 value hashed with the client key to prove the server speaks WebSocket
+_HANDSHAKE_GUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+OPCODE_CONTINUATION = 0x0
+OPCODE_TEXT = 0x1
+OPCODE_BINARY = 0x2
+OPCODE_CLOSE = 0x8
+OPCODE_PING = 0x9
+OPCODE_PONG = 0xA
+
+
+class WebSocketError(Exception):
+    """Raised when the stream handshake fails or the server breaks the WebSocket protocol."""
+
+
+class WebSocket:
+    """Minimal RFC 6455 client: text messages, ping/pong and close, which is all the Atlas stream uses.
+
+    The server is pinged after ping_interval seconds without data, and
+    reads fail with WebSocketError when it stays silent for as long again.
+    Messages longer than max_message_size bytes are refused.
+    """
+
+    def __init__(self, sock: socket.socket, buffered: bytes = b"", ping_interval: float = STREAM_PING_INTERVAL,
+                 max_message_size: int = STREAM_MAX_MESSAGE_SIZE):
+        self.sock = sock
+        self._buffer = buffered
+        self._send_lock = threading.Lock()
+        self.closed = False
+        self.ping_interval = ping_interval
+        self.max_message_size = max_message_size
+        self._ping_unanswered = False
+        # Reads time out at every ping_interval so a silent server can be pinged
+        sock.settimeout(ping_interval)
+
+    @classmethod
+    def connect(cls, url: str, timeout: float = STREAM_CONNECT_TIMEOUT, ping_interval: float = STREAM_PING_INTERVAL,
+                max_message_size: int = STREAM_MAX_MESSAGE_SIZE, proxy: Optional[str] = None,
+                ca_bundle: Optional[str] = None) -> "WebSocket":
+        """Open a ws:// or wss:// URL. Raises WebSocketError or OSError.
+
+        proxy is an http:// proxy to tunnel through with CONNECT; ca_bundle
+        replaces the system CAs for verifying a wss:// server.
+        """
+        parsed = urlparse(url)
+        if parsed.scheme not in ("ws", "wss") or not parsed.hostname:
+            raise WebSocketError(f"Not a WebSocket URL: {url}")
+        port = parsed.port or (443 if parsed.scheme == "wss" else 80)
+        host = parsed.hostname if parsed.port is None else f"{parsed.hostname}:{parsed.port}"
+        sock = _open_tunnel(proxy, parsed.hostname, port, timeout) if proxy \
+            else socket.create_connection((parsed.hostname, port), timeout=timeout)
+        try:
+            if parsed.scheme == "wss":
+                context = ssl.create_default_context(cafile=ca_bundle)
+                sock = context.wrap_socket(sock, server_hostname=parsed.hostname)
+            key = base64.b64encode(os.urandom(16)).decode()
+            path = (parsed.path or "/") + (f"?{parsed.query}" if parsed.query else "")
+            sock.sendall((f"GET {path} HTTP/1.1\r\nHost: {host}\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"
+                          f"Sec-WebSocket-Key: {key}\r\nSec-WebSocket-Version: 13\r\n\r\n").encode())
+
+            status_line, header_lines, buffered = _read_response_head(sock, "the WebSocket handshake")
+            if status_line.split(" ")[1:2] != ["101"]:
+                raise WebSocketError(f"WebSocket handshake rejected: {status_line}")
+            headers = {name.strip().lower(): value.strip()
+                       for name, _, value in (line.partition(":") for line in header_lines)}
+            expected = base64.b64encode(hashlib.sha1((key + _HANDSHAKE_GUID).encode()).digest()).decode()
+            if headers.get("sec-websocket-accept") != expected:
+                raise WebSocketError("WebSocket handshake returned a wrong Sec-WebSocket-Accept")
+        except BaseException:
+            sock.close()
+            raise
+        return cls(sock, buffered, ping_interval, max_message_size)
+
+    def _read_exact(self, size: int) -> bytes:
+        while len(self._buffer) < size:
+            try:
+                chunk = self.sock.recv(max(4096, size - len(self._buffer)))
+            except socket.timeout:
+                if self._ping_unanswered:
+                    raise WebSocketError(f"No data from the server for {2 * self.ping_interval:g}s") from None
+                self._ping_unanswered = True
+                self._send_frame(OPCODE_PING, b"keepalive")
+                continue
+            if not chunk:
+                raise WebSocketError("Connection closed by the server")
+            # Anything received, not only a pong, shows the connection is alive
+            self._ping_unanswered = False
+            self._buffer += chunk
+        data, self._buffer = self._buffer[:size], self._buffer[size:]
+        return data
+
+    def _send_frame(self, opcode: int, payload: bytes = b"") -> None:
+        # Client frames are always masked
+        mask = os.urandom(4)
+        length = len(payload)
+        if length < 126:
+            header = struct.pack("!BB", 0x80 | opcode, 0x80 | length)
+        elif length < 1 << 16:
+            header = struct.pack("!BBH", 0x80 | opcode, 0x80 | 126, length)
+        else:
+            header = struct.pack("!BBQ", 0x80 | opcode, 0x80 | 127, length)
+        masked = bytes(byte ^ mask[i % 4] for i, byte in enumerate(payload))
+        with self._send_lock:
+            self.sock.sendall(header + mask + masked)
+
+    def send_text(self, text: str) -> None:
+        self._send_frame(OPCODE_TEXT, text.encode("utf-8"))
+
+    def receive_text(self) -> Optional[str]:
+        """Return the next text message, answering pings on the way. Returns None once the server closes."""
+        fragments: List[bytes] = []
+        while True:
+            first, second = self._read_exact(2)
+            opcode = first & 0x0F
+            length = second & 0x7F
+            if length == 126:
+                length = struct.unpack("!H", self._read_exact(2))[0]
+            elif length == 127:
+                length = struct.unpack("!Q", self._read_exact(8))[0]
+            if opcode >= OPCODE_CLOSE and length > 125:
+                raise WebSocketError(f"Control frame of {length} bytes; at most 125 are allowed")
+            if sum(map(len, fragments)) + length > self.max_message_size:
+                raise WebSocketError(f"Message larger than {self.max_message_size} bytes")
+            mask = self._read_exact(4) if second & 0x80 else None
+            payload = self._read_exact(length)
+            if mask:
+                payload = bytes(byte ^ mask[i % 4] for i, byte in enumerate(payload))
+
+            if opcode == OPCODE_PING:
+                self._send_frame(OPCODE_PONG, payload)
+            elif opcode == OPCODE_PONG:
+                continue
+            elif opcode == OPCODE_CLOSE:
+                self.close()
+                return None
+            elif opcode in (OPCODE_TEXT, OPCODE_BINARY, OPCODE_CONTINUATION):
+                if opcode != OPCODE_CONTINUATION and fragments:
+                    raise WebSocketError("New message started before the previous one was finished")
+                fragments.append(payload)
+                if first & 0x80:
+                    return b"".join(fragments).decode("utf-8")
+            else:
+                raise WebSocketError(f"Unknown WebSocket opcode {opcode}")
+
+    def close(self) -> None:
+        """Send a close frame (best effort) and shut the socket, waking any blocked receive."""
+        if self.closed:
+            return
+        self.closed = True
+        try:
+            self._send_frame(OPCODE_CLOSE, struct.pack("!H", 1000))
+        except OSError:
+            pass
+        try:
+            self.sock.shutdown(socket.SHUT_RDWR)
+        except OSError:
+            pass
+        self.sock.close()
+
+
+def _read_response_head(sock: socket.socket, during: str):
+    """Read an HTTP response head. Returns (status line, header lines, bytes read past the head)."""
+    response = b""
+    while b"\r\n\r\n" not in response:
+        chunk = sock.recv(4096)
+        if not chunk:
+            raise WebSocketError(f"Connection closed during {during}")
+        response += chunk
+        if len(response) > 64 * 1024:
+            raise WebSocketError(f"Response head too long during {during}")
+    head, rest = response.split(b"\r\n\r\n", 1)
+    status_line, *header_lines = head.decode("latin-1").split("\r\n")
+    return status_line, header_lines, rest
+
+
+def _open_tunnel(proxy: str, host: str, port: int, timeout: float) -> socket.socket:
+    """Connect to host:port through an http:// proxy with CONNECT. Raises WebSocketError or OSError."""
+    parsed = urlparse(proxy)
+    if parsed.scheme != "http" or not parsed.hostname:
+        raise WebSocketError(f"The result stream can only use an http:// proxy, not {proxy}")
+    sock = socket.create_connection((parsed.hostname, parsed.port or 80), timeout=timeout)
+    try:
+        request = f"CONNECT {host}:{port} HTTP/1.1\r\nHost: {host}:{port}\r\n"
+        if parsed.username:
+            credentials = f"{unquote(parsed.username)}:{unquote(parsed.password or '')}"
+            request += f"Proxy-Authorization: Basic {base64.b64encode(credentials.encode()).decode()}\r\n"
+        sock.sendall((request + "\r\n").encode())
+        status_line, _, rest = _read_response_head(sock, "the proxy CONNECT")
+        if status_line.split(" ")[1:2] != ["200"] or rest:
+            raise WebSocketError(f"Proxy refused to connect to {host}:{port}: {status_line}")
+    except BaseException:
+        sock.close()
+        raise
+    return sock
+
+
+class AtlasResultStream:
+    """Subscription to the Atlas result stream for a set of measurements.
+
+    run() delivers each result (the same JSON object the results API
+    returns) to a callback as it arrives; start() does so from a background
+    thread into a queue. A dropped connection is reopened with backoff and
+    the measurements subscribed again, so results published while
+    disconnected are missed unless fetched afterwards.
+    """
+
+    def __init__(self, measurement_ids: Iterable[int], url: str = DEFAULT_STREAM_URL,
+                 reconnect_delay: float = STREAM_RECONNECT_DELAY,
+                 max_reconnect_delay: float = STREAM_MAX_RECONNECT_DELAY,
+                 connect: Optional[Callable[[str], WebSocket]] = None, proxy: Optional[str] = None,
+                 ca_bundle: Optional[str] = None, connect_timeout: float = STREAM_CONNECT_TIMEOUT):
+        self.measurement_ids = sorted(set(int(measurement_id) for measurement_id in measurement_ids))
+        if not self.measurement_ids:
+            raise ValueError("At least one measurement ID is required to stream results")
+        if proxy and urlparse(proxy).scheme != "http":
+            raise ValueError(f"The result stream can only use an http:// proxy, not {proxy}")
+        self.url = url
+        self.reconnect_delay = reconnect_delay
+        self.max_reconnect_delay = max_reconnect_delay
+        # proxy, ca_bundle and connect_timeout are passed to WebSocket.connect unless connect replaces it
+        self._connect = connect or (lambda stream_url: WebSocket.connect(
+            stream_url, timeout=connect_timeout, proxy=proxy, ca_bundle=ca_bundle))
+        self._stop = threading.Event()
+        self._socket: Optional[WebSocket] = None
+        self._thread: Optional[threading.Thread] = None
+
+    def _subscribe(self, websocket: WebSocket) -> None:
+        for measurement_id in self.measurement_ids:
+            websocket.send_text(json.dumps(["atlas_subscribe", {"streamType": "result", "msm": measurement_id}]))
+
+    def _handle_message(self, text: str, callback: Callable[[Dict[str, Any]], None]) -> None:
+        try:
+            event, payload = json.loads(text)
+        except (ValueError, TypeError):
+            logger.warning(f"Ignoring malformed stream message: {text[:200]}")
+            return
+        if event == "atlas_result":
+            callback(payload)
+        elif event == "atlas_subscribed":
+            logger.debug(f"Atlas stream subscription confirmed: {payload}")
+        elif event == "atlas_error":
+            logger.error(f"Atlas stream error: {payload}")
+
+    def run(self, callback: Callable[[Dict[str, Any]], None]) -> None:
+        """Deliver results to callback until stop() is called, reconnecting whenever the stream drops."""
+        delay = self.reconnect_delay
+        while not self._stop.is_set():
+            try:
+                self._socket = self._connect(self.url)
+                self._subscribe(self._socket)
+                logger.info(f"Streaming results of {len(self.measurement_ids)} measurement(s) from {self.url}")
+                delay = self.reconnect_delay
+                while not self._stop.is_set():
+                    text = self._socket.receive_text()
+                    if text is None:
+                        break
+                    self._handle_message(text, callback)
+            except (OSError, WebSocketError, UnicodeDecodeError) as e:
+                if self._stop.is_set():
+                    break
+                logger.warning(f"Atlas stream connection lost: {e}")
+            finally:
+                if self._socket is not None:
+                    self._socket.close()
+            if self._stop.is_set():
+                break
+            logger.info(f"Reconnecting to the Atlas stream in {delay:g}s")
+            self._stop.wait(delay)
+            delay = min(delay * 2, self.max_reconnect_delay)
+
+    def start(self, maxsize: int = 0) -> "queue.Queue[Dict[str, Any]]":
+        """Run the stream in a background thread, putting each result on the returned queue."""
+        results: "queue.Queue[Dict[str, Any]]" = queue.Queue(maxsize)
+        self._thread = threading.Thread(target=self.run, args=(results.put,), daemon=True)
+        self._thread.start()
+        return results
+
+    def stop(self, timeout: Optional[float] = None) -> None:
+        """Stop streaming, closing the connection and waiting for a started thread to finish."""
+        self._stop.set()
+        if self._socket is not None:
+            self._socket.close()
+        if self._thread is not None:
+            self._thread.join(timeout)
@@ -0,0 +1,95 @@
+import base64
+import re
+from datetime import datetime, timezone
+from typing import Any, Dict, Iterator, List, Optional, Tuple
+
+# sslcert results carry the certificate chain as PEM strings, leaf first
+_PEM_CERTIFICATE = re.compile(r"-----BEGIN CERTIFICATE-----(.+?)-----END CERTIFICATE-----", re.S)
+
+# Name attributes reported for a certificate's subject and issuer, by DER-encoded OID
+NAME_ATTRIBUTES = {
+    bytes([0x55, 0x04, 0x03]): "common_name",
+    bytes([0x55, 0x04, 0x0A]): "organization",
+    bytes([0x55, 0x04, 0x06]): "country"
+}
+
+# DER tags of the two time encodings a certificate's validity may use
+_UTC_TIME = 0x17
+_GENERALIZED_TIME = 0x18
+_EXPLICIT_VERSION = 0xA0
+
+
+def _read_tlv(data: bytes, offset: int) -> Tuple[int, bytes, int]:
+    """Read the DER element at offset. Returns (tag, value, offset after the element)."""
+    tag, length = data[offset], data[offset + 1]
+    offset += 2
+    if length & 0x80:
+        size = length & 0x7F
+        length = int.from_bytes(data[offset:offset + size], "big")
+        offset += size
+    if offset + length > len(data):
+        raise ValueError("truncated DER element")
+    return tag, data[offset:offset + length], offset + length
+
+
+def _children(data: bytes) -> Iterator[Tuple[int, bytes]]:
+    offset = 0
+    while offset < len(data):
+        tag, value, offset = _read_tlv(data, offset)
+        yield tag, value
+
+
+def _name(data: bytes) -> Dict[str, str]:
+    attributes = {}
+    for _, relative_name in _children(data):
+        for _, pair in _children(relative_name):
+            (_, oid), (_, value) = list(_children(pair))[:2]
+            key = NAME_ATTRIBUTES.get(oid)
+            if key:
+                attributes[key] = value.decode("utf-8", errors="replace")
+    return attributes
+
+
+def _time(tag: int, data: bytes) -> datetime:
+    fmt = "%y%m%d%H%M%SZ" if tag == _UTC_TIME else "%Y%m%d%H%M%SZ"
+    return datetime.strptime(data.decode("ascii"), fmt).replace(tzinfo=timezone.utc)
+
+
+def decode_certificate(pem: str) -> Dict[str, Any]:
+    """Decode the subject, issuer and validity of a PEM (or bare base64) X.509 certificate.
+
+    Returns {"subject", "issuer", "not_before", "not_after"} with the names as
+    dicts of NAME_ATTRIBUTES and the times as ISO 8601 UTC strings. Only the
+    fields needed to watch expiry are read; nothing is verified. Raises
+    ValueError if the certificate cannot be decoded.
+    """
+    match = _PEM_CERTIFICATE.search(pem)
+    try:
+        der = base64.b64decode("".join((match.group(1) if match else pem).split()), validate=True)
+        _, certificate, _ = _read_tlv(der, 0)
+        _, tbs_certificate, _ = _read_tlv(certificate, 0)
+        fields = list(_children(tbs_certificate))
+        if fields[0][0] == _EXPLICIT_VERSION:
+            fields = fields[1:]
+        # serialNumber, signature, issuer, validity, subject, ...
+        issuer, validity, subject = fields[2][1], fields[3][1], fields[4][1]
+        (not_before_tag, not_before), (not_after_tag, not_after) = list(_children(validity))[:2]
+        return {
+            "subject": _name(subject),
+            "issuer": _name(issuer),
+            "not_before": _time(not_before_tag, not_before).isoformat().replace("+00:00", "Z"),
+            "not_after": _time(not_after_tag, not_after).isoformat().replace("+00:00", "Z")
+        }
+    except (ValueError, IndexError, UnicodeDecodeError) as e:
+        raise ValueError(f"Cannot decode certificate: {e}") from None
+
+
+def decode_certificate_chain(pems: Optional[List[str]]) -> List[Dict[str, Any]]:
+    """Decode every certificate of a chain; one that cannot be decoded becomes {"error": ...}."""
+    chain = []
+    for pem in pems or []:
+        try:
+            chain.append(decode_certificate(pem))
+        except ValueError as e:
+            chain.append({"error": str(e)})
+    return chain
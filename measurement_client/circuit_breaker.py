@@ -0,0 +1,120 @@
+import threading
+import time
+from typing import Optional
+import requests
+from .logger import logger
+
+# Consecutive failed requests (network errors or 5xx) that open the circuit
+DEFAULT_FAILURE_THRESHOLD = 5
+
+# Seconds the circuit stays open before a probe request, doubled after each
+# failed probe up to the maximum
+DEFAULT_OPEN_TIMEOUT = 30.0
+DEFAULT_MAX_OPEN_TIMEOUT = 600.0
+
+CLOSED = "closed"
+OPEN = "open"
+HALF_OPEN = "half_open"
+CIRCUIT_STATES = (CLOSED, OPEN, HALF_OPEN)
+
+
+class CircuitOpenError(requests.ConnectionError):
+    """Raised instead of sending a request while the Atlas API is considered down."""
+
+
+class CircuitBreaker:
+    """Stops sending requests to an API that keeps failing, and probes until it recovers.
+
+    Closed, requests go through and consecutive failures are counted; any
+    response below 500 (even a 4xx) shows the API is up and resets the
+    count. After failure_threshold failures the circuit opens and requests
+    fail fast with CircuitOpenError. Once open_timeout has passed it turns
+    half-open and lets one probe request through: success closes it again,
+    failure reopens it for twice as long, up to max_open_timeout. Shared by
+    every thread of a client; render() exposes the state as metrics.
+    """
+
+    def __init__(self, failure_threshold: int = DEFAULT_FAILURE_THRESHOLD,
+                 open_timeout: float = DEFAULT_OPEN_TIMEOUT,
+                 max_open_timeout: float = DEFAULT_MAX_OPEN_TIMEOUT):
+        if failure_threshold < 1:
+            raise ValueError("Circuit breaker failure_threshold must be at least 1")
+        if open_timeout <= 0 or max_open_timeout < open_timeout:
+            raise ValueError("Circuit breaker open_timeout must be positive and at most max_open_timeout")
+        self.failure_threshold = failure_threshold
+        self.open_timeout = open_timeout
+        self.max_open_timeout = max_open_timeout
+        self.state = CLOSED
+        self.failures = 0
+        self.trips = 0
+        self.rejected = 0
+        self._current_timeout = open_timeout
+        self._opened_at: Optional[float] = None
+        self._probing = False
+        self._lock = threading.Lock()
+
+    def before_request(self) -> None:
+        """Raise CircuitOpenError unless a request may be sent now."""
+        with self._lock:
+            if self.state == OPEN and time.monotonic() - self._opened_at >= self._current_timeout:
+                self.state = HALF_OPEN
+                self._probing = False
+            if self.state == HALF_OPEN and not self._probing:
+                self._probing = True
+                logger.info("Probing whether the Atlas API has recovered")
+                return
+            if self.state != CLOSED:
+                self.rejected += 1
+                retry_in = max(0.0, self._opened_at + self._current_timeout - time.monotonic())
+                raise CircuitOpenError(f"Atlas API circuit breaker is open after repeated failures; "
+                                       f"next attempt in {retry_in:.0f}s")
+
+    def record(self, status_code: Optional[int]) -> None:
+        """Record the outcome of a request: its status code, or None for a network error."""
+        failed = status_code is None or status_code >= 500
+        with self._lock:
+            if not failed:
+                if self.state != CLOSED:
+                    logger.info("Atlas API recovered; circuit breaker closed")
+                self.state = CLOSED
+                self.failures = 0
+                self._current_timeout = self.open_timeout
+                self._probing = False
+                return
+            self.failures += 1
+            if self.state == HALF_OPEN:
+                self._current_timeout = min(self._current_timeout * 2, self.max_open_timeout)
+                self._open()
+            elif self.state == CLOSED and self.failures >= self.failure_threshold:
+                self.trips += 1
+                self._open()
+
+    def _open(self) -> None:
+        self.state = OPEN
+        self._opened_at = time.monotonic()
+        self._probing = False
+        logger.warning(f"Atlas API failing ({self.failures} failed requests in a row); "
+                       f"pausing requests for {self._current_timeout:g}s")
+
+    def render(self, openmetrics: bool = False) -> str:
+        """Render the state and counters in the Prometheus text format, or as OpenMetrics."""
+        trips_family, rejected_family = (
+            name[:-len("_total")] if openmetrics else name
+            for name in ("sintra_api_circuit_trips_total", "sintra_api_circuit_rejected_total")
+        )
+        with self._lock:
+            lines = [
+                "# HELP sintra_api_circuit_state Whether the RIPE Atlas API circuit breaker is in each state",
+                "# TYPE sintra_api_circuit_state gauge",
+            ]
+            lines += [f'sintra_api_circuit_state{{state="{state}"}} {int(state == self.state)}'
+                      for state in CIRCUIT_STATES]
+            lines += [
+                f"# HELP {trips_family} Times the RIPE Atlas API circuit breaker opened",
+                f"# TYPE {trips_family} counter",
+                f"sintra_api_circuit_trips_total {self.trips}",
+                f"# HELP {rejected_family} RIPE Atlas API requests not sent because the circuit was open",
+                f"# TYPE {rejected_family} counter",
+                f"sintra_api_circuit_rejected_total {self.rejected}",
+            ]
+        return "\n".join(lines) + "\n"
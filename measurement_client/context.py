@@ -0,0 +1,77 @@
+import threading
+import time
+import weakref
+from typing import Optional
+
+
+class Cancelled(Exception):
+    """Raised by API calls made under a Context that was cancelled."""
+
+
+class DeadlineExceeded(Cancelled):
+    """Raised by API calls made under a Context whose deadline has passed."""
+
+
+class Context:
+    """Cancellation signal and optional deadline shared by a group of API calls.
+
+    Cancelling a context cancels every context derived from it with
+    with_cancel() or with_timeout(); a derived context's deadline is never
+    later than its parent's. The client checks its context before each
+    request, caps request timeouts at the time remaining, and wakes from
+    retry and polling waits as soon as the context is done. A request
+    already in flight is not interrupted but finishes within its timeout.
+    """
+
+    def __init__(self, parent: Optional["Context"] = None, deadline: Optional[float] = None):
+        # Deadlines are time.monotonic() values
+        if parent is not None and parent.deadline is not None:
+            deadline = parent.deadline if deadline is None else min(deadline, parent.deadline)
+        self.deadline = deadline
+        self._cancelled = threading.Event()
+        self._children: "weakref.WeakSet[Context]" = weakref.WeakSet()
+        self._lock = threading.Lock()
+        if parent is not None:
+            with parent._lock:
+                parent._children.add(self)
+            if parent.cancelled:
+                self.cancel()
+
+    def with_cancel(self) -> "Context":
+        """Return a child context that can be cancelled on its own."""
+        return Context(self)
+
+    def with_timeout(self, seconds: float) -> "Context":
+        """Return a child context whose deadline is seconds from now."""
+        return Context(self, time.monotonic() + seconds)
+
+    def cancel(self) -> None:
+        """Cancel this context and every context derived from it."""
+        self._cancelled.set()
+        with self._lock:
+            children = list(self._children)
+        for child in children:
+            child.cancel()
+
+    @property
+    def cancelled(self) -> bool:
+        return self._cancelled.is_set()
+
+    def remaining(self) -> Optional[float]:
+        """Seconds left until the deadline (at least 0), or None without one."""
+        if self.deadline is None:
+            return None
+        return max(0.0, self.deadline - time.monotonic())
+
+    def check(self) -> None:
+        """Raise Cancelled or DeadlineExceeded if the context is done."""
+        if self.cancelled:
+            raise Cancelled("Operation cancelled")
+        if self.deadline is not None and time.monotonic() >= self.deadline:
+            raise DeadlineExceeded("Deadline exceeded")
+
+    def wait(self, seconds: float) -> None:
+        """Sleep for seconds, raising as soon as the context is cancelled or reaches its deadline."""
+        remaining = self.remaining()
+        self._cancelled.wait(seconds if remaining is None else min(seconds, remaining))
+        self.check()
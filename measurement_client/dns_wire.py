@@ -0,0 +1,147 @@
+import base64
+import ipaddress
+import struct
+from typing import Any, Dict, List, Tuple
+
+# Names of the response codes a DNS header can carry
+RCODES = {0: "NOERROR", 1: "FORMERR", 2: "SERVFAIL", 3: "NXDOMAIN", 4: "NOTIMP", 5: "REFUSED", 6: "YXDOMAIN",
+          7: "YXRRSET", 8: "NXRRSET", 9: "NOTAUTH", 10: "NOTZONE"}
+
+# Names of the record types given in their usual text form; others are shown as TYPE<n> with hex data
+RECORD_TYPES = {1: "A", 2: "NS", 5: "CNAME", 6: "SOA", 12: "PTR", 15: "MX", 16: "TXT", 28: "AAAA", 33: "SRV",
+                41: "OPT"}
+
+_HEADER = struct.Struct("!HHHHHH")
+_RECORD = struct.Struct("!HHIH")
+# Compression pointers followed before a name is taken to loop
+_MAX_POINTERS = 64
+# Longest name on the wire, length octets included (RFC 1035 section 2.3.4)
+_MAX_NAME_LENGTH = 255
+
+
+def _name(data: bytes, offset: int) -> Tuple[str, int]:
+    """Read a possibly compressed domain name. Returns (name, offset after the name in place)."""
+    labels: List[str] = []
+    end = None
+    pointers = 0
+    wire_length = 1  # the root label
+    while True:
+        length = data[offset]
+        if (length & 0xC0) == 0xC0:
+            pointers += 1
+            if pointers > _MAX_POINTERS:
+                raise ValueError("name compression loop")
+            if end is None:
+                end = offset + 2
+            offset = ((length & 0x3F) << 8) | data[offset + 1]
+            continue
+        if length & 0xC0:
+            raise ValueError(f"unsupported label type {length >> 6:#x}")
+        if length == 0:
+            return ".".join(labels) or ".", end if end is not None else offset + 1
+        wire_length += 1 + length
+        if wire_length > _MAX_NAME_LENGTH:
+            raise ValueError(f"name longer than {_MAX_NAME_LENGTH} bytes")
+        label = data[offset + 1:offset + 1 + length]
+        if len(label) != length:
+            raise ValueError("truncated name")
+        labels.append(label.decode("ascii", errors="replace"))
+        offset += 1 + length
+
+
+def _character_strings(rdata: bytes) -> List[str]:
+    strings, offset = [], 0
+    while offset < len(rdata):
+        length = rdata[offset]
+        strings.append(rdata[offset + 1:offset + 1 + length].decode("utf-8", errors="replace"))
+        offset += 1 + length
+    return strings
+
+
+def _rdata(data: bytes, offset: int, length: int, record_type: int) -> str:
+    """Text form of a record's data, read from the whole message so compressed names resolve."""
+    rdata = data[offset:offset + length]
+    if record_type == 1 and length == 4:
+        return str(ipaddress.IPv4Address(rdata))
+    if record_type == 28 and length == 16:
+        return str(ipaddress.IPv6Address(rdata))
+    if record_type in (2, 5, 12):
+        return _name(data, offset)[0]
+    if record_type == 15:
+        return f"{struct.unpack('!H', rdata[:2])[0]} {_name(data, offset + 2)[0]}"
+    if record_type == 16:
+        return " ".join(f'"{text}"' for text in _character_strings(rdata))
+    if record_type == 6:
+        mname, position = _name(data, offset)
+        rname, position = _name(data, position)
+        numbers = struct.unpack("!IIIII", data[position:position + 20])
+        return " ".join([mname, rname] + [str(number) for number in numbers])
+    if record_type == 33:
+        priority, weight, port = struct.unpack("!HHH", rdata[:6])
+        return f"{priority} {weight} {port} {_name(data, offset + 6)[0]}"
+    # RFC 3597 generic form
+    return f"\\# {length} {rdata.hex()}".rstrip()
+
+
+def _records(data: bytes, offset: int, count: int) -> Tuple[List[Dict[str, Any]], int]:
+    records = []
+    for _ in range(count):
+        name, offset = _name(data, offset)
+        record_type, _, ttl, length = _RECORD.unpack_from(data, offset)
+        offset += _RECORD.size
+        if offset + length > len(data):
+            raise ValueError("truncated record data")
+        records.append({
+            "name": name,
+            "type": RECORD_TYPES.get(record_type, f"TYPE{record_type}"),
+            "ttl": ttl,
+            "data": _rdata(data, offset, length, record_type)
+        })
+        offset += length
+    return records, offset
+
+
+def decode_message(data: bytes) -> Dict[str, Any]:
+    """Decode a DNS message in wire format.
+
+    Returns {"id", "rcode", "truncated", "questions", "answers", "authority",
+    "additional"}, rcode by name (or its number when unknown), questions as
+    {"name", "type"} and records as {"name", "type", "ttl", "data"} with the
+    data in its usual text form. Raises ValueError if the message cannot be
+    decoded.
+    """
+    try:
+        (message_id, flags, question_count, answer_count, authority_count,
+         additional_count) = _HEADER.unpack_from(data, 0)
+        offset = _HEADER.size
+        questions = []
+        for _ in range(question_count):
+            name, offset = _name(data, offset)
+            record_type, _ = struct.unpack_from("!HH", data, offset)
+            offset += 4
+            questions.append({"name": name, "type": RECORD_TYPES.get(record_type, f"TYPE{record_type}")})
+        answers, offset = _records(data, offset, answer_count)
+        authority, offset = _records(data, offset, authority_count)
+        additional, offset = _records(data, offset, additional_count)
+    except (struct.error, IndexError, ValueError) as e:
+        raise ValueError(f"Cannot decode DNS message: {e}") from None
+
+    rcode = flags & 0x000F
+    return {
+        "id": message_id,
+        "rcode": RCODES.get(rcode, str(rcode)),
+        "truncated": bool(flags & 0x0200),
+        "questions": questions,
+        "answers": answers,
+        "authority": authority,
+        "additional": additional
+    }
+
+
+def decode_abuf(abuf: str) -> Dict[str, Any]:
+    """Decode the base64 abuf of an Atlas DNS result (see decode_message). Raises ValueError."""
+    try:
+        data = base64.b64decode(abuf, validate=True)
+    except (ValueError, TypeError) as e:
+        raise ValueError(f"Cannot decode DNS message: {e}") from None
+    return decode_message(data)
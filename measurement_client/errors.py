@@ -0,0 +1,158 @@
+import re
+from email.utils import parsedate_to_datetime
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional, Tuple
+import requests
+
+# Error details Atlas gives when an account is out of credits or over a measurement limit
+_QUOTA_PATTERN = re.compile(r"credit|quota|too many (concurrent |running )?measurements", re.IGNORECASE)
+
+
+class ApiError(requests.HTTPError):
+    """An error response from the RIPE Atlas API.
+
+    A requests.HTTPError, so existing handlers keep working; subclasses let
+    callers tell rejected keys, rate limits, exhausted credits and invalid
+    definitions apart. title and detail come from the Atlas error body when
+    it has one; field_errors lists (field, message) pairs such as
+    ("definitions.0.target", "This field is required.").
+    """
+
+    def __init__(self, response: requests.Response, title: str = "", detail: str = "",
+                 field_errors: Optional[List[Tuple[str, str]]] = None):
+        self.status_code = response.status_code
+        self.title = title
+        self.detail = detail
+        self.field_errors = field_errors or []
+        message = f"HTTP {self.status_code}" + (f" {title}" if title else "")
+        if detail:
+            message += f": {detail}"
+        if self.field_errors:
+            message += " (" + "; ".join(f"{field}: {error}" for field, error in self.field_errors) + ")"
+        super().__init__(message, response=response)
+
+
+class UnauthorizedError(ApiError):
+    """401/403: the API key is missing, invalid or lacks the permission for the request."""
+
+
+class NotFoundError(ApiError):
+    """404: the measurement, probe or endpoint does not exist."""
+
+
+class ValidationError(ApiError):
+    """400: Atlas rejected the request body, e.g. an invalid measurement definition."""
+
+
+class QuotaExceededError(ApiError):
+    """The account is out of credits or over its measurement limits."""
+
+
+class RateLimitedError(ApiError):
+    """429: too many requests. retry_after is the wait Atlas asked for in seconds, if it gave one."""
+
+    def __init__(self, response: requests.Response, *args, **kwargs):
+        self.retry_after = parse_retry_after(response.headers.get("Retry-After"))
+        super().__init__(response, *args, **kwargs)
+
+
+class ServerError(ApiError):
+    """5xx: Atlas failed to handle the request."""
+
+
+class CorruptBodyError(requests.RequestException, ValueError):
+    """A gzip response body that is invalid, cut short or followed by other data.
+
+    Like requests' JSONDecodeError both a RequestException and a ValueError,
+    so handlers of either catch it.
+    """
+
+
+def parse_retry_after(value: Optional[str], now: Optional[datetime] = None) -> Optional[float]:
+    """Seconds to wait from a Retry-After header given in seconds or as an HTTP date."""
+    if not isinstance(value, str) or not value.strip():
+        return None
+    value = value.strip()
+    if value.isdigit():
+        return float(value)
+    try:
+        moment = parsedate_to_datetime(value)
+    except (TypeError, ValueError):
+        return None
+    if moment.tzinfo is None:
+        moment = moment.replace(tzinfo=timezone.utc)
+    return max(0.0, (moment - (now or datetime.now(timezone.utc))).total_seconds())
+
+
+def _field_errors(body: Dict[str, Any]) -> List[Tuple[str, str]]:
+    """(field, message) pairs from an Atlas error body's errors list or a per-field error mapping."""
+    errors = body.get("errors")
+    if isinstance(errors, list):
+        pairs = []
+        for error in errors:
+            if not isinstance(error, dict):
+                continue
+            source = error.get("source") if isinstance(error.get("source"), dict) else {}
+            field = (source.get("pointer") or source.get("parameter") or "").strip("/").replace("/", ".")
+            pairs.append((field or "request", str(error.get("detail") or error.get("title") or "")))
+        return pairs
+
+    pairs = []
+
+    def walk(value: Any, path: str) -> None:
+        if isinstance(value, dict):
+            for key, item in value.items():
+                walk(item, f"{path}.{key}" if path else str(key))
+        elif isinstance(value, list) and value and all(isinstance(item, str) for item in value):
+            pairs.extend((path or "request", item) for item in value)
+        elif isinstance(value, list):
+            for index, item in enumerate(value):
+                walk(item, f"{path}.{index}" if path else str(index))
+
+    walk(body, "")
+    return pairs
+
+
+def api_error(response: requests.Response) -> ApiError:
+    """Build the typed error for an error response from its status code and Atlas error body."""
+    try:
+        body = response.json()
+    except Exception:
+        body = None
+    title = detail = ""
+    field_errors: List[Tuple[str, str]] = []
+    if isinstance(body, dict):
+        # Atlas wraps errors as {"error": {"status", "code", "title", "detail", "errors": [...]}}
+        error = body.get("error") if isinstance(body.get("error"), dict) else None
+        if error is not None:
+            title = str(error.get("title") or "")
+            detail = str(error.get("detail") or "")
+            field_errors = _field_errors(error)
+        elif isinstance(body.get("detail"), str):
+            detail = body["detail"]
+        else:
+            field_errors = _field_errors(body)
+
+    status = response.status_code
+    if status == 429:
+        cls = RateLimitedError
+    elif status == 402 or (status in (400, 403) and _QUOTA_PATTERN.search(detail)):
+        cls = QuotaExceededError
+    elif status in (401, 403):
+        cls = UnauthorizedError
+    elif status == 404:
+        cls = NotFoundError
+    elif status == 400:
+        cls = ValidationError
+    elif status >= 500:
+        cls = ServerError
+    else:
+        cls = ApiError
+    return cls(response, title, detail, field_errors)
+
+
+def error_text(error: requests.HTTPError) -> str:
+    """The parsed Atlas error of an HTTPError when it has one, else the raw response body."""
+    if isinstance(error, ApiError) and (error.detail or error.field_errors):
+        return str(error)
+    return error.response.text if error.response is not None else str(error)
@@ -0,0 +1,617 @@
+import csv
+import io
+import json
+import math
+import statistics
+from collections import OrderedDict
+from datetime import datetime, timezone
+from pathlib import Path
+from typing import Any, Callable, Dict, List, Optional, TextIO, Tuple
+import yaml
+from event_manager.anomaly_types import ANOMALY_TYPES
+from measurement_client.processors import summarize_result
+
+# Column order for exported per-probe rows
+EXPORT_FIELDS = [
+    "measurement_id",
+    "measurement_type",
+    "probe_id",
+    "probe_country_code",
+    "probe_asn",
+    "target",
+    "timestamp",
+    "rtt_min",
+    "rtt_avg",
+    "rtt_max",
+    "packet_loss_percentage",
+    "packets_sent",
+    "packets_received",
+    "hops_count"
+]
+
+# Type of each export column; ResultTable coerces values to it (None stays None)
+COLUMN_TYPES = {
+    "measurement_id": int,
+    "measurement_type": str,
+    "probe_id": int,
+    "probe_country_code": str,
+    "probe_asn": int,
+    "target": str,
+    "timestamp": str,
+    "rtt_min": float,
+    "rtt_avg": float,
+    "rtt_max": float,
+    "packet_loss_percentage": float,
+    "packets_sent": int,
+    "packets_received": int,
+    "hops_count": int,
+    "probe_firmware": int,
+    "probe_hardware_version": int,
+    "probe_is_anchor": bool
+}
+
+# Extra columns present when results were fetched with --enrich
+ENRICHED_FIELDS = [
+    "probe_firmware",
+    "probe_hardware_version",
+    "probe_is_anchor"
+]
+
+# Formats written one row per result; matrix pivots rows instead
+ROW_FORMATS = ["json", "csv", "yaml"]
+# Aligned columns for reading in a terminal; needs every row first, so it cannot be streamed
+TABLE_FORMAT = "table"
+MATRIX_FORMAT = "matrix"
+CHART_FORMAT = "chartjs"
+EXPORT_FORMATS = ROW_FORMATS + [TABLE_FORMAT, MATRIX_FORMAT, CHART_FORMAT]
+
+# Row fields exports can be split into one file per value by
+SPLIT_KEYS = {"probe": "probe_id"}
+
+# Files SplitRowWriter keeps open at once; others are reopened for append
+DEFAULT_MAX_OPEN_FILES = 64
+
+# Default time bucket width, in seconds, for matrix output
+DEFAULT_MATRIX_RESOLUTION = 3600
+
+
+def _mean(values: List[float]) -> float:
+    return sum(values) / len(values)
+
+
+def _spread(values: List[float]) -> float:
+    return statistics.stdev(values) if len(values) > 1 else math.nan
+
+
+# Metrics matrix and chartjs output can plot: the row field read, its units,
+# and how samples falling into the same time bucket are combined.
+# jitter is the variation of per-result average RTT within a bucket. The keys
+# are the fetch --metric choices.
+PIVOT_METRICS = {
+    "rtt_avg": {"field": "rtt_avg", "units": "ms", "aggregate": _mean},
+    "loss": {"field": "packet_loss_percentage", "units": "%", "aggregate": _mean},
+    "jitter": {"field": "rtt_avg", "units": "ms", "aggregate": _spread}
+}
+DEFAULT_PIVOT_METRIC = "rtt_avg"
+
+
+class _YamlDumper(yaml.SafeDumper):
+    """Safe dumper that never emits &anchor/*alias references for repeated values."""
+
+    def ignore_aliases(self, data):
+        return True
+
+
+def dump_yaml(data: Any, stream: Optional[TextIO] = None) -> Optional[str]:
+    """Dump data as block-style YAML, keeping key order as given."""
+    return yaml.dump(data, stream, Dumper=_YamlDumper, sort_keys=False, default_flow_style=False)
+
+
+def result_rows(processed: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """Flatten a processed measurement (as saved by fetch) into one row per probe."""
+    rows = []
+    for result in processed.get("results", []):
+        latency_stats = result.get("latency_stats") or {}
+        rows.append({
+            "measurement_id": result.get("measurement_id", processed.get("measurement_id")),
+            "measurement_type": result.get("measurement_type", processed.get("measurement_type")),
+            "probe_id": result.get("probe_id"),
+            "probe_country_code": result.get("probe_country_code"),
+            "probe_asn": result.get("probe_asn"),
+            "target": result.get("target_address") or result.get("target") or processed.get("target"),
+            "timestamp": result.get("timestamp"),
+            "rtt_min": latency_stats.get("min"),
+            "rtt_avg": latency_stats.get("avg"),
+            "rtt_max": latency_stats.get("max"),
+            "packet_loss_percentage": result.get("packet_loss_percentage"),
+            "packets_sent": result.get("packets_sent"),
+            "packets_received": result.get("packets_received"),
+            "hops_count": result.get("hops_count")
+        })
+        if "probe_hardware_version" in result:
+            rows[-1].update({
+                "probe_firmware": result.get("firmware_version"),
+                "probe_hardware_version": result.get("probe_hardware_version"),
+                "probe_is_anchor": result.get("probe_is_anchor")
+            })
+    return rows
+
+
+def raw_result_row(result: Dict[str, Any], measurement_id: int, measurement_type: Optional[str],
+                   probe_info: Optional[Dict[str, Any]] = None, enrich: bool = False) -> Dict[str, Any]:
+    """Flatten one raw Atlas result into an export row.
+
+    Used when streaming, where rows are per result rather than aggregated
+    per probe as in result_rows. With enrich the ENRICHED_FIELDS are added.
+    """
+    probe_info = probe_info or {}
+    timestamp = result.get("timestamp")
+    row = {
+        "measurement_id": measurement_id,
+        "measurement_type": measurement_type,
+        "probe_id": result.get("prb_id"),
+        "probe_country_code": probe_info.get("country_code"),
+        "probe_asn": probe_info.get("asn"),
+        "target": result.get("dst_addr") or result.get("dst_name"),
+        "timestamp": datetime.utcfromtimestamp(timestamp).isoformat() if timestamp else None,
+        "rtt_min": None,
+        "rtt_avg": None,
+        "rtt_max": None,
+        "packet_loss_percentage": None,
+        "packets_sent": None,
+        "packets_received": None,
+        "hops_count": None
+    }
+
+    summary = summarize_result(result, measurement_type)
+    latency_stats = summary["latency_stats"]
+    row.update({
+        "rtt_min": latency_stats["min"],
+        "rtt_avg": latency_stats["avg"],
+        "rtt_max": latency_stats["max"],
+        "packet_loss_percentage": summary["packet_loss_percentage"],
+        "hops_count": summary.get("hops_count")
+    })
+    if measurement_type == "ping":
+        row.update({"packets_sent": result.get("sent"), "packets_received": result.get("rcvd")})
+
+    if enrich:
+        row.update({
+            "probe_firmware": result.get("fw"),
+            "probe_hardware_version": probe_info.get("hardware_version"),
+            "probe_is_anchor": probe_info.get("is_anchor")
+        })
+    return row
+
+
+class RowWriter:
+    """Write export rows one at a time, as CSV, a JSON array or a YAML list.
+
+    The streaming counterpart of write_rows: nothing is buffered, so the
+    output can be larger than memory. Call close() to finish a JSON array.
+    """
+
+    def __init__(self, output_format: str, stream: TextIO, fields: Optional[List[str]] = None):
+        if output_format not in ROW_FORMATS:
+            raise ValueError(f"Unsupported export format '{output_format}'. Must be one of: {', '.join(ROW_FORMATS)}")
+        self.output_format = output_format
+        self.stream = stream
+        self.count = 0
+        self._csv = None
+        if output_format == "csv":
+            self._csv = csv.DictWriter(stream, fieldnames=list(fields or EXPORT_FIELDS), extrasaction="ignore")
+            self._csv.writeheader()
+        elif output_format == "json":
+            stream.write("[")
+
+    def write(self, row: Dict[str, Any]) -> None:
+        if self._csv:
+            self._csv.writerow({
+                key: ";".join(value) if isinstance(value, list) else value
+                for key, value in row.items()
+            })
+        elif self.output_format == "yaml":
+            dump_yaml([row], self.stream)
+        else:
+            self.stream.write(",\n  " if self.count else "\n  ")
+            self.stream.write(json.dumps(row))
+        self.count += 1
+
+    def close(self) -> None:
+        if self.output_format == "json":
+            self.stream.write("\n]\n" if self.count else "]\n")
+        elif self.output_format == "yaml" and not self.count:
+            self.stream.write("[]\n")
+
+
+class SplitRowWriter:
+    """Append rows as JSON lines to one file per value of a row field.
+
+    With split_by="probe" rows go to <directory>/<probe_id>.jsonl. Existing
+    files are appended to, so repeated fetches build up a per-probe archive.
+    At most max_open_files handles are kept open (least recently used are
+    closed first), so any number of probes can be written.
+    """
+
+    def __init__(self, directory: str, split_by: str = "probe", max_open_files: int = DEFAULT_MAX_OPEN_FILES):
+        if split_by not in SPLIT_KEYS:
+            raise ValueError(f"Unsupported split '{split_by}'. Must be one of: {', '.join(SPLIT_KEYS)}")
+        if max_open_files <= 0:
+            raise ValueError("max_open_files must be greater than zero")
+        self.directory = Path(directory)
+        self.directory.mkdir(parents=True, exist_ok=True)
+        self.field = SPLIT_KEYS[split_by]
+        self.max_open_files = max_open_files
+        self.count = 0
+        self.files = set()
+        self._handles: "OrderedDict[str, TextIO]" = OrderedDict()
+
+    def _handle(self, name: str) -> TextIO:
+        handle = self._handles.get(name)
+        if handle is not None:
+            self._handles.move_to_end(name)
+            return handle
+        if len(self._handles) >= self.max_open_files:
+            _, oldest = self._handles.popitem(last=False)
+            oldest.close()
+        handle = open(self.directory / f"{name}.jsonl", "a")
+        self._handles[name] = handle
+        return handle
+
+    def write(self, row: Dict[str, Any]) -> None:
+        value = row.get(self.field)
+        name = "unknown" if value is None else str(value)
+        self._handle(name).write(json.dumps(row) + "\n")
+        self.files.add(name)
+        self.count += 1
+
+    def close(self) -> None:
+        while self._handles:
+            _, handle = self._handles.popitem()
+            handle.close()
+
+    def checkpoint(self) -> Dict[str, int]:
+        """Flush open files and return the size of every file in the directory."""
+        for handle in self._handles.values():
+            handle.flush()
+        return {path.name: path.stat().st_size for path in self.directory.glob("*.jsonl")}
+
+    def rollback(self, sizes: Dict[str, int]) -> None:
+        """Restore the directory to a checkpoint: truncate files back and delete newer ones."""
+        self.close()
+        for path in self.directory.glob("*.jsonl"):
+            if path.name in sizes:
+                with open(path, "r+") as f:
+                    f.truncate(sizes[path.name])
+            else:
+                path.unlink()
+
+
+def classify_row(row: Dict[str, Any], latency_threshold_ms: float,
+                 loss_threshold_pct: float) -> List[str]:
+    """Return the anomaly types (keys of ANOMALY_TYPES) a row matches.
+
+    Rules mirror the event manager's static thresholds: RTT above the latency
+    threshold is a latency_spike, loss above the loss threshold is
+    packet_loss, and 100% loss is additionally an unreachable_host.
+    """
+    anomalies = []
+    rtt_avg = row.get("rtt_avg")
+    loss = row.get("packet_loss_percentage")
+
+    if rtt_avg is not None and rtt_avg > latency_threshold_ms:
+        anomalies.append("latency_spike")
+    if loss is not None and loss > loss_threshold_pct:
+        anomalies.append("packet_loss")
+    if loss is not None and loss >= 100.0:
+        anomalies.append("unreachable_host")
+
+    return [a for a in anomalies if a in ANOMALY_TYPES]
+
+
+def annotate_rows(rows: List[Dict[str, Any]], latency_threshold_ms: float = 250.0,
+                  loss_threshold_pct: float = 0.0) -> List[Dict[str, Any]]:
+    """Add an `anomaly` field listing the matched anomaly types (empty when normal)."""
+    for row in rows:
+        row["anomaly"] = classify_row(row, latency_threshold_ms, loss_threshold_pct)
+    return rows
+
+
+def row_fields(rows: List[Dict[str, Any]], fields: Optional[List[str]] = None) -> List[str]:
+    """Columns for a set of rows: fields (EXPORT_FIELDS by default) plus enrichment/anomaly when present."""
+    fields = list(fields or EXPORT_FIELDS)
+    if any("probe_hardware_version" in row for row in rows):
+        fields.extend(field for field in ENRICHED_FIELDS if field not in fields)
+    if any("anomaly" in row for row in rows) and "anomaly" not in fields:
+        fields.append("anomaly")
+    return fields
+
+
+def write_rows(rows: List[Dict[str, Any]], output_format: str, stream: TextIO,
+               fields: Optional[List[str]] = None) -> None:
+    """Write rows to a stream as CSV, JSON, YAML or an aligned table.
+
+    List values (such as the anomaly annotation) are joined with ';' in CSV
+    and table output so each row stays a single line.
+    """
+    if output_format == "json":
+        json.dump(rows, stream, indent=2)
+        stream.write("\n")
+    elif output_format == "yaml":
+        # One list item at a time so large result sets are not built up as a single YAML document
+        for row in rows:
+            dump_yaml([row], stream)
+        if not rows:
+            stream.write("[]\n")
+    elif output_format == "csv":
+        writer = csv.DictWriter(stream, fieldnames=row_fields(rows, fields), extrasaction="ignore")
+        writer.writeheader()
+        for row in rows:
+            writer.writerow({
+                key: ";".join(value) if isinstance(value, list) else value
+                for key, value in row.items()
+            })
+    elif output_format == TABLE_FORMAT:
+        _write_table(rows, row_fields(rows, fields), stream)
+    else:
+        formats = ROW_FORMATS + [TABLE_FORMAT]
+        raise ValueError(f"Unsupported export format '{output_format}'. Must be one of: {', '.join(formats)}")
+
+
+def _table_cell(value: Any) -> str:
+    if value is None:
+        return "-"
+    if isinstance(value, float):
+        return f"{value:.2f}"
+    if isinstance(value, list):
+        return ";".join(map(str, value))
+    return str(value)
+
+
+def _write_table(rows: List[Dict[str, Any]], fields: List[str], stream: TextIO) -> None:
+    cells = [[_table_cell(row.get(field)) for field in fields] for row in rows]
+    widths = [max([len(field)] + [len(line[i]) for line in cells]) for i, field in enumerate(fields)]
+    for line in [fields] + cells:
+        stream.write("  ".join(cell.ljust(width) for cell, width in zip(line, widths)).rstrip() + "\n")
+
+
+def _coerce(value: Any, column_type: Optional[type]) -> Any:
+    if value is None or column_type is None or isinstance(value, column_type):
+        return value
+    if column_type is bool and isinstance(value, str):
+        return value.strip().lower() in ("1", "true", "yes")
+    if column_type is int and isinstance(value, str) and "." in value:
+        return int(float(value))
+    return column_type(value)
+
+
+class ResultTable:
+    """Export rows as a columnar table, for using Sintra as a library.
+
+    columns names the fields and each row is a tuple of values in column
+    order, coerced to the types in COLUMN_TYPES. Iterating yields each row
+    as a dict; filter() returns a new table; to_csv()/to_json() render it
+    the same way fetch --output csv/json does.
+    """
+
+    def __init__(self, columns: List[str], rows: Optional[List[Tuple[Any, ...]]] = None):
+        self.columns = list(columns)
+        self.rows = [tuple(row) for row in rows or []]
+        for row in self.rows:
+            if len(row) != len(self.columns):
+                raise ValueError(f"Row has {len(row)} values but the table has {len(self.columns)} columns")
+
+    def __len__(self) -> int:
+        return len(self.rows)
+
+    def __iter__(self):
+        for row in self.rows:
+            yield dict(zip(self.columns, row))
+
+    def column(self, name: str) -> List[Any]:
+        """All values of one column, in row order."""
+        if name not in self.columns:
+            raise KeyError(f"Unknown column '{name}'. Must be one of: {', '.join(self.columns)}")
+        index = self.columns.index(name)
+        return [row[index] for row in self.rows]
+
+    def filter(self, predicate: Callable[[Dict[str, Any]], bool]) -> "ResultTable":
+        """A new table with the rows for which predicate(row as dict) is true."""
+        return ResultTable(self.columns, [row for row, record in zip(self.rows, self) if predicate(record)])
+
+    def to_csv(self, stream: Optional[TextIO] = None) -> Optional[str]:
+        """Write the table as CSV to stream, or return it as a string without one."""
+        return self._render("csv", stream)
+
+    def to_json(self, stream: Optional[TextIO] = None) -> Optional[str]:
+        """Write the table as a JSON array of objects to stream, or return it as a string without one."""
+        return self._render("json", stream)
+
+    def _render(self, output_format: str, stream: Optional[TextIO]) -> Optional[str]:
+        out = stream or io.StringIO()
+        write_rows(list(self), output_format, out, self.columns)
+        return None if stream else out.getvalue()
+
+
+def to_table(rows: List[Dict[str, Any]], columns: Optional[List[str]] = None) -> ResultTable:
+    """Build a ResultTable from export rows (from result_rows or raw_result_row).
+
+    Columns default to those fetch would export for these rows; fields a
+    row lacks are None.
+    """
+    columns = row_fields(rows, columns)
+    return ResultTable(columns, [
+        tuple(_coerce(row.get(column), COLUMN_TYPES.get(column)) for column in columns)
+        for row in rows
+    ])
+
+
+def _row_epoch(timestamp: Any) -> Optional[float]:
+    """Epoch seconds for a row timestamp (epoch number or ISO string, naive means UTC)."""
+    if timestamp is None:
+        return None
+    if isinstance(timestamp, (int, float)):
+        return float(timestamp)
+    parsed = datetime.fromisoformat(str(timestamp).replace("Z", "+00:00"))
+    if parsed.tzinfo is None:
+        parsed = parsed.replace(tzinfo=timezone.utc)
+    return parsed.timestamp()
+
+
+def result_matrix(rows: List[Dict[str, Any]], resolution: int = DEFAULT_MATRIX_RESOLUTION,
+                  metric: str = DEFAULT_PIVOT_METRIC) -> Tuple[List[int], List[datetime], List[List[float]]]:
+    """Pivot export rows into a probe x time matrix of a metric (average RTT by default).
+
+    Timestamps are floored to `resolution`-second buckets so irregularly
+    sampled probes line up; rows sharing a cell are combined as the metric
+    in PIVOT_METRICS defines. Time buckets are contiguous from the first to
+    the last sample, and cells without a value are NaN. Returns (probe ids,
+    bucket start times, values by probe).
+    """
+    if resolution <= 0:
+        raise ValueError("Matrix resolution must be greater than zero")
+    if metric not in PIVOT_METRICS:
+        raise ValueError(f"Unsupported metric '{metric}'. Must be one of: {', '.join(PIVOT_METRICS)}")
+    field = PIVOT_METRICS[metric]["field"]
+    aggregate = PIVOT_METRICS[metric]["aggregate"]
+
+    cells: Dict[Tuple[int, int], List[float]] = {}
+    probes = set()
+    buckets = set()
+    for row in rows:
+        probe_id = row.get("probe_id")
+        epoch = _row_epoch(row.get("timestamp"))
+        if probe_id is None or epoch is None:
+            continue
+        bucket = int(epoch // resolution) * resolution
+        probes.add(probe_id)
+        buckets.add(bucket)
+        if row.get(field) is not None:
+            cells.setdefault((probe_id, bucket), []).append(row[field])
+
+    if not buckets:
+        return [], [], []
+
+    bucket_range = list(range(min(buckets), max(buckets) + resolution, resolution))
+    probe_ids = sorted(probes)
+    values = []
+    for probe_id in probe_ids:
+        values.append([
+            aggregate(cells[(probe_id, bucket)]) if (probe_id, bucket) in cells else math.nan
+            for bucket in bucket_range
+        ])
+    times = [datetime.fromtimestamp(bucket, timezone.utc) for bucket in bucket_range]
+    return probe_ids, times, values
+
+
+def align_results(rows: List[Dict[str, Any]], bucket: int = DEFAULT_MATRIX_RESOLUTION,
+                  field: str = "rtt_avg") -> Dict[datetime, Dict[int, Optional[float]]]:
+    """Snap each row to the nearest bucket and return time -> probe id -> value.
+
+    Unlike result_matrix, which floors timestamps, samples go to whichever
+    bucket start is closest, so a probe reporting a few seconds before the
+    hour lands on the hour. A sample exactly halfway between two buckets
+    goes to the later one. Samples sharing a cell are averaged. Every bucket
+    from the first to the last has an entry for every probe, with None
+    where the probe has no value, so gaps are explicit.
+    """
+    if bucket <= 0:
+        raise ValueError("Bucket width must be greater than zero")
+
+    cells: Dict[Tuple[int, int], List[float]] = {}
+    probes = set()
+    buckets = set()
+    for row in rows:
+        probe_id = row.get("probe_id")
+        epoch = _row_epoch(row.get("timestamp"))
+        if probe_id is None or epoch is None:
+            continue
+        # floor(x + 0.5) rounds halves up, unlike round() which rounds them to even
+        slot = math.floor(epoch / bucket + 0.5) * bucket
+        probes.add(probe_id)
+        buckets.add(slot)
+        if row.get(field) is not None:
+            cells.setdefault((probe_id, slot), []).append(row[field])
+
+    if not buckets:
+        return {}
+
+    aligned = {}
+    for slot in range(min(buckets), max(buckets) + bucket, bucket):
+        aligned[datetime.fromtimestamp(slot, timezone.utc)] = {
+            probe_id: _mean(cells[(probe_id, slot)]) if (probe_id, slot) in cells else None
+            for probe_id in sorted(probes)
+        }
+    return aligned
+
+
+def compare_probe_rows(rows_a: List[Dict[str, Any]], rows_b: List[Dict[str, Any]],
+                       bucket: int) -> List[Dict[str, Any]]:
+    """Line up one probe's rows from two measurements by time and compute B - A deltas.
+
+    Both sides are snapped to common buckets with align_results, so samples
+    taken a little apart still pair up. Returns one entry per bucket with
+    rtt_a/rtt_b/rtt_delta and loss_a/loss_b/loss_delta (None where a side
+    has no value).
+    """
+    def by_time(rows, field):
+        return {time: next(iter(values.values()), None)
+                for time, values in align_results(rows, bucket, field).items()}
+
+    rtt_a, rtt_b = by_time(rows_a, "rtt_avg"), by_time(rows_b, "rtt_avg")
+    loss_a, loss_b = by_time(rows_a, "packet_loss_percentage"), by_time(rows_b, "packet_loss_percentage")
+
+    def delta(a, b):
+        return None if a is None or b is None else b - a
+
+    comparison = []
+    for time in sorted(set(rtt_a) | set(rtt_b)):
+        comparison.append({
+            "timestamp": time,
+            "rtt_a": rtt_a.get(time),
+            "rtt_b": rtt_b.get(time),
+            "rtt_delta": delta(rtt_a.get(time), rtt_b.get(time)),
+            "loss_a": loss_a.get(time),
+            "loss_b": loss_b.get(time),
+            "loss_delta": delta(loss_a.get(time), loss_b.get(time))
+        })
+    return comparison
+
+
+def write_matrix(probes: List[int], times: List[datetime], values: List[List[float]],
+                 stream: TextIO) -> None:
+    """Write a result matrix as CSV: one row per probe, one column per time bucket."""
+    writer = csv.writer(stream)
+    writer.writerow(["probe_id"] + [t.isoformat().replace("+00:00", "Z") for t in times])
+    for probe_id, probe_values in zip(probes, values):
+        writer.writerow([probe_id] + ["NaN" if math.isnan(v) else round(v, 3) for v in probe_values])
+
+
+def chartjs_data(probes: List[int], times: List[datetime], values: List[List[float]],
+                 metric: str = DEFAULT_PIVOT_METRIC) -> Dict[str, Any]:
+    """Shape a result matrix as a Chart.js `data` object: one dataset per probe.
+
+    labels are the bucket start times (ISO 8601, UTC) and each dataset's
+    data lines up with them, with null where the probe has no value. The
+    extra `metric`, `units` and per-dataset `probe_id` keys are ignored by
+    Chart.js, so the object can be passed to `new Chart(ctx, {data})` as is.
+    """
+    return {
+        "metric": metric,
+        "units": PIVOT_METRICS[metric]["units"],
+        "labels": [t.isoformat().replace("+00:00", "Z") for t in times],
+        "datasets": [
+            {
+                "label": f"Probe {probe_id}",
+                "probe_id": probe_id,
+                "data": [None if math.isnan(v) else round(v, 3) for v in probe_values]
+            }
+            for probe_id, probe_values in zip(probes, values)
+        ]
+    }
+
+
+def write_chartjs(probes: List[int], times: List[datetime], values: List[List[float]],
+                  stream: TextIO, metric: str = DEFAULT_PIVOT_METRIC) -> None:
+    json.dump(chartjs_data(probes, times, values, metric), stream, indent=2)
+    stream.write("\n")
@@ -0,0 +1,295 @@
+import hashlib
+import json
+import re
+import threading
+import time
+from collections import deque
+from typing import Any, Callable, Deque, Dict, Iterable, List, Optional, Tuple
+from urllib.parse import parse_qsl, urlparse
+import requests
+from requests.structures import CaseInsensitiveDict
+
+# First ID handed out to measurements created through the fake
+FIRST_MEASUREMENT_ID = 100000001
+
+# Atlas status of a running and of a stopped measurement
+STATUS_ONGOING = {"id": 2, "name": "Ongoing"}
+STATUS_STOPPED = {"id": 4, "name": "Stopped"}
+
+# Query parameters that shape a response rather than filter it
+_NON_FILTER_PARAMS = {"page", "page_size", "format", "fields", "optional_fields", "sort"}
+
+
+class FakeAtlasAPI:
+    """In-memory RIPE Atlas API implementing AtlasAPI, for tests and offline runs.
+
+    Pass it to SintraMeasurementClient(api=...) and the client's create,
+    fetch, stop, probe and credit calls run against measurements, results
+    and probes held here instead of the network. Seed it with
+    add_measurement, add_results and probes; every request is recorded in
+    requests as (method, path, params, json) and fail_next queues error
+    responses to exercise retries and error handling.
+
+    Covered endpoints: /measurements/ (create, list), /measurements/my/,
+    /measurements/validate/, /measurements/<id>/ (get, stop, update) and its
+    results/, latest/, status-check/ and participation-requests/, /probes/,
+    /probes/<id>/ and /credits/. Lists come back as a single page; filters
+    match fields exactly, with __in (comma separated) and __contains
+    forms, and filters on fields the objects lack are ignored. Successful
+    GETs carry an ETag of their body and If-None-Match gets 304 answers.
+    """
+
+    def __init__(self, probes: Iterable[Dict[str, Any]] = (), credits: float = 100000):
+        self.measurements: Dict[int, Dict[str, Any]] = {}
+        self.results: Dict[int, List[Dict[str, Any]]] = {}
+        self.probes: Dict[int, Dict[str, Any]] = {probe["id"]: probe for probe in probes}
+        self.credits: Dict[str, Any] = {"current_balance": credits, "estimated_daily_income": 0,
+                                        "estimated_daily_expenditure": 0}
+        self.participation_requests: Dict[int, List[Dict[str, Any]]] = {}
+        self.requests: List[Tuple[str, str, Dict[str, Any], Any]] = []
+        self._failures: Deque[Tuple[int, Any, Dict[str, str]]] = deque()
+        self._next_id = FIRST_MEASUREMENT_ID
+        self._lock = threading.RLock()
+        self._routes: List[Tuple[str, "re.Pattern[str]", Callable[..., Tuple[int, Any]]]] = [
+            ("POST", re.compile(r"/measurements/validate/$"), self._validate),
+            ("GET", re.compile(r"/measurements/(?:my/)?$"), self._list_measurements),
+            ("POST", re.compile(r"/measurements/$"), self._create),
+            ("GET", re.compile(r"/measurements/(\d+)/$"), self._get_measurement),
+            ("DELETE", re.compile(r"/measurements/(\d+)/$"), self._stop),
+            ("PATCH", re.compile(r"/measurements/(\d+)/$"), self._update),
+            ("GET", re.compile(r"/measurements/(\d+)/results/$"), self._results),
+            ("GET", re.compile(r"/measurements/(\d+)/latest/$"), self._latest),
+            ("GET", re.compile(r"/measurements/(\d+)/status-check/$"), self._status_check),
+            ("POST", re.compile(r"/measurements/(\d+)/participation-requests/$"), self._participation),
+            ("GET", re.compile(r"/probes/$"), self._list_probes),
+            ("GET", re.compile(r"/probes/(\d+)/$"), self._get_probe),
+            ("GET", re.compile(r"/credits/$"), lambda params, body: (200, self.credits)),
+        ]
+
+    # --- Seeding and failure injection ---
+
+    def add_measurement(self, definition: Dict[str, Any], **fields: Any) -> int:
+        """Add a running measurement built from an Atlas definition (type, target, ...). Returns its ID."""
+        with self._lock:
+            measurement_id = self._next_id
+            self._next_id += 1
+            now = int(time.time())
+            self.measurements[measurement_id] = {
+                "id": measurement_id, "af": 4, "is_oneoff": False, "interval": None, "description": "",
+                "tags": [], "start_time": now, "stop_time": None, "probes_requested": None,
+                "participant_count": None, "status": dict(STATUS_ONGOING), **definition, **fields
+            }
+            self.results.setdefault(measurement_id, [])
+            return measurement_id
+
+    def add_results(self, measurement_id: int, results: Iterable[Dict[str, Any]]) -> None:
+        """Publish raw results of a measurement; msm_id is filled in where missing."""
+        with self._lock:
+            stored = self.results.setdefault(measurement_id, [])
+            stored.extend(dict(result, msm_id=result.get("msm_id", measurement_id)) for result in results)
+            stored.sort(key=lambda result: result.get("timestamp", 0))
+
+    def fail_next(self, status_code: int, body: Any = None, headers: Optional[Dict[str, str]] = None,
+                  times: int = 1) -> None:
+        """Answer the next `times` requests, whatever they are, with this error response."""
+        with self._lock:
+            self._failures.extend([(status_code, body, dict(headers or {}))] * times)
+
+    # --- AtlasAPI ---
+
+    def request(self, method: str, url: str, params: Optional[Dict[str, Any]] = None, json: Any = None,
+                **kwargs: Any) -> requests.Response:
+        parsed = urlparse(url)
+        query = dict(parse_qsl(parsed.query))
+        query.update({key: ",".join(map(str, value)) if isinstance(value, (list, tuple)) else value
+                      for key, value in (params or {}).items() if value is not None})
+        method = method.upper()
+        with self._lock:
+            self.requests.append((method, parsed.path, query, json))
+            if self._failures:
+                return _response(url, *self._failures.popleft())
+            for route_method, pattern, handler in self._routes:
+                match = pattern.search(parsed.path)
+                if match and route_method == method:
+                    status_code, body = handler(*(int(group) for group in match.groups()), query, json)
+                    response = _response(url, status_code, body)
+                    if method == "GET" and status_code == 200:
+                        etag = f'"{hashlib.sha1(response.content).hexdigest()}"'
+                        response.headers["ETag"] = etag
+                        if (kwargs.get("headers") or {}).get("If-None-Match") == etag:
+                            return _response(url, 304, headers={"ETag": etag})
+                    return response
+        if any(pattern.search(parsed.path) for _, pattern, _ in self._routes):
+            return _response(url, 405, _error(405, "Method Not Allowed", f'Method "{method}" not allowed.'))
+        return _response(url, 404, _error(404, "Not Found", "Not found."))
+
+    def close(self) -> None:
+        pass
+
+    # --- Endpoints ---
+
+    def _measurement(self, measurement_id: int) -> Optional[Dict[str, Any]]:
+        return self.measurements.get(measurement_id)
+
+    def _validate(self, params, body) -> Tuple[int, Any]:
+        errors = self._definition_errors(body)
+        return (400, _error(400, "Bad Request", "There was a problem with your request", errors)) if errors \
+            else (200, {})
+
+    @staticmethod
+    def _definition_errors(body: Any) -> List[Dict[str, Any]]:
+        if not isinstance(body, dict) or not body.get("definitions"):
+            return [{"source": {"pointer": "/definitions"}, "detail": "This field is required."}]
+        errors = []
+        for index, definition in enumerate(body["definitions"]):
+            # DNS through each probe's own resolver has no target
+            required = ("type",) if definition.get("use_probe_resolver") else ("type", "target")
+            for field in required:
+                if not definition.get(field):
+                    errors.append({"source": {"pointer": f"/definitions/{index}/{field}"},
+                                   "detail": "This field is required."})
+        if not body.get("probes"):
+            errors.append({"source": {"pointer": "/probes"}, "detail": "This field is required."})
+        return errors
+
+    def _create(self, params, body) -> Tuple[int, Any]:
+        errors = self._definition_errors(body)
+        if errors:
+            return 400, _error(400, "Bad Request", "There was a problem with your request", errors)
+        requested = sum(probe.get("requested") or 0 for probe in body["probes"])
+        shared = {key: body[key] for key in ("start_time", "stop_time", "is_oneoff") if body.get(key) is not None}
+        ids = [self.add_measurement(definition, probes_requested=requested or None, **shared)
+               for definition in body["definitions"]]
+        return 201, {"measurements": ids}
+
+    def _list_measurements(self, params, body) -> Tuple[int, Any]:
+        return 200, _page(_filtered(self.measurements.values(), params))
+
+    def _get_measurement(self, measurement_id, params, body) -> Tuple[int, Any]:
+        measurement = self._measurement(measurement_id)
+        if measurement is None:
+            return 404, _error(404, "Not Found", "Not found.")
+        if "probes" in params.get("optional_fields", "") and "probes" not in measurement:
+            probe_ids = sorted({result.get("prb_id") for result in self.results.get(measurement_id, [])
+                                if result.get("prb_id") is not None})
+            measurement = dict(measurement, probes=[{"id": probe_id} for probe_id in probe_ids])
+        return 200, measurement
+
+    def _stop(self, measurement_id, params, body) -> Tuple[int, Any]:
+        measurement = self._measurement(measurement_id)
+        if measurement is None:
+            return 404, _error(404, "Not Found", "Not found.")
+        if measurement["status"]["id"] != STATUS_ONGOING["id"]:
+            return 400, _error(400, "Bad Request", "This measurement is not running")
+        measurement.update(status=dict(STATUS_STOPPED), stop_time=int(time.time()))
+        return 204, None
+
+    def _update(self, measurement_id, params, body) -> Tuple[int, Any]:
+        measurement = self._measurement(measurement_id)
+        if measurement is None:
+            return 404, _error(404, "Not Found", "Not found.")
+        measurement.update(body or {})
+        return 200, measurement
+
+    def _results(self, measurement_id, params, body) -> Tuple[int, Any]:
+        if self._measurement(measurement_id) is None:
+            return 404, _error(404, "Not Found", "Not found.")
+        start, stop = int(params.get("start", 0)), int(params.get("stop", 2 ** 63))
+        probe_ids = _id_set(params.get("probe_ids"))
+        return 200, [result for result in self.results.get(measurement_id, [])
+                     if start <= result.get("timestamp", 0) <= stop
+                     and (probe_ids is None or result.get("prb_id") in probe_ids)]
+
+    def _latest(self, measurement_id, params, body) -> Tuple[int, Any]:
+        status_code, results = self._results(measurement_id, {"probe_ids": params.get("probe_ids")}, body)
+        if status_code != 200:
+            return status_code, results
+        latest = {result.get("prb_id"): result for result in results}
+        return 200, list(latest.values())
+
+    def _status_check(self, measurement_id, params, body) -> Tuple[int, Any]:
+        measurement = self._measurement(measurement_id)
+        if measurement is None or measurement.get("type") != "ping":
+            return 404, _error(404, "Not Found", "Status checks are only available for ping measurements")
+        max_loss = float(params.get("max_packet_loss", 95))
+        probes = {}
+        for result in self._latest(measurement_id, {}, None)[1]:
+            sent, received = result.get("sent") or 0, result.get("rcvd") or 0
+            loss = 100.0 * (sent - received) / sent if sent else None
+            alert = loss is not None and loss > max_loss
+            probes[str(result.get("prb_id"))] = {"alert": alert, "last": result.get("avg"),
+                                                 "last_packet_loss": loss,
+                                                 "alert_reasons": ["loss"] if alert else []}
+        total = sum(probe["alert"] for probe in probes.values())
+        return 200, {"global_alert": bool(probes) and total == len(probes), "total_alerts": total,
+                     "probes": probes}
+
+    def _participation(self, measurement_id, params, body) -> Tuple[int, Any]:
+        if self._measurement(measurement_id) is None:
+            return 404, _error(404, "Not Found", "Not found.")
+        self.participation_requests.setdefault(measurement_id, []).extend(body or [])
+        return 201, {"request_ids": list(range(1, len(body or []) + 1))}
+
+    def _list_probes(self, params, body) -> Tuple[int, Any]:
+        return 200, _page(_filtered(self.probes.values(), params))
+
+    def _get_probe(self, probe_id, params, body) -> Tuple[int, Any]:
+        probe = self.probes.get(probe_id)
+        return (200, probe) if probe is not None else (404, _error(404, "Not Found", "Not found."))
+
+
+def _error(status: int, title: str, detail: str, errors: Optional[List[Dict[str, Any]]] = None) -> Dict[str, Any]:
+    """An error body shaped like Atlas's."""
+    error: Dict[str, Any] = {"status": status, "title": title, "detail": detail}
+    if errors:
+        error["errors"] = errors
+    return {"error": error}
+
+
+def _page(items: List[Dict[str, Any]]) -> Dict[str, Any]:
+    return {"count": len(items), "next": None, "previous": None, "results": items}
+
+
+def _id_set(value: Any) -> Optional[set]:
+    if value in (None, ""):
+        return None
+    return {int(item) for item in str(value).split(",") if item.strip()}
+
+
+def _matches(item: Dict[str, Any], key: str, value: Any) -> bool:
+    field, _, op = key.partition("__")
+    if field not in item:
+        return True
+    actual = item[field]
+    if isinstance(actual, dict) and "id" in actual:
+        actual = actual["id"]  # statuses filter by their ID
+    if op == "in":
+        return str(actual) in {part.strip() for part in str(value).split(",")}
+    if op == "contains":
+        return str(value).lower() in str(actual).lower()
+    if isinstance(actual, list):
+        return str(value) in {str(element.get("slug", element)) if isinstance(element, dict) else str(element)
+                              for element in actual}
+    if isinstance(actual, bool):
+        return str(value).lower() in (str(actual).lower(), str(int(actual)))
+    return str(actual) == str(value)
+
+
+def _filtered(items: Iterable[Dict[str, Any]], params: Dict[str, Any]) -> List[Dict[str, Any]]:
+    filters = {key: value for key, value in params.items() if key not in _NON_FILTER_PARAMS}
+    return [item for item in items if all(_matches(item, key, value) for key, value in filters.items())]
+
+
+def _response(url: str, status_code: int, body: Any = None, headers: Optional[Dict[str, str]] = None
+              ) -> requests.Response:
+    """A real requests.Response carrying body as JSON, readable whole or through iter_content."""
+    response = requests.Response()
+    response.status_code = status_code
+    response.url = url
+    response.headers = CaseInsensitiveDict(headers or {})
+    response.encoding = "utf-8"
+    response._content = b"" if body is None else json.dumps(body).encode()
+    response._content_consumed = True
+    if body is not None:
+        response.headers.setdefault("Content-Type", "application/json")
+    return response
@@ -0,0 +1,263 @@
+import hashlib
+import json
+import os
+import re
+import tempfile
+import threading
+import time
+from pathlib import Path
+from typing import Any, BinaryIO, Dict, Optional, Tuple
+from urllib.parse import parse_qs, urlparse
+import requests
+from requests.structures import CaseInsensitiveDict
+from .atlas_api import AtlasAPI
+from .logger import logger
+
+# Endpoints whose responses are cached: measurement metadata and result pages
+CACHEABLE_PATH = re.compile(r"/measurements/\d+/(results/)?$")
+
+# Total size of the cached bodies kept before the least recently used are evicted
+DEFAULT_MAX_CACHE_BYTES = 256 * 1024 * 1024
+
+# Headers describing the stored body, which is kept decoded rather than as sent
+_BODY_HEADERS = {"content-encoding", "content-length", "transfer-encoding"}
+
+# Bytes read at a time while storing or serving a body
+_CHUNK_SIZE = 64 * 1024
+
+
+class _CachedBody:
+    """Raw stream of a cached body for requests.Response, closing the file once read to the end."""
+
+    def __init__(self, file: BinaryIO):
+        self._file = file
+
+    def read(self, size: int = -1, **kwargs: Any) -> bytes:
+        if self._file.closed:
+            return b""
+        data = self._file.read(size)
+        if not data:
+            self._file.close()
+        return data
+
+    def close(self) -> None:
+        self._file.close()
+
+
+class CachingAtlasAPI:
+    """AtlasAPI that revalidates measurement metadata and result pages instead of downloading them again.
+
+    Wraps another AtlasAPI. GET responses of the cacheable endpoints that
+    carry an ETag or Last-Modified are stored under directory, and the next
+    identical request (same URL, query and API key) is sent with
+    If-None-Match / If-Modified-Since. When Atlas answers 304 Not Modified
+    the stored body is served as a 200, so callers cannot tell the
+    difference; rate-limit headers of the 304 are kept. Bodies are written
+    to disk while they download and streamed from it, so large result
+    pages never sit in memory.
+
+    Result pages are only cached for a window that has ended (a stop in the
+    past): a window reaching to now is asked for with a different stop next
+    time, so its entry would never be used again.
+
+    Each entry is one file, replaced atomically, so several threads or
+    processes may share a directory. Entries are replaced when the data
+    changes, and once the entries together exceed max_bytes the least
+    recently used are deleted. clear() deletes them all. hits and stores
+    count revalidated and stored responses.
+    """
+
+    def __init__(self, api: AtlasAPI, directory, max_bytes: int = DEFAULT_MAX_CACHE_BYTES):
+        self.api = api
+        self.directory = Path(directory)
+        self.max_bytes = max_bytes
+        self.hits = 0
+        self.stores = 0
+        self._lock = threading.Lock()
+
+    def request(self, method: str, url: str, **kwargs: Any) -> requests.Response:
+        if method.upper() != "GET" or not CACHEABLE_PATH.search(urlparse(url).path):
+            return self.api.request(method, url, **kwargs)
+        prepared_url = requests.Request("GET", url, params=kwargs.get("params")).prepare().url
+        if not _stable_query(prepared_url):
+            return self.api.request(method, url, **kwargs)
+
+        path = self._entry_path(prepared_url, kwargs)
+        entry = self._read_meta(path)
+        headers = dict(kwargs.pop("headers", None) or {})
+        conditional = dict(headers)
+        if entry is not None:
+            if entry.get("etag"):
+                conditional["If-None-Match"] = entry["etag"]
+            if entry.get("last_modified"):
+                conditional["If-Modified-Since"] = entry["last_modified"]
+
+        response = self.api.request(method, url, headers=conditional, **kwargs)
+        if response.status_code == 304:
+            cached = self._cached_response(path, url, response, kwargs.get("stream", False))
+            if cached is not None:
+                _touch(path)
+                with self._lock:
+                    self.hits += 1
+                logger.debug(f"Not modified, served from the HTTP cache: {url}")
+                return cached
+            # The entry vanished since it was read; ask for the full response
+            response.close()
+            return self.api.request(method, url, headers=headers, **kwargs)
+        if response.status_code == 200 and (response.headers.get("ETag") or response.headers.get("Last-Modified")):
+            return self._store(path, url, response, kwargs.get("stream", False))
+        return response
+
+    def close(self) -> None:
+        close = getattr(self.api, "close", None)
+        if close is not None:
+            close()
+
+    def clear(self) -> int:
+        """Delete every cached response. Returns the number deleted."""
+        removed = 0
+        for path in self.directory.glob("*.cache"):
+            try:
+                path.unlink()
+                removed += 1
+            except OSError as e:
+                logger.warning(f"Could not delete HTTP cache entry {path}: {e}")
+        return removed
+
+    def _entry_path(self, prepared_url: str, kwargs: Dict[str, Any]) -> Path:
+        # The key includes the API key: private measurements differ per account
+        authorization = (kwargs.get("headers") or {}).get("Authorization", "")
+        digest = hashlib.sha256(f"{prepared_url}\n{authorization}".encode()).hexdigest()
+        return self.directory / f"{digest}.cache"
+
+    def _evict(self, keep: Path) -> None:
+        """Delete the least recently used entries other than keep until the rest fit in max_bytes."""
+        entries = []
+        for path in self.directory.glob("*.cache"):
+            if path == keep:
+                continue
+            try:
+                stat = path.stat()
+            except OSError:
+                continue  # replaced or deleted by another process meanwhile
+            entries.append((stat.st_mtime, stat.st_size, path))
+        total = sum(size for _, size, _ in entries) + keep.stat().st_size
+        for _, size, path in sorted(entries):
+            if total <= self.max_bytes:
+                break
+            try:
+                path.unlink()
+            except FileNotFoundError:
+                pass
+            except OSError as e:
+                logger.warning(f"Could not evict HTTP cache entry {path}: {e}")
+                continue
+            total -= size
+            logger.debug(f"Evicted HTTP cache entry {path}")
+
+    @staticmethod
+    def _read_meta(path: Path) -> Optional[Dict[str, Any]]:
+        try:
+            with open(path, "rb") as f:
+                return json.loads(f.readline())
+        except FileNotFoundError:
+            return None
+        except (OSError, ValueError) as e:
+            logger.warning(f"Ignoring unreadable HTTP cache entry {path}: {e}")
+            return None
+
+    @staticmethod
+    def _open_entry(path: Path) -> Optional[Tuple[Dict[str, Any], BinaryIO]]:
+        """The entry's metadata and its file positioned at the body, or None when it is gone or unreadable."""
+        try:
+            f = open(path, "rb")
+        except OSError:
+            return None
+        try:
+            return json.loads(f.readline()), f
+        except ValueError:
+            f.close()
+            return None
+
+    def _cached_response(self, path: Path, url: str, not_modified: requests.Response,
+                         stream: bool) -> Optional[requests.Response]:
+        opened = self._open_entry(path)
+        if opened is None:
+            return None
+        meta, body = opened
+        headers = CaseInsensitiveDict(meta.get("headers") or {})
+        headers.update({name: value for name, value in not_modified.headers.items()
+                        if name.lower() not in _BODY_HEADERS})
+        not_modified.close()
+        return _response(url, headers, body, stream)
+
+    def _store(self, path: Path, url: str, response: requests.Response, stream: bool) -> requests.Response:
+        headers = {name: value for name, value in response.headers.items() if name.lower() not in _BODY_HEADERS}
+        meta = {"url": url, "etag": response.headers.get("ETag"),
+                "last_modified": response.headers.get("Last-Modified"), "headers": headers,
+                "stored_at": int(time.time())}
+        try:
+            self.directory.mkdir(parents=True, exist_ok=True)
+            fd, tmp_name = tempfile.mkstemp(dir=self.directory, suffix=".tmp")
+        except OSError as e:
+            logger.warning(f"HTTP cache {self.directory} is not writable, not caching {url}: {e}")
+            return response
+        try:
+            with os.fdopen(fd, "wb") as f:
+                f.write(json.dumps(meta).encode() + b"\n")
+                for chunk in response.iter_content(chunk_size=_CHUNK_SIZE):
+                    f.write(chunk)
+            os.replace(tmp_name, path)
+        except BaseException:
+            # The download failed part way; leave the previous entry in place
+            Path(tmp_name).unlink(missing_ok=True)
+            raise
+        finally:
+            response.close()
+        with self._lock:
+            self.stores += 1
+        try:
+            self._evict(path)
+        except OSError as e:
+            logger.warning(f"Could not evict from the HTTP cache {self.directory}: {e}")
+
+        opened = self._open_entry(path)
+        if opened is None:
+            raise requests.ConnectionError(f"HTTP cache entry for {url} disappeared while being stored")
+        return _response(url, CaseInsensitiveDict(headers), opened[1], stream, response.status_code)
+
+
+def _stable_query(prepared_url: str) -> bool:
+    """Whether a request asks for the same data every time: not a result page whose window reaches to now."""
+    parsed = urlparse(prepared_url)
+    if not parsed.path.endswith("/results/"):
+        return True
+    stop = parse_qs(parsed.query).get("stop")
+    try:
+        return bool(stop) and float(stop[-1]) < time.time()
+    except ValueError:
+        return False
+
+
+def _touch(path: Path) -> None:
+    """Mark an entry as recently used, so eviction keeps it longer."""
+    try:
+        os.utime(path)
+    except OSError:
+        pass
+
+
+def _response(url: str, headers: CaseInsensitiveDict, body: BinaryIO, stream: bool,
+              status_code: int = 200) -> requests.Response:
+    """A response whose body is read from an open cache entry: lazily when streaming, else at once."""
+    response = requests.Response()
+    response.status_code = status_code
+    response.url = url
+    response.headers = headers
+    response.encoding = requests.utils.get_encoding_from_headers(headers)
+    response.raw = _CachedBody(body)
+    if not stream:
+        response._content = response.raw.read() or b""
+        response.raw.close()
+        response._content_consumed = True
+    return response
@@ -0,0 +1,84 @@
+import sys
+import threading
+from typing import Any, Callable, Iterable, Optional, TextIO
+from urllib.parse import parse_qsl, urlencode, urlsplit, urlunsplit
+import requests
+from .streaming import GZIP_MAGIC
+
+REDACTED = "[REDACTED]"
+
+# Headers whose values are credentials and never dumped
+REDACTED_HEADERS = {"authorization", "proxy-authorization", "cookie", "set-cookie", "x-api-key"}
+
+# Query parameters that carry credentials (Atlas also accepts ?key=)
+REDACTED_PARAMS = {"key", "api_key", "token"}
+
+# Bodies longer than this are cut short in the dump
+MAX_DUMPED_BODY = 64 * 1024
+
+
+def _redact_url(url: str) -> str:
+    parts = urlsplit(url)
+    if not parts.query:
+        return url
+    query = [(name, REDACTED if name.lower() in REDACTED_PARAMS else value)
+             for name, value in parse_qsl(parts.query, keep_blank_values=True)]
+    return urlunsplit(parts._replace(query=urlencode(query, safe="[]")))
+
+
+def _format_headers(headers) -> Iterable[str]:
+    for name, value in (headers or {}).items():
+        yield f"{name}: {REDACTED if name.lower() in REDACTED_HEADERS else value}"
+
+
+def _format_body(body: Any) -> str:
+    if body is None or body == b"" or body == "":
+        return "<empty body>"
+    if isinstance(body, bytes):
+        if body[:2] == GZIP_MAGIC:
+            return f"<{len(body)} bytes of gzip data>"
+        body = body.decode("utf-8", errors="replace")
+    body = str(body)
+    if len(body) > MAX_DUMPED_BODY:
+        return body[:MAX_DUMPED_BODY] + f"\n<truncated, {len(body)} characters in total>"
+    return body
+
+
+def format_exchange(response: requests.Response, secrets: Iterable[str] = (), streamed: bool = False) -> str:
+    """Render a request and its response for debugging, with credentials redacted.
+
+    Credential headers and query parameters are replaced, and any of the
+    given secrets (such as the API key) is scrubbed wherever else it appears.
+    Streamed response bodies are not read, since that would consume them.
+    """
+    request = response.request
+    lines = [f"> {request.method} {_redact_url(request.url)}"]
+    lines += [f"> {line}" for line in _format_headers(request.headers)]
+    lines += [f"> {line}" for line in _format_body(request.body).splitlines()]
+    lines.append(f"< {response.status_code} {response.reason or ''}".rstrip())
+    lines += [f"< {line}" for line in _format_headers(response.headers)]
+    body = "<streamed body not shown>" if streamed else _format_body(response.content)
+    lines += [f"< {line}" for line in body.splitlines()]
+
+    dump = "\n".join(lines) + "\n"
+    for secret in secrets:
+        if secret:
+            dump = dump.replace(secret, REDACTED)
+    return dump
+
+
+def http_debug_hook(secrets: Iterable[str] = (), stream: Optional[TextIO] = None
+                    ) -> Callable[..., requests.Response]:
+    """Build a requests response hook that dumps every exchange to stream (stderr by default)."""
+    secrets = [secret for secret in secrets if secret]
+    lock = threading.Lock()
+
+    def hook(response: requests.Response, *args, **kwargs) -> requests.Response:
+        dump = format_exchange(response, secrets, streamed=bool(kwargs.get("stream")))
+        out = stream or sys.stderr
+        with lock:
+            out.write(dump)
+            out.flush()
+        return response
+
+    return hook
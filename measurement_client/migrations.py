@@ -0,0 +1,113 @@
+import copy
+from typing import Dict, Any, List, Tuple, Callable
+from .logger import logger
+
+# Config schema version written by this Sintra build. Configs without a
+# version field predate versioning and are treated as version 1.
+CURRENT_CONFIG_VERSION = 2
+
+# Oldest config version this build can still load (older ones need migrate)
+MIN_SUPPORTED_CONFIG_VERSION = 1
+
+# Atlas probe source type -> Sintra probes key
+ATLAS_SOURCE_KEYS = {"country": "country", "area": "area", "probes": "ids"}
+
+
+def config_version(config: Dict[str, Any]) -> int:
+    """Return the config's schema version, defaulting to 1 when it has none."""
+    version = config.get("version", 1)
+    if not isinstance(version, int) or isinstance(version, bool) or version < 1:
+        raise ValueError(f"Invalid config version '{version}'. Must be a positive integer")
+    return version
+
+
+def check_config_version(config: Dict[str, Any], config_path: str) -> int:
+    """Gate a loaded config on its schema version.
+
+    Versions newer than this build are rejected, versions older than the
+    minimum supported are rejected with a pointer to migrate, and older but
+    supported versions load with a warning. Returns the version.
+    """
+    version = config_version(config)
+    if version > CURRENT_CONFIG_VERSION:
+        raise ValueError(
+            f"Config version {version} in {config_path} is newer than this Sintra supports "
+            f"(max {CURRENT_CONFIG_VERSION}); upgrade Sintra"
+        )
+    if version < MIN_SUPPORTED_CONFIG_VERSION:
+        raise ValueError(
+            f"Config version {version} in {config_path} is no longer supported; "
+            f"run 'sintra migrate --config {config_path} --in-place'"
+        )
+    if version < CURRENT_CONFIG_VERSION:
+        logger.warning(
+            f"Config {config_path} is version {version} (current is {CURRENT_CONFIG_VERSION}); "
+            f"run 'sintra migrate --config {config_path} --in-place' to upgrade it"
+        )
+    return version
+
+
+def _atlas_probe_sources(config: Dict[str, Any]) -> List[str]:
+    """v1 -> v2: rewrite Atlas-style probe sources into Sintra's probes keys.
+
+    Configs copied from Atlas API payloads use {type, value, requested},
+    which Sintra silently ignored (falling back to worldwide probes).
+    """
+    changes = []
+    for i, measurement in enumerate(config.get("measurements") or []):
+        probes = measurement.get("probes")
+        if not isinstance(probes, dict) or "type" not in probes:
+            continue
+
+        source_type = probes.pop("type")
+        value = probes.pop("value", None)
+        requested = probes.pop("requested", None)
+        key = ATLAS_SOURCE_KEYS.get(source_type)
+        if key is None:
+            probes.update({"type": source_type, "value": value, "requested": requested})
+            changes.append(f"measurements[{i}].probes: unsupported source type '{source_type}' left unchanged")
+            continue
+
+        if key == "ids":
+            ids = value.split(",") if isinstance(value, str) else list(value or [])
+            probes["ids"] = [int(probe_id) for probe_id in ids]
+        else:
+            probes[key] = value
+            if requested is not None:
+                probes.setdefault("count", requested)
+        changes.append(f"measurements[{i}].probes: type/value/requested -> {key}"
+                       + (" + count" if key != "ids" and requested is not None else ""))
+    return changes
+
+
+# Ordered migration steps keyed by the version they upgrade from. Each step
+# mutates the config in place and returns human-readable changes.
+MIGRATIONS: List[Tuple[int, Callable[[Dict[str, Any]], List[str]]]] = [
+    (1, _atlas_probe_sources),
+]
+
+
+def migrate_config(config: Dict[str, Any]) -> Tuple[Dict[str, Any], List[str]]:
+    """Apply every migration step newer than the config's version.
+
+    Returns the migrated copy (the input is not modified) and the list of
+    changes made. Raises ValueError for versions newer than this build.
+    """
+    migrated = copy.deepcopy(config or {})
+    version = config_version(migrated)
+    if version > CURRENT_CONFIG_VERSION:
+        raise ValueError(
+            f"Config version {version} is newer than the supported version {CURRENT_CONFIG_VERSION}; upgrade Sintra"
+        )
+
+    changes = []
+    for from_version, step in MIGRATIONS:
+        if version <= from_version:
+            changes.extend(f"v{from_version}->v{from_version + 1} {change}" for change in step(migrated))
+
+    if version != CURRENT_CONFIG_VERSION or "version" not in migrated:
+        changes.append(f"version: {version} -> {CURRENT_CONFIG_VERSION}")
+    # Keep the version first so it is easy to spot in the file
+    migrated.pop("version", None)
+    migrated = {"version": CURRENT_CONFIG_VERSION, **migrated}
+    return migrated, changes
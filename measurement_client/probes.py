@@ -0,0 +1,442 @@
+import copy
+import json
+import re
+import threading
+import time
+from datetime import datetime, timezone
+from pathlib import Path
+from typing import Dict, Any, Iterable, List, Callable, Optional, Tuple
+import yaml
+from .logger import logger
+
+# RIPE Atlas probe status IDs
+PROBE_STATUS_CONNECTED = 1
+PROBE_STATUSES = {"never-connected": 0, "connected": PROBE_STATUS_CONNECTED, "disconnected": 2, "abandoned": 3}
+
+PROBE_QUERY_STRATEGIES = ["best"]
+
+# Largest 32-bit autonomous system number
+MAX_ASN = 4294967295
+
+# Degrees probe coordinates are rounded to when grouping probes by city for max_per_city
+CITY_GRID_DEGREES = 0.1
+
+# System tag Atlas gives probes whose IPv6 connectivity has been verified
+IPV6_PROBE_TAG = "system-ipv6-works"
+
+# Participation below this share of the requested probes is worth a warning
+PARTICIPATION_WARN_RATIO = 0.8
+
+# Keys a probe-set library entry may hold; each entry defines exactly one
+PROBE_SET_KEYS = ["probes", "probe_query"]
+
+# Seconds cached probe metadata stays valid before it is fetched again
+DEFAULT_PROBE_CACHE_TTL = 24 * 3600
+
+# /probes/ query parameters ProbeCache.search can answer locally
+CACHE_SEARCH_FILTERS = {"country_code", "asn", "status", "tags", "page_size"}
+
+
+def _is_connected(probe: Dict[str, Any]) -> bool:
+    status = probe.get("status") or {}
+    return status.get("id") == PROBE_STATUS_CONNECTED
+
+
+def score_by_uptime(probe: Dict[str, Any]) -> float:
+    """Prefer connected probes with the most accumulated uptime."""
+    if not _is_connected(probe):
+        return float("-inf")
+    return float(probe.get("total_uptime") or 0)
+
+
+def score_by_connected_since(probe: Dict[str, Any]) -> float:
+    """Prefer probes that have been continuously connected the longest."""
+    if not _is_connected(probe):
+        return float("-inf")
+    since = probe.get("status_since")
+    if since is None:
+        return 0.0
+    return datetime.now(timezone.utc).timestamp() - float(since)
+
+
+def score_by_last_seen(probe: Dict[str, Any]) -> float:
+    """Prefer probes that were seen most recently."""
+    return float(probe.get("last_connected") or 0)
+
+
+# Scoring criteria selectable via probe_query.score; higher scores rank first
+PROBE_SCORERS: Dict[str, Callable[[Dict[str, Any]], float]] = {
+    "uptime": score_by_uptime,
+    "connected_since": score_by_connected_since,
+    "last_seen": score_by_last_seen
+}
+
+
+def probe_asn(probe: Dict[str, Any]) -> Optional[int]:
+    """The network a probe is hosted in: its IPv4 ASN, else its IPv6 ASN."""
+    return probe.get("asn_v4") or probe.get("asn_v6")
+
+
+def probe_city(probe: Dict[str, Any]) -> Optional[Tuple[int, int]]:
+    """Approximate a probe's city by its coordinates rounded to CITY_GRID_DEGREES.
+
+    Atlas records no city for probes, so probes within the same grid cell
+    (about 10 km across) count as one city. None without coordinates.
+    """
+    latitude, longitude = probe.get("latitude"), probe.get("longitude")
+    if latitude is None or longitude is None:
+        # /probes/ objects carry their location as a GeoJSON point
+        coordinates = (probe.get("geometry") or {}).get("coordinates") or [None, None]
+        longitude, latitude = coordinates[:2]
+    if latitude is None or longitude is None:
+        return None
+    return round(latitude / CITY_GRID_DEGREES), round(longitude / CITY_GRID_DEGREES)
+
+
+# Diversity limits selectable via probe_query, each capping how many selected
+# probes may share the value of its key; probes without a value are not capped
+PROBE_DIVERSITY_KEYS: Dict[str, Callable[[Dict[str, Any]], Any]] = {
+    "max_per_asn": probe_asn,
+    "max_per_city": probe_city
+}
+
+
+def rank_probes(probes: List[Dict[str, Any]], count: int, score: str = "uptime",
+                limits: Optional[Dict[str, int]] = None) -> List[int]:
+    """Return the IDs of the top `count` probes by the named scoring criterion.
+
+    Probes scored -inf (e.g. disconnected probes for uptime scoring) are never
+    selected. Ties keep the order the API returned them in. limits maps
+    PROBE_DIVERSITY_KEYS to the most probes that may share one value, so a
+    probe is skipped for the next best once its network or city is full;
+    probes without a value are not limited.
+    """
+    if score not in PROBE_SCORERS:
+        raise ValueError(f"Unknown probe score '{score}'. Must be one of: {', '.join(PROBE_SCORERS)}")
+    unknown = set(limits or {}) - set(PROBE_DIVERSITY_KEYS)
+    if unknown:
+        raise ValueError(f"Unknown probe diversity limit(s): {', '.join(sorted(unknown))}")
+
+    scorer = PROBE_SCORERS[score]
+    scored = [(scorer(probe), probe) for probe in probes if probe.get("id") is not None]
+    scored = [item for item in scored if item[0] != float("-inf")]
+    scored.sort(key=lambda item: item[0], reverse=True)
+
+    selected: List[int] = []
+    used: Dict[Tuple[str, Any], int] = {}
+    for _, probe in scored:
+        if len(selected) == count:
+            break
+        keys = [(name, PROBE_DIVERSITY_KEYS[name](probe)) for name in limits or {}]
+        keys = [key for key in keys if key[1] is not None]
+        if any(used.get(key, 0) >= limits[key[0]] for key in keys):
+            continue
+        for key in keys:
+            used[key] = used.get(key, 0) + 1
+        selected.append(probe["id"])
+    return selected
+
+
+def probe_metadata(probe: Dict[str, Any]) -> Dict[str, Any]:
+    """Extract hardware version, anchor flag and system tags from a /probes/ object.
+
+    Atlas exposes the hardware generation only as a system tag such as
+    'system-v3' (anchors carry 'system-anchor').
+    """
+    system_tags = [
+        tag.get("slug") for tag in probe.get("tags") or []
+        if isinstance(tag, dict) and str(tag.get("slug", "")).startswith("system-")
+    ]
+    versions = [slug[len("system-"):] for slug in system_tags if re.fullmatch(r"system-v\d+", slug)]
+    if versions:
+        hardware_version = versions[0]
+    else:
+        hardware_version = "anchor" if "system-anchor" in system_tags else None
+    return {
+        "hardware_version": hardware_version,
+        "is_anchor": probe.get("is_anchor"),
+        "system_tags": system_tags
+    }
+
+
+def probe_summary(probe: Dict[str, Any]) -> Dict[str, Any]:
+    """The fields of a /probes/ object worth showing when picking probes by hand."""
+    return {
+        "id": probe.get("id"),
+        "country_code": probe.get("country_code"),
+        "asn_v4": probe.get("asn_v4"),
+        "asn_v6": probe.get("asn_v6"),
+        "status": (probe.get("status") or {}).get("name"),
+        "is_anchor": bool(probe.get("is_anchor")),
+        "description": probe.get("description"),
+        "tags": [tag.get("slug") for tag in probe.get("tags") or [] if isinstance(tag, dict)]
+    }
+
+
+def set_definition_probe_ids(config: Dict[str, Any], index: int, probe_ids: List[int]) -> Dict[str, Any]:
+    """Point measurement definition index of a create config at explicit probe IDs.
+
+    Any other probe selection of the definition (probes, probe_query,
+    probe_set_ref) is replaced. Raises ValueError if there is no such
+    definition.
+    """
+    measurements = config.get("measurements") or []
+    if not 0 <= index < len(measurements):
+        raise ValueError(f"Config has no measurement definition {index} ({len(measurements)} defined)")
+    definition = measurements[index]
+    for key in ("probe_query", "probe_set_ref"):
+        definition.pop(key, None)
+    definition["probes"] = {"ids": list(probe_ids)}
+    return config
+
+
+def parse_asn(value: Any) -> int:
+    """Parse an autonomous system number given as 15169, "15169" or "AS15169". Raises ValueError."""
+    text = str(value).strip()
+    if isinstance(value, bool) or not text:
+        raise ValueError(f"invalid ASN: {value}")
+    if text[:2].upper() == "AS":
+        text = text[2:]
+    if not text.isdigit() or not 0 < int(text) <= MAX_ASN:
+        raise ValueError(f"invalid ASN: {value}")
+    return int(text)
+
+
+def probe_tags(value: Any) -> List[str]:
+    """Normalize a probe tag filter given as one tag or a list into a list of lowercase tags."""
+    if not value:
+        return []
+    values = [value] if isinstance(value, str) else value
+    return [str(tag).strip().lower() for tag in values]
+
+
+def probe_query_filters(probe_query: Dict[str, Any], af: int = 4) -> Dict[str, Any]:
+    """Translate a probe_query config block into /probes/ API query parameters.
+
+    For IPv6 (af 6) only probes tagged IPV6_PROBE_TAG are selected.
+    """
+    filters: Dict[str, Any] = {}
+    tags = probe_query.get("tags") or []
+    tags = list(tags) if isinstance(tags, (list, tuple)) else [tags]
+    if af == 6 and IPV6_PROBE_TAG not in tags:
+        tags.append(IPV6_PROBE_TAG)
+    if tags:
+        filters["tags"] = ",".join(tags)
+    if probe_query.get("country"):
+        filters["country_code"] = probe_query["country"]
+    if probe_query.get("asn"):
+        filters["asn"] = probe_query["asn"]
+    geo = probe_query.get("geo")
+    if geo:
+        filters["radius"] = f"{geo['latitude']:g},{geo['longitude']:g}:{geo['radius_km']:g}"
+    filters["status"] = probe_query.get("status", PROBE_STATUS_CONNECTED)
+    return filters
+
+
+def requested_probe_count(processed: Dict[str, Any], saved_info: Optional[Dict[str, Any]] = None) -> Optional[int]:
+    """Return how many probes a measurement asked for, if known.
+
+    Prefers the probes_requested reported by Atlas at fetch time and falls
+    back to the probes section of the config saved at create time.
+    """
+    if processed.get("probes_requested"):
+        return int(processed["probes_requested"])
+
+    probes_config = ((saved_info or {}).get("config") or {}).get("probes") or {}
+    if probes_config.get("ids"):
+        return len(probes_config["ids"])
+    if probes_config.get("count"):
+        return int(probes_config["count"])
+    return None
+
+
+def requested_probe_ids(saved_info: Optional[Dict[str, Any]]) -> Optional[List[int]]:
+    """Return the probe IDs recorded at create time, or None when they are not known.
+
+    IDs are recorded for explicit probes.ids and for probe_query selections
+    (frozen into ids); area/country selections leave the choice to Atlas.
+    """
+    probes_config = ((saved_info or {}).get("config") or {}).get("probes") or {}
+    ids = probes_config.get("ids")
+    if not ids:
+        return None
+    return [int(probe_id) for probe_id in ids]
+
+
+def probe_participation(processed: Dict[str, Any],
+                        saved_info: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+    """Compare requested probes with the probes that actually returned results.
+
+    Participating probes are the distinct probe IDs in the processed result
+    rows. The ratio is None when the requested count is unknown.
+    """
+    requested = requested_probe_count(processed, saved_info)
+    participating = len({r.get("probe_id") for r in processed.get("results", []) if r.get("probe_id") is not None})
+    return {
+        "requested": requested,
+        "participating": participating,
+        "ratio": participating / requested if requested else None
+    }
+
+
+def participation_is_low(participation: Dict[str, Any], warn_ratio: float = PARTICIPATION_WARN_RATIO) -> bool:
+    ratio = participation.get("ratio")
+    return ratio is not None and ratio < warn_ratio
+
+
+def load_probe_sets(path: str) -> Dict[str, Dict[str, Any]]:
+    """Read a probe-set library: a YAML file whose `probe_sets` maps names to definitions.
+
+    Each definition holds either a `probes` or a `probe_query` block, exactly
+    as it would appear in a measurement definition.
+    """
+    with open(path, "r") as f:
+        data = yaml.safe_load(f)
+    probe_sets = data.get("probe_sets") if isinstance(data, dict) else None
+    if not isinstance(probe_sets, dict):
+        raise ValueError(f"Probe-set file {path} must contain a 'probe_sets' mapping")
+
+    for name, definition in probe_sets.items():
+        if (not isinstance(definition, dict) or len(definition) != 1
+                or next(iter(definition)) not in PROBE_SET_KEYS):
+            raise ValueError(
+                f"Probe set '{name}' in {path} must define exactly one of: {', '.join(PROBE_SET_KEYS)}"
+            )
+    return probe_sets
+
+
+def resolve_probe_set_ref(measurement: Dict[str, Any], probe_sets: Optional[Dict[str, Dict[str, Any]]],
+                          index: int) -> Dict[str, Any]:
+    """Return the definition with its probe_set_ref replaced by the named set.
+
+    The set name is kept as `probe_set` so the saved measurement info records
+    where the probes came from. Definitions without a reference are returned as is.
+    """
+    name = measurement.get("probe_set_ref")
+    if name is None:
+        return measurement
+    if probe_sets is None:
+        raise ValueError(f"Measurement {index}: probe_set_ref '{name}' requires a probe-set file (--probe-set-file)")
+    if name not in probe_sets:
+        known = ", ".join(sorted(probe_sets)) or "none"
+        raise ValueError(f"Measurement {index}: Unknown probe set '{name}'. Known sets: {known}")
+    if any(key in measurement for key in PROBE_SET_KEYS):
+        raise ValueError(f"Measurement {index}: Cannot specify 'probe_set_ref' together with 'probes' or 'probe_query'")
+
+    resolved = {key: value for key, value in measurement.items() if key != "probe_set_ref"}
+    resolved.update(copy.deepcopy(probe_sets[name]))
+    resolved["probe_set"] = name
+    return resolved
+
+
+class ProbeCache:
+    """Probe metadata (/probes/ objects) cached on disk by probe ID, with a TTL.
+
+    Entries older than ttl seconds are dropped when the file is read.
+    After a full sync (put with complete=True) probe searches can be answered
+    from the cache too, until the sync itself is older than the TTL.
+    The file is read on first use and written by save(). Safe to share
+    between threads.
+    """
+
+    def __init__(self, path, ttl: float = DEFAULT_PROBE_CACHE_TTL, clock: Callable[[], float] = time.time):
+        if ttl <= 0:
+            raise ValueError("Probe cache TTL must be greater than zero")
+        self.path = Path(path)
+        self.ttl = ttl
+        self.clock = clock
+        self._entries: Optional[Dict[int, Dict[str, Any]]] = None
+        self._synced_at: Optional[float] = None
+        self._lock = threading.RLock()
+
+    def _fresh(self, timestamp: Optional[float]) -> bool:
+        return timestamp is not None and self.clock() - timestamp < self.ttl
+
+    def _load(self) -> Dict[int, Dict[str, Any]]:
+        with self._lock:
+            if self._entries is None:
+                self._read()
+            return self._entries
+
+    def _read(self) -> None:
+        self._entries = {}
+        try:
+            with open(self.path, "r") as f:
+                data = json.load(f)
+        except FileNotFoundError:
+            return
+        except (OSError, ValueError) as e:
+            logger.warning(f"Ignoring unreadable probe cache {self.path}: {e}")
+            return
+
+        self._synced_at = data.get("synced_at") if self._fresh(data.get("synced_at")) else None
+        for probe_id, entry in (data.get("probes") or {}).items():
+            if self._fresh(entry.get("cached_at")):
+                self._entries[int(probe_id)] = entry
+
+    def __len__(self) -> int:
+        return len(self._load())
+
+    def get(self, probe_id: int) -> Optional[Dict[str, Any]]:
+        """The cached /probes/ object for probe_id, or None when missing or expired."""
+        entry = self._load().get(probe_id)
+        if entry is None or not self._fresh(entry["cached_at"]):
+            return None
+        return entry["probe"]
+
+    def put(self, probes: Iterable[Dict[str, Any]], complete: bool = False) -> None:
+        """Cache /probes/ objects. complete marks them as every probe Atlas has (a full sync)."""
+        with self._lock:
+            entries = self._load()
+            now = self.clock()
+            if complete:
+                entries.clear()
+                self._synced_at = now
+            for probe in probes:
+                if probe.get("id") is not None:
+                    entries[int(probe["id"])] = {"cached_at": now, "probe": probe}
+
+    @property
+    def synced(self) -> bool:
+        """Whether a full sync is cached and still within the TTL."""
+        self._load()
+        return self._fresh(self._synced_at)
+
+    def search(self, filters: Dict[str, Any]) -> Optional[List[Dict[str, Any]]]:
+        """Probes matching /probes/ filters, or None when the cache cannot answer.
+
+        Only country_code, asn, status and tags filters are understood, and
+        only a fresh full sync can answer, since otherwise probes may be missing.
+        """
+        if not self.synced or set(filters) - CACHE_SEARCH_FILTERS:
+            return None
+        tags = filters.get("tags")
+        wanted_tags = set(tags.split(",") if isinstance(tags, str) else tags or [])
+
+        with self._lock:
+            entries = list(self._load().values())
+        matches = []
+        for entry in entries:
+            probe = entry["probe"]
+            if "country_code" in filters and probe.get("country_code") != filters["country_code"]:
+                continue
+            if "asn" in filters and int(filters["asn"]) not in (probe.get("asn_v4"), probe.get("asn_v6")):
+                continue
+            if "status" in filters and (probe.get("status") or {}).get("id") != int(filters["status"]):
+                continue
+            if wanted_tags - {tag.get("slug") for tag in probe.get("tags") or [] if isinstance(tag, dict)}:
+                continue
+            matches.append(probe)
+        return sorted(matches, key=lambda probe: probe["id"])
+
+    def save(self) -> None:
+        self.path.parent.mkdir(parents=True, exist_ok=True)
+        with self._lock:
+            data = {
+                "synced_at": self._synced_at,
+                "probes": {str(probe_id): entry for probe_id, entry in sorted(self._load().items())}
+            }
+            tmp = self.path.with_suffix(".tmp")
+            with open(tmp, "w") as f:
+                json.dump(data, f)
+            tmp.replace(self.path)
@@ -1,7 +1,8 @@
 from datetime import datetime
 import statistics
-from typing import Dict, Any, Optional
+from typing import Dict, Any, List, Optional, Callable, Tuple
 from .logger import logger
+from .certificates import decode_certificate_chain
 
 # This module processes results from various types of measurements
 def process_ping_result(result: Dict[str, Any]) -> Dict[str, Any]:
@@ -94,6 +95,293 @@ def process_default_result():
         }
     }
 
+# This function processes the result of a DNS measurement
+# A result holds one answer (result) or, with several resolvers, a resultset;
+# queries that got no answer carry an error instead and count as lost
+def process_dns_result(result: Dict[str, Any]) -> Dict[str, Any]:
+    try:
+        if "resultset" in result:
+            answers = result.get("resultset") or []
+        else:
+            answers = [result]
+        if not answers:
+            logger.warning("Empty DNS results received")
+            return _create_empty_dns_result()
+
+        response_times = []
+        answer_count = 0
+        for answer in answers:
+            response = answer.get("result")
+            if not isinstance(response, dict):
+                continue
+            rt = response.get("rt")
+            if isinstance(rt, (int, float)) and rt >= 0:
+                response_times.append(rt)
+            answer_count += response.get("ANCOUNT", 0) or 0
+
+        failed_queries = len([a for a in answers if not isinstance(a.get("result"), dict)])
+        return {
+            "packet_loss_percentage": failed_queries / len(answers) * 100,
+            "latency_stats": {
+                "min": min(response_times) if response_times else None,
+                "max": max(response_times) if response_times else None,
+                "avg": sum(response_times) / len(response_times) if response_times else None,
+                "median": statistics.median(response_times) if response_times else None
+            },
+            "answer_count": answer_count
+        }
+    except Exception as e:
+        logger.error(f"Error processing DNS result: {e}")
+        return _create_empty_dns_result()
+
+def _create_empty_dns_result() -> Dict[str, Any]:
+    result = process_default_result()
+    result["answer_count"] = 0
+    return result
+
+# This function processes the result of an HTTP measurement
+# Each request carries its response time (rt) and status code (res); requests
+# that got no response carry an err instead and count as lost
+def process_http_result(result: Dict[str, Any]) -> Dict[str, Any]:
+    try:
+        http_requests = result.get("result", [])
+        if not http_requests:
+            logger.warning("Empty HTTP results received")
+            return _create_empty_http_result()
+
+        response_times = [r["rt"] for r in http_requests
+                          if isinstance(r.get("rt"), (int, float)) and r["rt"] >= 0 and "err" not in r]
+        failed_requests = len([r for r in http_requests if "err" in r or r.get("res") is None])
+        return {
+            "packet_loss_percentage": failed_requests / len(http_requests) * 100,
+            "latency_stats": {
+                "min": min(response_times) if response_times else None,
+                "max": max(response_times) if response_times else None,
+                "avg": sum(response_times) / len(response_times) if response_times else None,
+                "median": statistics.median(response_times) if response_times else None
+            },
+            "status_codes": sorted({r["res"] for r in http_requests if r.get("res") is not None})
+        }
+    except Exception as e:
+        logger.error(f"Error processing HTTP result: {e}")
+        return _create_empty_http_result()
+
+def _create_empty_http_result() -> Dict[str, Any]:
+    result = process_default_result()
+    result["status_codes"] = []
+    return result
+
+# This function processes the result of an NTP measurement
+# Each packet carries its round-trip time (rtt) and clock offset in seconds;
+# packets that got no reply carry x: "*" and count as lost. Times are
+# reported in milliseconds like the other summaries
+def process_ntp_result(result: Dict[str, Any]) -> Dict[str, Any]:
+    try:
+        packets = result.get("result", [])
+        if not packets:
+            logger.warning("Empty NTP results received")
+            return _create_empty_ntp_result()
+
+        replies = [p for p in packets if isinstance(p.get("rtt"), (int, float)) and p["rtt"] >= 0]
+        rtts = [p["rtt"] * 1000 for p in replies]
+        offsets = [p["offset"] * 1000 for p in replies if isinstance(p.get("offset"), (int, float))]
+        stratum = result.get("stratum")
+        return {
+            "packet_loss_percentage": (len(packets) - len(replies)) / len(packets) * 100,
+            "latency_stats": {
+                "min": min(rtts) if rtts else None,
+                "max": max(rtts) if rtts else None,
+                "avg": sum(rtts) / len(rtts) if rtts else None,
+                "median": statistics.median(rtts) if rtts else None
+            },
+            "offset_ms": statistics.median(offsets) if offsets else None,
+            "stratum": stratum if isinstance(stratum, int) else None
+        }
+    except Exception as e:
+        logger.error(f"Error processing NTP result: {e}")
+        return _create_empty_ntp_result()
+
+def _create_empty_ntp_result() -> Dict[str, Any]:
+    result = process_default_result()
+    result["offset_ms"] = None
+    result["stratum"] = None
+    return result
+
+# This function processes the result of an sslcert measurement
+# A result is one TLS handshake: rt is its time and cert the server's chain
+# (leaf first); a handshake that failed carries err or alert and counts as lost.
+# days_to_expiry is counted from the result's own timestamp
+def process_sslcert_result(result: Dict[str, Any]) -> Dict[str, Any]:
+    try:
+        certificates = decode_certificate_chain(result.get("cert"))
+        failed = "err" in result or "alert" in result or not certificates
+        rt = result.get("rt")
+        rt = rt if isinstance(rt, (int, float)) and rt >= 0 and not failed else None
+        summary = {
+            "packet_loss_percentage": 100.0 if failed else 0.0,
+            "latency_stats": {"min": rt, "max": rt, "avg": rt, "median": rt},
+            "certificates": certificates,
+            "expires_at": None,
+            "days_to_expiry": None
+        }
+        leaf = certificates[0] if certificates else {}
+        if leaf.get("not_after"):
+            summary["expires_at"] = leaf["not_after"]
+            timestamp = result.get("timestamp")
+            if timestamp:
+                expires_at = datetime.strptime(leaf["not_after"], "%Y-%m-%dT%H:%M:%SZ")
+                remaining = expires_at - datetime.utcfromtimestamp(timestamp)
+                summary["days_to_expiry"] = round(remaining.total_seconds() / 86400, 2)
+        return summary
+    except Exception as e:
+        logger.error(f"Error processing sslcert result: {e}")
+        return _create_empty_sslcert_result()
+
+def _create_empty_sslcert_result() -> Dict[str, Any]:
+    result = process_default_result()
+    result.update({"certificates": [], "expires_at": None, "days_to_expiry": None})
+    return result
+
+# Summarizer for each measurement type, selected by the result's type;
+# unknown types get process_default_result
+RESULT_SUMMARIZERS: Dict[str, Callable[[Dict[str, Any]], Dict[str, Any]]] = {
+    "ping": process_ping_result,
+    "traceroute": process_traceroute_result,
+    "dns": process_dns_result,
+    "http": process_http_result,
+    "ntp": process_ntp_result,
+    "sslcert": process_sslcert_result
+}
+
+_NUMBER = (int, float)
+
+# Fields create_basic_result reads from every result, as path -> (types, required)
+COMMON_RESULT_FIELDS: Dict[str, Tuple[tuple, bool]] = {
+    "type": ((str,), True),
+    "prb_id": ((int,), True),
+    "timestamp": ((int,), True),
+    "from": ((str,), False)
+}
+
+# Fields each summarizer reads, by measurement type. "a.b" is a nested key and
+# "a[]" each item of a list. A required field must be in every result (where
+# its parent is); an optional one, like the rtt a lost ping lacks, only needs
+# to show up somewhere in the sample. Keep in step with RESULT_SUMMARIZERS
+RESULT_SCHEMAS: Dict[str, Dict[str, Tuple[tuple, bool]]] = {
+    "ping": {
+        "dst_addr": ((str,), True),
+        "result": ((list,), True),
+        "result[].rtt": (_NUMBER, False)
+    },
+    "traceroute": {
+        "dst_addr": ((str,), True),
+        "result": ((list,), True)
+    },
+    "http": {
+        "result": ((list,), True),
+        "result[].rt": (_NUMBER, False),
+        "result[].res": ((int,), False)
+    },
+    "ntp": {
+        "result": ((list,), True),
+        "result[].rtt": (_NUMBER, False),
+        "result[].offset": (_NUMBER, False),
+        "stratum": ((int,), False)
+    },
+    "sslcert": {
+        "rt": (_NUMBER, False),
+        "cert": ((list,), False)
+    },
+    "dns": {
+        "result.rt": (_NUMBER, True),
+        "result.ANCOUNT": ((int,), True),
+        "resultset[].result.rt": (_NUMBER, True),
+        "resultset[].result.ANCOUNT": ((int,), True)
+    }
+}
+
+def _field_parents(result: Dict[str, Any], segments: List[str]) -> List[Any]:
+    """Values reached by following segments from result; a segment ending in [] fans out over list items."""
+    values = [result]
+    for segment in segments:
+        key = segment[:-2] if segment.endswith("[]") else segment
+        reached = []
+        for value in values:
+            if not isinstance(value, dict) or key not in value:
+                continue
+            if segment.endswith("[]"):
+                if isinstance(value[key], list):
+                    reached.extend(value[key])
+            else:
+                reached.append(value[key])
+        values = reached
+    return values
+
+def validate_result_schema(results: List[Dict[str, Any]],
+                           measurement_type: Optional[str] = None) -> List[Dict[str, Any]]:
+    """Check raw results against the fields the parsers expect and report any drift.
+
+    Returns one entry per problem: {"field", "problem" ("missing" or "type"),
+    "results" (how many results show it), "expected", "found"}. An empty list
+    means the sample matches COMMON_RESULT_FIELDS and RESULT_SCHEMAS.
+    """
+    missing: Dict[str, int] = {}
+    seen: Dict[str, int] = {}
+    wrong_types: Dict[str, Dict[str, int]] = {}
+    specs: Dict[str, Tuple[tuple, bool]] = {}
+    for result in results:
+        fields = dict(COMMON_RESULT_FIELDS, **RESULT_SCHEMAS.get(measurement_type or result.get("type"), {}))
+        specs.update(fields)
+        for path, (types, required) in fields.items():
+            *parent_path, key = path.split(".")
+            parents = [parent for parent in _field_parents(result, parent_path) if isinstance(parent, dict)]
+            has_field = [parent for parent in parents if key in parent]
+            if parents and not has_field and required:
+                missing[path] = missing.get(path, 0) + 1
+            if has_field:
+                seen[path] = seen.get(path, 0) + 1
+            found = {type(parent[key]).__name__ for parent in has_field
+                     if not isinstance(parent[key], types) or isinstance(parent[key], bool)}
+            for name in found:
+                wrong_types.setdefault(path, {})
+                wrong_types[path][name] = wrong_types[path].get(name, 0) + 1
+
+    drift = []
+    for path, (types, required) in specs.items():
+        expected = " or ".join(t.__name__ for t in types)
+        if required and missing.get(path):
+            drift.append({"field": path, "problem": "missing", "results": missing[path],
+                          "expected": expected, "found": None})
+        elif not required and not seen.get(path) and _optional_parent_seen(results, path, measurement_type):
+            drift.append({"field": path, "problem": "missing", "results": len(results),
+                          "expected": expected, "found": None})
+        if wrong_types.get(path):
+            drift.append({"field": path, "problem": "type", "results": sum(wrong_types[path].values()),
+                          "expected": expected, "found": ", ".join(sorted(wrong_types[path]))})
+    return drift
+
+def _optional_parent_seen(results: List[Dict[str, Any]], path: str, measurement_type: Optional[str]) -> bool:
+    """Whether any result has the dict an optional field belongs in, so its absence everywhere is drift."""
+    parent_path = path.split(".")[:-1]
+    for result in results:
+        fields = dict(COMMON_RESULT_FIELDS, **RESULT_SCHEMAS.get(measurement_type or result.get("type"), {}))
+        if path in fields and any(isinstance(parent, dict) for parent in _field_parents(result, parent_path)):
+            return True
+    return False
+
+def summarize_result(result: Dict[str, Any], measurement_type: Optional[str] = None) -> Dict[str, Any]:
+    """Summarize one raw Atlas result with the summarizer registered for its type.
+
+    The type defaults to the result's own `type` field. Every summary has
+    packet_loss_percentage and latency_stats; summarizers may add fields
+    such as hops_count (traceroute), answer_count (dns), status_codes (http),
+    offset_ms and stratum (ntp) or certificates and days_to_expiry (sslcert).
+    """
+    summarizer = RESULT_SUMMARIZERS.get(measurement_type or result.get("type"))
+    if summarizer is None:
+        return process_default_result()
+    return summarizer(result)
+
 # This function creates a basic result dictionary from a measurement result
 # It extracts the probe ID, source address, target address, and timestamp
 def create_basic_result(result: Dict[str, Any]) -> Dict[str, Any]:
@@ -0,0 +1,85 @@
+import threading
+import time
+from typing import Callable, Mapping, Optional
+from .logger import logger
+
+# Reset header values above this are Unix timestamps rather than seconds from now
+_EPOCH_THRESHOLD = 10 ** 9
+
+# Longest pause taken on the server's word, in case a header is off by orders of magnitude
+MAX_RATE_LIMIT_PAUSE = 300.0
+
+
+class RateLimiter:
+    """Spaces out API requests shared by every thread of a client.
+
+    A token bucket allows requests_per_second on average with bursts of up
+    to burst requests; None leaves the rate unlimited. Independently of the
+    rate, the limiter holds all requests back when Atlas reports its limit
+    is used up (X-RateLimit-Remaining: 0 until X-RateLimit-Reset) or
+    answers 429 with Retry-After, so concurrent callers wait together
+    instead of each running into 429s.
+    """
+
+    def __init__(self, requests_per_second: Optional[float] = None, burst: Optional[int] = None):
+        if requests_per_second is not None and requests_per_second <= 0:
+            raise ValueError("Rate limit requests_per_second must be greater than zero")
+        if burst is not None and burst < 1:
+            raise ValueError("Rate limit burst must be at least 1")
+        self.requests_per_second = requests_per_second
+        self.burst = burst or 1
+        self._tokens = float(self.burst)
+        self._updated = time.monotonic()
+        self._paused_until = 0.0
+        self._lock = threading.Lock()
+
+    def _reserve(self) -> float:
+        """Take a token if one is available and return 0, else return the seconds to wait first."""
+        with self._lock:
+            now = time.monotonic()
+            if now < self._paused_until:
+                return self._paused_until - now
+            if self.requests_per_second is None:
+                return 0.0
+            self._tokens = min(self.burst, self._tokens + (now - self._updated) * self.requests_per_second)
+            self._updated = now
+            if self._tokens >= 1:
+                self._tokens -= 1
+                return 0.0
+            return (1 - self._tokens) / self.requests_per_second
+
+    def acquire(self, wait: Callable[[float], None] = time.sleep) -> None:
+        """Block until a request may be sent. wait sleeps (and may raise to give up, e.g. Context.wait)."""
+        while True:
+            delay = self._reserve()
+            if delay <= 0:
+                return
+            wait(delay)
+
+    def pause(self, seconds: float) -> None:
+        """Hold every request back for seconds (capped at MAX_RATE_LIMIT_PAUSE)."""
+        seconds = min(seconds, MAX_RATE_LIMIT_PAUSE)
+        with self._lock:
+            until = time.monotonic() + seconds
+            if until > self._paused_until:
+                self._paused_until = until
+                logger.warning(f"Atlas API rate limit reached; holding requests for {seconds:.1f}s")
+
+    def observe(self, status_code: int, headers: Mapping[str, str]) -> None:
+        """Pause as the rate-limit headers of a response ask."""
+        retry_after = _seconds(headers.get("Retry-After"))
+        if status_code == 429 and retry_after is not None:
+            self.pause(retry_after)
+        elif _seconds(headers.get("X-RateLimit-Remaining")) == 0:
+            reset = _seconds(headers.get("X-RateLimit-Reset"))
+            if reset is not None:
+                self.pause(reset - time.time() if reset > _EPOCH_THRESHOLD else reset)
+
+
+def _seconds(value: Optional[str]) -> Optional[float]:
+    """A non-negative number header value, or None if missing or not a number."""
+    try:
+        number = float(value)
+    except (TypeError, ValueError):
+        return None
+    return number if number >= 0 else None
@@ -0,0 +1,291 @@
+from dataclasses import dataclass, field
+from typing import Any, Callable, Dict, List, Optional
+from .dns_wire import decode_abuf
+
+# Atlas reports min/avg/max as -1 when no packet came back
+_NO_RTT = -1
+
+
+def _rtt(value: Any) -> Optional[float]:
+    return float(value) if isinstance(value, (int, float)) and not isinstance(value, bool) and value >= 0 else None
+
+
+def _require(raw: Dict[str, Any], measurement_type: str) -> None:
+    if not isinstance(raw, dict):
+        raise ValueError(f"Expected a {measurement_type} result object, got {type(raw).__name__}")
+    if raw.get("type", measurement_type) != measurement_type:
+        raise ValueError(f"Expected a {measurement_type} result, got type '{raw.get('type')}'")
+
+
+@dataclass
+class PingResult:
+    """One probe's ping result.
+
+    rtts has one entry per packet sent, None for packets that got no reply;
+    min/avg/max_rtt are None when none did. errors holds the messages of
+    packets that failed outright (e.g. "sendto failed").
+    """
+    probe_id: Optional[int]
+    timestamp: Optional[int]
+    measurement_id: Optional[int] = None
+    target: Optional[str] = None
+    source: Optional[str] = None
+    af: Optional[int] = None
+    sent: int = 0
+    received: int = 0
+    min_rtt: Optional[float] = None
+    avg_rtt: Optional[float] = None
+    max_rtt: Optional[float] = None
+    rtts: List[Optional[float]] = field(default_factory=list)
+    errors: List[str] = field(default_factory=list)
+
+    @property
+    def packet_loss(self) -> Optional[float]:
+        """Share of packets lost, in percent, or None when nothing was sent."""
+        return (self.sent - self.received) / self.sent * 100 if self.sent else None
+
+
+def decode_ping_result(raw: Dict[str, Any]) -> PingResult:
+    """Decode a raw Atlas ping result.
+
+    sent and received come from the result's own counts, falling back to the
+    per-packet entries; min/avg/max likewise fall back to the replies'
+    RTTs. Raises ValueError for anything but a ping result object.
+    """
+    _require(raw, "ping")
+    packets = [packet for packet in raw.get("result") or [] if isinstance(packet, dict)]
+    rtts = [_rtt(packet.get("rtt")) for packet in packets]
+    replies = [rtt for rtt in rtts if rtt is not None]
+    sent = raw.get("sent")
+    received = raw.get("rcvd")
+
+    def stat(name: str, fallback: Callable[[List[float]], float]) -> Optional[float]:
+        value = raw.get(name)
+        if isinstance(value, (int, float)) and value != _NO_RTT:
+            return _rtt(value)
+        return fallback(replies) if replies and value is None else None
+
+    return PingResult(
+        probe_id=raw.get("prb_id"),
+        timestamp=raw.get("timestamp"),
+        measurement_id=raw.get("msm_id"),
+        target=raw.get("dst_addr") or raw.get("dst_name"),
+        source=raw.get("src_addr") or raw.get("from"),
+        af=raw.get("af"),
+        sent=sent if isinstance(sent, int) else len(packets),
+        received=received if isinstance(received, int) else len(replies),
+        min_rtt=stat("min", min),
+        avg_rtt=stat("avg", lambda values: sum(values) / len(values)),
+        max_rtt=stat("max", max),
+        rtts=rtts,
+        errors=[str(packet["error"]) for packet in packets if packet.get("error")]
+    )
+
+
+@dataclass
+class TracerouteReply:
+    """One reply (or timeout) to a traceroute probe packet.
+
+    A timed-out packet has neither address nor rtt. icmp_error is the Atlas
+    code of an ICMP error reply: "N" (network unreachable), "H" (host
+    unreachable), "A" (administratively prohibited), "P" (protocol
+    unreachable), "p" (port unreachable) or a numeric ICMP code.
+    """
+    address: Optional[str] = None
+    rtt: Optional[float] = None
+    ttl: Optional[int] = None
+    icmp_error: Optional[str] = None
+
+    @property
+    def timed_out(self) -> bool:
+        return self.address is None and self.rtt is None
+
+
+@dataclass
+class TracerouteHop:
+    """The replies to the packets sent with one TTL; error is set when the hop could not be sent."""
+    hop: int
+    replies: List[TracerouteReply] = field(default_factory=list)
+    error: Optional[str] = None
+
+    @property
+    def addresses(self) -> List[str]:
+        """Responding IPs, each once, in the order they replied."""
+        return list(dict.fromkeys(reply.address for reply in self.replies if reply.address))
+
+    @property
+    def rtts(self) -> List[float]:
+        return [reply.rtt for reply in self.replies if reply.rtt is not None]
+
+
+@dataclass
+class TracerouteResult:
+    """One probe's traceroute: its hops in order and whether the target answered."""
+    probe_id: Optional[int]
+    timestamp: Optional[int]
+    measurement_id: Optional[int] = None
+    target: Optional[str] = None
+    source: Optional[str] = None
+    af: Optional[int] = None
+    protocol: Optional[str] = None
+    end_time: Optional[int] = None
+    hops: List[TracerouteHop] = field(default_factory=list)
+    destination_reached: bool = False
+
+    @property
+    def icmp_errors(self) -> List[str]:
+        """ICMP error codes seen along the path, in hop order."""
+        return [reply.icmp_error for hop in self.hops for reply in hop.replies if reply.icmp_error]
+
+
+def _traceroute_reply(reply: Dict[str, Any]) -> TracerouteReply:
+    error = reply.get("err")
+    return TracerouteReply(
+        address=reply.get("from"),
+        rtt=_rtt(reply.get("rtt")),
+        ttl=reply.get("ttl"),
+        icmp_error=str(error) if error is not None else None
+    )
+
+
+def decode_traceroute_result(raw: Dict[str, Any]) -> TracerouteResult:
+    """Decode a raw Atlas traceroute result.
+
+    The destination counts as reached when the target address replied
+    without an ICMP error on the last hop. Raises ValueError for anything
+    but a traceroute result object.
+    """
+    _require(raw, "traceroute")
+    hops = []
+    for position, hop in enumerate(raw.get("result") or [], start=1):
+        if not isinstance(hop, dict):
+            continue
+        replies = [_traceroute_reply(reply) for reply in hop.get("result") or [] if isinstance(reply, dict)]
+        hops.append(TracerouteHop(hop=hop.get("hop", position), replies=replies, error=hop.get("error")))
+
+    target = raw.get("dst_addr")
+    last_replies = hops[-1].replies if hops else []
+    return TracerouteResult(
+        probe_id=raw.get("prb_id"),
+        timestamp=raw.get("timestamp"),
+        measurement_id=raw.get("msm_id"),
+        target=target or raw.get("dst_name"),
+        source=raw.get("src_addr") or raw.get("from"),
+        af=raw.get("af"),
+        protocol=raw.get("proto"),
+        end_time=raw.get("endtime"),
+        hops=hops,
+        destination_reached=bool(target) and any(reply.address == target and reply.icmp_error is None
+                                                 for reply in last_replies)
+    )
+
+
+@dataclass
+class DnsRecord:
+    """A resource record from a DNS answer, with its data in the usual text form (e.g. an address for A)."""
+    name: str
+    type: str
+    ttl: int
+    data: str
+
+
+@dataclass
+class DnsResponse:
+    """One resolver's answer to a DNS query, or the error that kept it from answering.
+
+    rcode is the response code by name ("NOERROR", "NXDOMAIN", ...) and is
+    None, like answers, when there was no response or it could not be
+    decoded.
+    """
+    resolver: Optional[str] = None
+    protocol: Optional[str] = None
+    af: Optional[int] = None
+    response_time: Optional[float] = None
+    rcode: Optional[str] = None
+    truncated: bool = False
+    answers: List[DnsRecord] = field(default_factory=list)
+    error: Optional[str] = None
+
+
+@dataclass
+class DnsResult:
+    """One probe's DNS result: a response per resolver queried (one unless the probe used its own resolvers)."""
+    probe_id: Optional[int]
+    timestamp: Optional[int]
+    measurement_id: Optional[int] = None
+    responses: List[DnsResponse] = field(default_factory=list)
+
+    @property
+    def answers(self) -> List[DnsRecord]:
+        """The answer records of every response."""
+        return [record for response in self.responses for record in response.answers]
+
+
+def _dns_error(error: Any) -> str:
+    if isinstance(error, dict):
+        return ", ".join(f"{key}: {value}" for key, value in error.items())
+    return str(error)
+
+
+def _dns_response(entry: Dict[str, Any], defaults: Dict[str, Any]) -> DnsResponse:
+    response = DnsResponse(
+        resolver=entry.get("dst_addr") or entry.get("dst_name") or defaults.get("dst_addr"),
+        protocol=entry.get("proto") or defaults.get("proto"),
+        af=entry.get("af") or defaults.get("af")
+    )
+    result = entry.get("result")
+    if not isinstance(result, dict):
+        response.error = _dns_error(entry.get("error") or "no response")
+        return response
+    response.response_time = _rtt(result.get("rt"))
+    if not result.get("abuf"):
+        response.error = "no abuf in response"
+        return response
+    try:
+        message = decode_abuf(result["abuf"])
+    except ValueError as e:
+        response.error = str(e)
+        return response
+    response.rcode = message["rcode"]
+    response.truncated = message["truncated"]
+    response.answers = [DnsRecord(**record) for record in message["answers"]]
+    return response
+
+
+def decode_dns_result(raw: Dict[str, Any]) -> DnsResult:
+    """Decode a raw Atlas DNS result, decoding each response's base64 abuf.
+
+    A result holds one response, or with the probe's own resolvers a
+    resultset of one per resolver. Responses that timed out, failed, or
+    whose abuf cannot be decoded keep their error instead. Raises
+    ValueError for anything but a DNS result object.
+    """
+    _require(raw, "dns")
+    entries = raw["resultset"] if isinstance(raw.get("resultset"), list) else [raw]
+    return DnsResult(
+        probe_id=raw.get("prb_id"),
+        timestamp=raw.get("timestamp"),
+        measurement_id=raw.get("msm_id"),
+        responses=[_dns_response(entry, raw) for entry in entries if isinstance(entry, dict)]
+    )
+
+
+# Decoder of each result type, by the Atlas result "type" field
+RESULT_DECODERS: Dict[str, Callable[[Dict[str, Any]], Any]] = {
+    "ping": decode_ping_result,
+    "traceroute": decode_traceroute_result,
+    "dns": decode_dns_result,
+}
+
+
+def decode_result(raw: Dict[str, Any], measurement_type: Optional[str] = None) -> Any:
+    """Decode a raw Atlas result into the typed result registered for its type.
+
+    The type defaults to the result's own `type` field. Raises ValueError
+    for result types without a decoder and for malformed results.
+    """
+    measurement_type = measurement_type or (raw.get("type") if isinstance(raw, dict) else None)
+    decoder = RESULT_DECODERS.get(measurement_type)
+    if decoder is None:
+        raise ValueError(f"No typed decoder for {measurement_type or 'untyped'} results")
+    return decoder(raw)
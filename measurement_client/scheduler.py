@@ -0,0 +1,150 @@
+import threading
+from datetime import datetime, timedelta, timezone
+from typing import Any, Callable, List, Optional, Set
+from .logger import logger
+
+# (name, lowest, highest) of the five cron fields, in expression order
+CRON_FIELDS = [
+    ("minute", 0, 59),
+    ("hour", 0, 23),
+    ("day of month", 1, 31),
+    ("month", 1, 12),
+    ("day of week", 0, 7)  # 0 and 7 are both Sunday
+]
+
+# Furthest ahead next_run looks before deciding an expression never matches
+# (covers Feb 29 schedules, which recur every 4 years)
+MAX_LOOKAHEAD = timedelta(days=366 * 5)
+
+
+def _parse_field(text: str, name: str, low: int, high: int) -> Set[int]:
+    """Parse one cron field (*, N, A-B, and /STEP on either, comma separated)."""
+    values = set()
+    for part in text.split(","):
+        spec, _, step_text = part.partition("/")
+        try:
+            step = int(step_text) if step_text else 1
+            if spec == "*":
+                start, end = low, high
+            elif "-" in spec:
+                start, end = (int(v) for v in spec.split("-", 1))
+            else:
+                start = int(spec)
+                end = high if step_text else start
+        except ValueError:
+            raise ValueError(f"Invalid cron {name} field '{text}'") from None
+        if step <= 0 or start > end or start < low or end > high:
+            raise ValueError(f"Invalid cron {name} field '{text}': values must be within {low}-{high}")
+        values.update(range(start, end + 1, step))
+    return values
+
+
+class CronSchedule:
+    """A standard five-field cron expression: minute hour day-of-month month day-of-week.
+
+    Supports *, single values, ranges, steps and lists. As in cron, when both
+    day of month and day of week are restricted a day matching either runs.
+    Times are evaluated in UTC.
+    """
+
+    def __init__(self, expression: str):
+        parts = expression.split()
+        if len(parts) != len(CRON_FIELDS):
+            raise ValueError(
+                f"Invalid cron expression '{expression}': expected 5 fields "
+                "(minute hour day-of-month month day-of-week)"
+            )
+        self.expression = expression
+        self.minutes, self.hours, self.days, self.months, weekdays = [
+            _parse_field(part, name, low, high) for part, (name, low, high) in zip(parts, CRON_FIELDS)
+        ]
+        self.weekdays = {day % 7 for day in weekdays}
+        self._any_day = parts[2].startswith("*")
+        self._any_weekday = parts[4].startswith("*")
+
+    def _day_matches(self, moment: datetime) -> bool:
+        day = moment.day in self.days
+        weekday = (moment.weekday() + 1) % 7 in self.weekdays  # cron counts from Sunday
+        if self._any_day or self._any_weekday:
+            return day and weekday
+        return day or weekday
+
+    def matches(self, moment: datetime) -> bool:
+        return (moment.minute in self.minutes and moment.hour in self.hours
+                and moment.month in self.months and self._day_matches(moment))
+
+    def next_run(self, after: datetime) -> datetime:
+        """Return the first matching minute strictly after `after`."""
+        if after.tzinfo is None:
+            after = after.replace(tzinfo=timezone.utc)
+        moment = after.astimezone(timezone.utc).replace(second=0, microsecond=0) + timedelta(minutes=1)
+        limit = moment + MAX_LOOKAHEAD
+
+        while moment <= limit:
+            if moment.month not in self.months or not self._day_matches(moment):
+                moment = moment.replace(hour=0, minute=0) + timedelta(days=1)
+            elif moment.hour not in self.hours:
+                moment = moment.replace(minute=0) + timedelta(hours=1)
+            elif moment.minute not in self.minutes:
+                moment += timedelta(minutes=1)
+            else:
+                return moment
+        raise ValueError(f"Cron expression '{self.expression}' never matches")
+
+
+class Scheduler:
+    """Run a job on a cron schedule in the foreground until stopped.
+
+    Each run happens on a worker thread so the schedule keeps time; when a
+    run is still going at the next tick that tick is skipped rather than
+    overlapping. The job's return value is logged as the run summary.
+    """
+
+    def __init__(self, schedule: CronSchedule, job: Callable[[], Any],
+                 summarize: Optional[Callable[[Any], str]] = None):
+        self.schedule = schedule
+        self.job = job
+        self.summarize = summarize or str
+        self.runs = 0
+        self.skipped = 0
+        self._running = threading.Lock()
+        self._stop = threading.Event()
+        self._workers: List[threading.Thread] = []
+
+    def run_once(self) -> Optional[Any]:
+        """Run the job now unless a previous run is still going. Returns its result."""
+        if not self._running.acquire(blocking=False):
+            self.skipped += 1
+            logger.warning("Previous scheduled run is still in progress; skipping this run")
+            return None
+        try:
+            self.runs += 1
+            logger.info(f"Scheduled run {self.runs} started")
+            result = self.job()
+            logger.info(f"Scheduled run {self.runs} finished: {self.summarize(result)}")
+            return result
+        except Exception as e:
+            logger.error(f"Scheduled run {self.runs} failed: {e}")
+            return None
+        finally:
+            self._running.release()
+
+    def run_forever(self, now: Callable[[], datetime] = lambda: datetime.now(timezone.utc)) -> None:
+        """Block, starting a run at every scheduled minute until stop() is called."""
+        while not self._stop.is_set():
+            next_run = self.schedule.next_run(now())
+            logger.info(f"Next run at {next_run.isoformat().replace('+00:00', 'Z')}")
+            # Wake at least every minute so clock changes cannot strand the loop
+            while not self._stop.is_set() and now() < next_run:
+                self._stop.wait(min(60.0, (next_run - now()).total_seconds()))
+            if self._stop.is_set():
+                break
+            worker = threading.Thread(target=self.run_once, daemon=True)
+            worker.start()
+            self._workers = [w for w in self._workers if w.is_alive()] + [worker]
+
+    def stop(self, wait: bool = True) -> None:
+        self._stop.set()
+        if wait:
+            for worker in self._workers:
+                worker.join()
@@ -0,0 +1,162 @@
+import codecs
+import itertools
+import json
+import threading
+import zlib
+from typing import Any, Callable, Dict, Iterable, Iterator, List, Set
+from measurement_client.errors import CorruptBodyError
+
+# First bytes of every gzip stream
+GZIP_MAGIC = b"\x1f\x8b"
+
+JSON_WHITESPACE = " \t\r\n"
+
+# Rows ReorderBuffer holds for sources that are not yet due before it makes their producers wait
+DEFAULT_REORDER_BUFFER = 10000
+
+
+def _inflate_members(chunks: Iterable[bytes]) -> Iterator[bytes]:
+    """Decompress a gzip stream, including any further members concatenated to the first.
+
+    Raises CorruptBodyError when the data is not gzip, ends inside a member
+    or continues with anything but another member.
+    """
+    decompressor = zlib.decompressobj(16 + zlib.MAX_WBITS)
+    in_member = False
+    try:
+        for chunk in chunks:
+            while chunk:
+                in_member = True
+                yield decompressor.decompress(chunk)
+                if not decompressor.eof:
+                    break
+                chunk = decompressor.unused_data
+                decompressor = zlib.decompressobj(16 + zlib.MAX_WBITS)
+                in_member = False
+        yield decompressor.flush()
+    except zlib.error as e:
+        raise CorruptBodyError(f"Cannot decompress the response body: {e}") from e
+    if in_member:
+        raise CorruptBodyError("Cannot decompress the response body: it ends inside a gzip member")
+
+
+def gunzip_chunks(chunks: Iterable[bytes]) -> Iterator[bytes]:
+    """Pass chunks through, decompressing them for as long as the stream starts as gzip.
+
+    Like decode_json_response, a body compressed more than once (a gzip
+    file compressed again by a proxy) is unpacked layer by layer, and like
+    gzip.decompress, concatenated gzip members are all decompressed.
+    """
+    chunks = iter(chunks)
+    head = b""
+    for chunk in chunks:
+        head += chunk
+        if len(head) >= len(GZIP_MAGIC):
+            break
+    rest = itertools.chain([head], chunks)
+    if head[:len(GZIP_MAGIC)] != GZIP_MAGIC:
+        yield from (chunk for chunk in rest if chunk)
+        return
+    yield from gunzip_chunks(_inflate_members(rest))
+
+
+def iter_json_array(chunks: Iterable[bytes]) -> Iterator[Any]:
+    """Yield the elements of a top-level JSON array read from byte chunks.
+
+    Only the element being decoded is held in memory, so arbitrarily large
+    result arrays can be processed row by row. Raises ValueError when the
+    body is not a JSON array or ends before the closing bracket.
+    """
+    decoder = json.JSONDecoder()
+    text_decoder = codecs.getincrementaldecoder("utf-8")()
+    buffer = ""
+    started = False
+    expect_separator = False
+
+    for chunk in gunzip_chunks(chunks):
+        buffer += text_decoder.decode(chunk)
+        pos = 0
+        while True:
+            while pos < len(buffer) and buffer[pos] in JSON_WHITESPACE:
+                pos += 1
+            if pos >= len(buffer):
+                break
+
+            if not started:
+                if buffer[pos] != "[":
+                    raise ValueError("Expected a JSON array of results")
+                started = True
+                pos += 1
+                continue
+            if buffer[pos] == "]":
+                return
+            if expect_separator:
+                if buffer[pos] != ",":
+                    raise ValueError(f"Expected ',' between results, got {buffer[pos]!r}")
+                expect_separator = False
+                pos += 1
+                continue
+
+            try:
+                item, end = decoder.raw_decode(buffer, pos)
+            except json.JSONDecodeError:
+                break  # element continues in the next chunk
+            if end >= len(buffer):
+                break  # a trailing number may still be growing; wait for more input
+            yield item
+            expect_separator = True
+            pos = end
+
+        buffer = buffer[pos:]
+
+    if started and buffer.strip():
+        # Surface the decoder's own message for a malformed trailing element
+        decoder.raw_decode(buffer.strip())
+    raise ValueError("Unexpected end of results: JSON array was not closed")
+
+
+class ReorderBuffer:
+    """Emit items produced concurrently by numbered sources in source order.
+
+    Items from the source currently due (the lowest unfinished index) are
+    emitted straight away; items from later sources are held until every
+    earlier source has finished. Once max_buffered items are held, producers
+    of later sources wait, so memory stays bounded while the due source,
+    which never waits, keeps making progress. emit is called under a lock,
+    one item at a time. Sources must be started in index order (as a FIFO
+    thread pool does) so the due source is always running.
+    """
+
+    def __init__(self, emit: Callable[[Any], None], max_buffered: int = DEFAULT_REORDER_BUFFER):
+        if max_buffered <= 0:
+            raise ValueError("max_buffered must be greater than zero")
+        self.emit = emit
+        self.max_buffered = max_buffered
+        self.buffered = 0
+        self._due = 0
+        self._held: Dict[int, List[Any]] = {}
+        self._finished: Set[int] = set()
+        self._condition = threading.Condition()
+
+    def put(self, index: int, item: Any) -> None:
+        with self._condition:
+            while index != self._due and self.buffered >= self.max_buffered:
+                self._condition.wait()
+            if index == self._due:
+                self.emit(item)
+            else:
+                self._held.setdefault(index, []).append(item)
+                self.buffered += 1
+
+    def finish(self, index: int) -> None:
+        """Mark a source done, emitting held items of the sources now due."""
+        with self._condition:
+            self._finished.add(index)
+            while self._due in self._finished:
+                self._finished.discard(self._due)
+                self._due += 1
+                held = self._held.pop(self._due, [])
+                self.buffered -= len(held)
+                for item in held:
+                    self.emit(item)
+            self._condition.notify_all()
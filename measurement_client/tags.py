@@ -0,0 +1,63 @@
+import hashlib
+import re
+from pathlib import Path
+from typing import Dict, Any, List, Optional
+
+# RIPE Atlas tags are lowercase slugs: letters, digits, '-' and '_'
+_TAG_INVALID = re.compile(r"[^a-z0-9_-]+")
+
+# Added to every measurement Sintra creates so they can be told apart from others
+SINTRA_TAG = "sintra"
+
+
+def slugify_tag(tag: Any) -> str:
+    """Convert a value into a valid Atlas tag, e.g. 'My Tag!' -> 'my-tag'."""
+    slug = _TAG_INVALID.sub("-", str(tag).strip().lower())
+    return re.sub(r"-{2,}", "-", slug).strip("-")
+
+
+def target_tag(target: str) -> str:
+    """Short stable tag for a target; hashed because hostnames/IPs are not valid slugs."""
+    return f"target-{hashlib.sha1(target.encode()).hexdigest()[:8]}"
+
+
+def config_tag(config_path: Optional[str]) -> Optional[str]:
+    """Tag naming the config a measurement came from, e.g. 'configs/prod-eu.yaml' -> 'cfg-prod-eu'.
+
+    None when there is no file name (a config read from stdin).
+    """
+    if not config_path:
+        return None
+    slug = slugify_tag(Path(config_path).stem)
+    return f"cfg-{slug}" if slug else None
+
+
+def derive_tags(config: Dict[str, Any], measurement_type: str, target: str) -> List[str]:
+    """Tags describing a definition: Sintra marker, type, target, interval and address family."""
+    tags = [SINTRA_TAG, f"type-{measurement_type}", target_tag(target)]
+    if config.get('is_oneoff'):
+        tags.append("oneoff")
+    elif config.get('interval'):
+        tags.append(f"interval-{config['interval']}")
+    tags.append(f"af-{config.get('af', 4)}")
+    return [slugify_tag(tag) for tag in tags]
+
+
+def measurement_tags(config: Dict[str, Any], measurement_type: str, target: str,
+                     auto_tags: bool = True, source_tag: Optional[str] = None) -> Optional[List[str]]:
+    """User tags from the config plus (optionally) derived tags, slugified and de-duplicated.
+
+    source_tag (see config_tag) is added along with the derived tags.
+
+    Returns None when there are no tags so the definition leaves them unset.
+    """
+    user_tags = config.get('tags') or []
+    if isinstance(user_tags, str):
+        user_tags = [user_tags]
+    tags = [slugify_tag(tag) for tag in user_tags]
+    if auto_tags:
+        tags += derive_tags(config, measurement_type, target)
+        if source_tag:
+            tags.append(slugify_tag(source_tag))
+    tags = list(dict.fromkeys(tag for tag in tags if tag))
+    return tags or None
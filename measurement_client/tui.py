@@ -0,0 +1,240 @@
+import curses
+import threading
+from collections import deque
+from datetime import datetime, timezone
+from typing import Dict, Any, List, Optional
+from .logger import logger
+from .processors import summarize_result
+
+SPARK_CHARS = "▁▂▃▄▅▆▇█"
+
+
+def sparkline(values: List[Optional[float]], width: int = 20) -> str:
+    """Render the last `width` values as a unicode sparkline.
+
+    Missing samples (None) are drawn as a blank so gaps in polling stay visible.
+    """
+    values = list(values)[-width:]
+    present = [v for v in values if v is not None]
+    if not present:
+        return " " * len(values)
+
+    low, high = min(present), max(present)
+    span = high - low
+    chars = []
+    for value in values:
+        if value is None:
+            chars.append(" ")
+        elif span == 0:
+            chars.append(SPARK_CHARS[len(SPARK_CHARS) // 2])
+        else:
+            index = int((value - low) / span * (len(SPARK_CHARS) - 1))
+            chars.append(SPARK_CHARS[index])
+    return "".join(chars)
+
+
+def summarize_latest(rows: List[Dict[str, Any]]) -> Dict[str, Any]:
+    """Summarize raw latest-result rows into per-probe and overall RTT/loss.
+
+    RTT/loss come from the summarizer registered for each row's type (ping
+    and dns have them); other types are listed with their probe and
+    timestamp so the drill-down view is never empty.
+    """
+    probes = {}
+    for row in rows:
+        probe_id = row.get("prb_id")
+        if probe_id is None:
+            continue
+
+        stats = summarize_result(row)
+        avg = stats["latency_stats"]["avg"]
+        loss = stats["packet_loss_percentage"]
+
+        probes[probe_id] = {
+            "probe_id": probe_id,
+            "timestamp": row.get("timestamp"),
+            "rtt_avg": avg,
+            "loss": loss,
+            "source_address": row.get("from"),
+        }
+
+    rtts = [p["rtt_avg"] for p in probes.values() if p["rtt_avg"] is not None]
+    losses = [p["loss"] for p in probes.values() if p["loss"] is not None]
+    return {
+        "probes": probes,
+        "rtt_avg": sum(rtts) / len(rtts) if rtts else None,
+        "loss": sum(losses) / len(losses) if losses else None,
+    }
+
+
+class DashboardState:
+    """Thread-safe store of the latest summary and RTT/loss history per measurement."""
+
+    def __init__(self, measurement_ids: List[int], history_size: int = 60):
+        self.measurement_ids = list(measurement_ids)
+        self.summaries: Dict[int, Dict[str, Any]] = {}
+        self.rtt_history = {mid: deque(maxlen=history_size) for mid in self.measurement_ids}
+        self.loss_history = {mid: deque(maxlen=history_size) for mid in self.measurement_ids}
+        self.errors: Dict[int, str] = {}
+        self.last_poll: Optional[datetime] = None
+        self._lock = threading.Lock()
+
+    def update(self, measurement_id: int, summary: Dict[str, Any]) -> None:
+        with self._lock:
+            self.summaries[measurement_id] = summary
+            self.rtt_history[measurement_id].append(summary.get("rtt_avg"))
+            self.loss_history[measurement_id].append(summary.get("loss"))
+            self.errors.pop(measurement_id, None)
+
+    def record_error(self, measurement_id: int, message: str) -> None:
+        with self._lock:
+            self.errors[measurement_id] = message
+            self.rtt_history[measurement_id].append(None)
+            self.loss_history[measurement_id].append(None)
+
+    def mark_polled(self, when: datetime) -> None:
+        with self._lock:
+            self.last_poll = when
+
+    def snapshot(self) -> Dict[str, Any]:
+        with self._lock:
+            return {
+                "summaries": dict(self.summaries),
+                "rtt_history": {k: list(v) for k, v in self.rtt_history.items()},
+                "loss_history": {k: list(v) for k, v in self.loss_history.items()},
+                "errors": dict(self.errors),
+                "last_poll": self.last_poll,
+            }
+
+
+class SintraDashboard:
+    """Interactive curses dashboard showing live RTT/loss per measurement.
+
+    A background thread polls the latest results for every measurement on a
+    fixed interval while the UI thread only renders the shared state, so slow
+    API responses never freeze the keyboard handling.
+    """
+
+    def __init__(self, client, measurement_ids: List[int], interval: int = 60):
+        self.client = client
+        self.interval = interval
+        self.state = DashboardState(measurement_ids)
+        self.selected = 0
+        self.detail = False
+        self._stop = threading.Event()
+
+    def poll_once(self) -> None:
+        for measurement_id in self.state.measurement_ids:
+            if self._stop.is_set():
+                return
+            try:
+                rows = self.client.fetch_latest_results(measurement_id)
+                self.state.update(measurement_id, summarize_latest(rows))
+            except Exception as e:
+                logger.debug(f"Dashboard poll failed for measurement {measurement_id}: {e}")
+                self.state.record_error(measurement_id, str(e))
+        self.state.mark_polled(datetime.now(timezone.utc))
+
+    def _poll_loop(self) -> None:
+        while not self._stop.is_set():
+            self.poll_once()
+            self._stop.wait(self.interval)
+
+    def run(self) -> None:
+        poller = threading.Thread(target=self._poll_loop, daemon=True)
+        poller.start()
+        try:
+            curses.wrapper(self._main)
+        finally:
+            self._stop.set()
+
+    def _main(self, screen) -> None:
+        curses.curs_set(0)
+        screen.timeout(500)
+
+        while True:
+            self._render(screen)
+            key = screen.getch()
+            if key in (ord('q'), ord('Q')):
+                return
+            if key in (curses.KEY_UP, ord('k')):
+                self.selected = max(0, self.selected - 1)
+            elif key in (curses.KEY_DOWN, ord('j')):
+                self.selected = min(len(self.state.measurement_ids) - 1, self.selected + 1)
+            elif key in (curses.KEY_ENTER, 10, 13):
+                self.detail = True
+            elif key in (27, curses.KEY_BACKSPACE, 127, ord('b')):
+                self.detail = False
+
+    def _render(self, screen) -> None:
+        screen.erase()
+        height, width = screen.getmaxyx()
+        snapshot = self.state.snapshot()
+
+        last_poll = snapshot["last_poll"]
+        poll_str = last_poll.strftime("%H:%M:%S UTC") if last_poll else "pending"
+        header = f"Sintra live dashboard - refresh {self.interval}s - last poll {poll_str}"
+        self._addstr(screen, 0, 0, header[:width - 1], curses.A_BOLD)
+
+        if self.detail and self.state.measurement_ids:
+            self._render_detail(screen, snapshot, height, width)
+            footer = "[b/Esc] back  [q] quit"
+        else:
+            self._render_overview(screen, snapshot, height, width)
+            footer = "[up/down] select  [Enter] probe detail  [q] quit"
+
+        self._addstr(screen, height - 1, 0, footer[:width - 1], curses.A_DIM)
+        screen.refresh()
+
+    def _render_overview(self, screen, snapshot, height, width) -> None:
+        columns = f"{'Measurement':<14}{'Probes':>7}{'RTT avg':>11}{'Loss':>8}  RTT trend"
+        self._addstr(screen, 2, 0, columns[:width - 1], curses.A_UNDERLINE)
+
+        for row, measurement_id in enumerate(self.state.measurement_ids):
+            y = 3 + row
+            if y >= height - 1:
+                break
+
+            summary = snapshot["summaries"].get(measurement_id, {})
+            probes = len(summary.get("probes", {}))
+            rtt = summary.get("rtt_avg")
+            loss = summary.get("loss")
+            rtt_str = f"{rtt:.1f} ms" if rtt is not None else "-"
+            loss_str = f"{loss:.1f}%" if loss is not None else "-"
+            trend = sparkline(snapshot["rtt_history"].get(measurement_id, []))
+
+            line = f"{measurement_id:<14}{probes:>7}{rtt_str:>11}{loss_str:>8}  {trend}"
+            if measurement_id in snapshot["errors"]:
+                line += f"  ! {snapshot['errors'][measurement_id]}"
+
+            attr = curses.A_REVERSE if row == self.selected else curses.A_NORMAL
+            self._addstr(screen, y, 0, line[:width - 1], attr)
+
+    def _render_detail(self, screen, snapshot, height, width) -> None:
+        measurement_id = self.state.measurement_ids[self.selected]
+        summary = snapshot["summaries"].get(measurement_id, {})
+        self._addstr(screen, 2, 0, f"Measurement {measurement_id} - per-probe latest results"[:width - 1])
+
+        columns = f"{'Probe':<10}{'Source':<40}{'RTT avg':>11}{'Loss':>8}  {'Time':<20}"
+        self._addstr(screen, 4, 0, columns[:width - 1], curses.A_UNDERLINE)
+
+        probes = sorted(summary.get("probes", {}).values(), key=lambda p: p["probe_id"])
+        for row, probe in enumerate(probes):
+            y = 5 + row
+            if y >= height - 1:
+                break
+            rtt_str = f"{probe['rtt_avg']:.1f} ms" if probe["rtt_avg"] is not None else "-"
+            loss_str = f"{probe['loss']:.1f}%" if probe["loss"] is not None else "-"
+            ts = probe.get("timestamp")
+            ts_str = datetime.fromtimestamp(ts, timezone.utc).strftime("%Y-%m-%d %H:%M:%S") if ts else "-"
+            source = str(probe.get("source_address") or "-")
+            line = f"{probe['probe_id']:<10}{source:<40}{rtt_str:>11}{loss_str:>8}  {ts_str:<20}"
+            self._addstr(screen, y, 0, line[:width - 1])
+
+    @staticmethod
+    def _addstr(screen, y, x, text, attr=curses.A_NORMAL) -> None:
+        # curses raises when writing into the bottom-right cell; ignore clipping errors
+        try:
+            screen.addstr(y, x, text, attr)
+        except curses.error:
+            pass
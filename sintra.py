@@ -1,19 +1,73 @@
 import argparse
 import sys
 import json
+import threading
 import logging
 import re
+import yaml
+import requests
+from concurrent.futures import ThreadPoolExecutor
 from pathlib import Path
 from datetime import datetime, timedelta, timezone
-from measurement_client.client import SintraMeasurementClient
+from measurement_client.client import (
+    SintraMeasurementClient, DEFAULT_API_BASE, DEFAULT_REQUEST_TIMEOUT, MAX_PROBES_PER_MEASUREMENT, STDIN_CONFIG,
+    DEFAULT_MAX_RETRIES, DEFAULT_RETRY_DELAY, COUNTRY_NAMES, CREATE_TYPES, MEASUREMENT_STATUSES, PLAN_ACTIONS,
+    PROBE_AREAS, MAX_DEFINITIONS_PER_REQUEST, ResultsTimeoutError,
+    measurement_summary, parse_schedule_time, saved_measurement_summary, validate_api_base,
+    validate_proxy
+)
 from measurement_client.logger import logger
+from measurement_client.exporters import (
+    EXPORT_FIELDS, ENRICHED_FIELDS, EXPORT_FORMATS, TABLE_FORMAT, MATRIX_FORMAT, CHART_FORMAT,
+    DEFAULT_MATRIX_RESOLUTION, PIVOT_METRICS, DEFAULT_PIVOT_METRIC, SPLIT_KEYS, RowWriter, SplitRowWriter, result_rows,
+    annotate_rows, write_rows, result_matrix, write_matrix, write_chartjs, dump_yaml, compare_probe_rows
+)
+from measurement_client.streaming import DEFAULT_REORDER_BUFFER, ReorderBuffer
+from measurement_client.atlas_stream import DEFAULT_STREAM_URL, STREAM_CONNECT_TIMEOUT, AtlasResultStream
+from measurement_client.api_metrics import DEFAULT_METRICS_PORT, RttHistogram, serve_metrics
+from measurement_client.errors import (
+    ApiError, QuotaExceededError, RateLimitedError, UnauthorizedError, ValidationError
+)
+from measurement_client.migrations import CURRENT_CONFIG_VERSION, migrate_config
+from measurement_client.processors import RESULT_SCHEMAS, summarize_result, validate_result_schema
+from measurement_client.probes import (
+    PARTICIPATION_WARN_RATIO, PROBE_STATUSES, probe_participation, participation_is_low, probe_query_filters,
+    probe_summary, probe_tags, parse_asn, set_definition_probe_ids
+)
 from event_manager.eventmanager import SintraEventManager
 from event_manager.anomaly_types import ANOMALY_TYPES
+from completion import COMPLETION_SHELLS, completion_script
+
+LOG_LEVELS = ['DEBUG', 'INFO', 'WARNING', 'ERROR']
+
+# Formats status can print its counts in
+STATUS_FORMATS = ['json', 'yaml']
+
+# Days of credit left below which the credits command warns
+DEFAULT_CREDIT_WARN_DAYS = 7
+
+# Seconds create --oneoff --wait waits for each one-off to finish
+DEFAULT_ONEOFF_WAIT_TIMEOUT = 900
+
+# Seconds stop --wait waits for each measurement to be reported stopped
+DEFAULT_STOP_WAIT_TIMEOUT = 300
+
+# Probes modify requests from a country, ASN, prefix or area by default
+DEFAULT_MODIFY_PROBE_COUNT = 5
+
+# Markers of each planned change in plan/apply output, after Terraform's
+PLAN_SYMBOLS = {"create": "+", "modify": "~", "replace": "-/+", "stop": "-", "unchanged": ""}
+
+# Number of latest results checked by results validate-schema by default
+DEFAULT_SCHEMA_SAMPLE_SIZE = 100
+
+# Probes listed by probes search by default
+DEFAULT_PROBE_SEARCH_LIMIT = 50
 
 
 def setup_logging(log_level: str) -> None:
     numeric_level = getattr(logging, log_level.upper(), None)
-    if not isinstance(numeric_level, int):
+    if log_level.upper() not in LOG_LEVELS or not isinstance(numeric_level, int):
         raise ValueError(f'Invalid log level: {log_level}')
     
     logging.getLogger().setLevel(numeric_level)
@@ -30,10 +84,61 @@ def create_parser():
     # Global options
     parser.add_argument(
         '--log-level',
-        choices=['DEBUG', 'INFO', 'WARNING', 'ERROR'],
+        choices=LOG_LEVELS,
         default='INFO',
         help='Set logging level (default: INFO)'
     )
+    parser.add_argument(
+        '--api-base',
+        default=DEFAULT_API_BASE,
+        help=f'RIPE Atlas API base URL, e.g. a staging or mock server (default: {DEFAULT_API_BASE})'
+    )
+    parser.add_argument(
+        '--proxy',
+        help='Send API requests through this proxy, e.g. http://proxy.example.com:3128 (overrides HTTPS_PROXY)'
+    )
+    parser.add_argument(
+        '--ca-bundle',
+        help='CA bundle to verify the API\'s TLS certificate with, e.g. a corporate root (overrides REQUESTS_CA_BUNDLE)'
+    )
+    parser.add_argument(
+        '--no-http-cache',
+        action='store_true',
+        help='Download measurement metadata and results again instead of revalidating cached copies'
+    )
+    parser.add_argument(
+        '--debug-http',
+        action='store_true',
+        help='Dump every API request and response to stderr (credentials redacted), for bug reports'
+    )
+    parser.add_argument(
+        '--api-key-file',
+        help='Read the RIPE Atlas API key from this file, e.g. a mounted secret (overrides RIPE_ATLAS_API_KEY)'
+    )
+    parser.add_argument(
+        '--timeout',
+        type=float,
+        default=DEFAULT_REQUEST_TIMEOUT,
+        help=f'Per-request RIPE Atlas API timeout in seconds (default: {DEFAULT_REQUEST_TIMEOUT})'
+    )
+    parser.add_argument(
+        '--connect-timeout',
+        type=float,
+        help='Seconds allowed for connecting to the API or the result stream, so an unreachable host fails '
+             'fast (default: the --timeout for API requests, 30 for the stream)'
+    )
+    parser.add_argument(
+        '--max-retries',
+        type=int,
+        default=DEFAULT_MAX_RETRIES,
+        help=f'Times to retry an API request after a network error, 429 or 5xx (default: {DEFAULT_MAX_RETRIES})'
+    )
+    parser.add_argument(
+        '--retry-delay',
+        type=float,
+        default=DEFAULT_RETRY_DELAY,
+        help=f'Seconds before the first retry, doubled per retry with jitter (default: {DEFAULT_RETRY_DELAY:g})'
+    )
     
     subparsers = parser.add_subparsers(dest='command', required=True, help='Available commands')
     
@@ -42,15 +147,110 @@ def create_parser():
     create_parser.add_argument(
         '--config', 
         default='measurement_client/create_config.yaml',
-        help='Configuration file path (default: measurement_client/create_config.yaml)'
+        help='Configuration file path, or - to read it from stdin (default: measurement_client/create_config.yaml)'
     )
     create_parser.add_argument(
         '--dry-run',
         action='store_true',
         help='Validate configuration without creating measurements'
     )
+    create_parser.add_argument(
+        '--remote',
+        action='store_true',
+        help='With --dry-run, also ask the API to validate each definition (creates nothing)'
+    )
+    create_parser.add_argument(
+        '--max-probes-per-measurement',
+        type=int,
+        default=MAX_PROBES_PER_MEASUREMENT,
+        help=f'Reject definitions requesting more probes than this (default: {MAX_PROBES_PER_MEASUREMENT}, the Atlas limit)'
+    )
+    create_parser.add_argument(
+        '--force',
+        action='store_true',
+        help='Create every definition even if a measurement Sintra created for it is still running'
+    )
+    create_parser.add_argument(
+        '--batch',
+        action='store_true',
+        help='Send definitions sharing probes, timing and billing in one API request (up to '
+             f'{MAX_DEFINITIONS_PER_REQUEST} per request) instead of one request per entry'
+    )
+    create_parser.add_argument(
+        '--probe-set-file',
+        help='YAML library of named probe sets that definitions reference with probe_set_ref'
+    )
+    create_parser.add_argument(
+        '--no-auto-tags',
+        action='store_true',
+        help='Only attach the tags listed in the config, not the derived type/target/interval tags'
+    )
+    create_parser.add_argument(
+        '--no-config-tag',
+        action='store_true',
+        help='Do not tag measurements with cfg-<name> derived from the config file name'
+    )
+    create_parser.add_argument(
+        '--summary-file',
+        help='Write a JSON summary of the run (created IDs, failures, credits, duration) to this path'
+    )
+    create_parser.add_argument(
+        '--oneoff',
+        action='store_true',
+        help='Create every definition as a one-off measurement, as if it set is_oneoff: true'
+    )
+    create_parser.add_argument(
+        '--wait',
+        action='store_true',
+        help='With --oneoff, wait for each measurement to finish and print its results as JSON'
+    )
+    create_parser.add_argument(
+        '--wait-timeout',
+        type=int,
+        default=DEFAULT_ONEOFF_WAIT_TIMEOUT,
+        help=f'Maximum seconds to wait per measurement with --wait (default: {DEFAULT_ONEOFF_WAIT_TIMEOUT})'
+    )
+
+    # Scheduled create command
+    schedule_parser = subparsers.add_parser('schedule', help='Run create on a cron schedule until interrupted')
+    schedule_parser.add_argument(
+        '--cron',
+        required=True,
+        help='Five-field cron expression in UTC, e.g. "0 * * * *" for hourly'
+    )
+    schedule_parser.add_argument(
+        '--config',
+        default='measurement_client/create_config.yaml',
+        help='Configuration file path, re-read on every run (default: measurement_client/create_config.yaml)'
+    )
+    schedule_parser.add_argument(
+        '--probe-set-file',
+        help='YAML library of named probe sets that definitions reference with probe_set_ref'
+    )
+    schedule_parser.add_argument(
+        '--no-auto-tags',
+        action='store_true',
+        help='Only attach the tags listed in the config, not the derived type/target/interval tags'
+    )
+    schedule_parser.add_argument(
+        '--no-config-tag',
+        action='store_true',
+        help='Do not tag measurements with cfg-<name> derived from the config file name'
+    )
+    schedule_parser.add_argument(
+        '--metrics-port',
+        type=int,
+        help=f'Serve sintra_api_* request/retry/error metrics for Prometheus at /metrics on this port '
+             f'(prometheus.yml scrapes {DEFAULT_METRICS_PORT})'
+    )
     
     fetch_parser = subparsers.add_parser('fetch', help='Fetch measurement results from RIPE Atlas')
+    fetch_parser.add_argument(
+        'measurement',
+        type=int,
+        nargs='?',
+        help='Measurement ID to fetch (same as --measurement-id)'
+    )
     fetch_parser.add_argument(
         '--config', 
         default='measurement_client/fetch_config.yaml',
@@ -71,6 +271,116 @@ def create_parser():
         type=str,
         help='Fetch results from the last N time units (e.g., 30m, 24h, 7d, 2w)'
     )
+    fetch_parser.add_argument(
+        '--start',
+        help='Fetch results from this time on, as RFC 3339 or unix seconds (cannot be combined with --since)'
+    )
+    fetch_parser.add_argument(
+        '--stop',
+        help='Fetch results up to this time, as RFC 3339 or unix seconds'
+    )
+    fetch_parser.add_argument(
+        '--probes',
+        help='Fetch only results of these comma-separated probe IDs'
+    )
+    fetch_parser.add_argument(
+        '--wait',
+        action='store_true',
+        help='Poll until the measurement has results instead of returning empty (useful right after create)'
+    )
+    fetch_parser.add_argument(
+        '--wait-timeout',
+        type=int,
+        default=900,
+        help='Maximum seconds to wait for results with --wait (default: 900)'
+    )
+    fetch_parser.add_argument(
+        '--output',
+        choices=EXPORT_FORMATS,
+        help='Also export per-probe result rows in this format (table prints aligned columns), a probe x time '
+             'matrix (CSV) with matrix, or Chart.js datasets (JSON) with chartjs'
+    )
+    fetch_parser.add_argument(
+        '--file',
+        help='Write exported rows to this file instead of stdout (requires --output)'
+    )
+    fetch_parser.add_argument(
+        '--enrich',
+        action='store_true',
+        help='Attach probe hardware version and anchor flag to results and export firmware/hardware columns'
+    )
+    fetch_parser.add_argument(
+        '--only-requested-probes',
+        action='store_true',
+        help='Keep only results from the probe IDs recorded when the measurement was created (all probes if none were)'
+    )
+    fetch_parser.add_argument(
+        '--stream',
+        action='store_true',
+        help='Stream one row per raw result straight to the export with bounded memory (requires --output)'
+    )
+    fetch_parser.add_argument(
+        '--concurrency',
+        type=int,
+        default=1,
+        help='Fetch this many measurements at once; with --stream, rows are still written in measurement order '
+             '(default: 1)'
+    )
+    fetch_parser.add_argument(
+        '--reorder-buffer',
+        type=int,
+        default=DEFAULT_REORDER_BUFFER,
+        help=f'With --concurrency, rows held for measurements not yet due before their fetches pause (default: {DEFAULT_REORDER_BUFFER})'
+    )
+    fetch_parser.add_argument(
+        '--split-by',
+        choices=list(SPLIT_KEYS),
+        help='Stream rows into one <value>.jsonl file per probe under --output-dir, appending (requires --output json)'
+    )
+    fetch_parser.add_argument(
+        '--output-dir',
+        help='Directory for --split-by files'
+    )
+    fetch_parser.add_argument(
+        '--incremental',
+        action='store_true',
+        help='Only fetch results not seen by a previous --incremental fetch, merging them into the saved '
+             'results (seen results are kept in measurement_client/results/last_seen.json)'
+    )
+    fetch_parser.add_argument(
+        '--resume',
+        action='store_true',
+        help='With --split-by, continue an interrupted fetch from its last completed page'
+    )
+    fetch_parser.add_argument(
+        '--resolution',
+        type=int,
+        default=DEFAULT_MATRIX_RESOLUTION,
+        help=f'Time bucket width in seconds for --output matrix/chartjs (default: {DEFAULT_MATRIX_RESOLUTION})'
+    )
+    fetch_parser.add_argument(
+        '--metric',
+        choices=list(PIVOT_METRICS),
+        default=DEFAULT_PIVOT_METRIC,
+        help=f'Value plotted by --output matrix/chartjs (default: {DEFAULT_PIVOT_METRIC})'
+    )
+    fetch_parser.add_argument(
+        '--annotate',
+        action='store_true',
+        help='Add an anomaly field to exported rows based on the thresholds below'
+    )
+    fetch_parser.add_argument(
+        '--latency-threshold',
+        type=float,
+        default=250.0,
+        help='Average RTT in ms above which an exported row is a latency_spike (default: 250)'
+    )
+    fetch_parser.add_argument(
+        '--loss-threshold',
+        type=float,
+        default=0.0,
+        help='Packet loss %% above which an exported row is packet_loss (default: 0)'
+    )
     
     # Event management commands
     detect_parser = subparsers.add_parser('detect', help='Detect anomalies in measurement results')
@@ -99,35 +409,619 @@ def create_parser():
     
     # Status command
     status_parser = subparsers.add_parser('status', help='Show current status of Sintra measurements and alerts')
-    
+    status_parser.add_argument(
+        '--output',
+        choices=STATUS_FORMATS,
+        help='Also print the status counts to stdout in this format'
+    )
+    status_parser.add_argument(
+        '--live',
+        action='store_true',
+        help='Also query the API for the status, probes, last result and credit use of every created measurement'
+    )
+
+    # Credits command
+    credits_parser = subparsers.add_parser(
+        'credits', help='Show the account credit balance, daily burn of ongoing measurements and days remaining'
+    )
+    credits_parser.add_argument(
+        '--warn-days',
+        type=float,
+        default=DEFAULT_CREDIT_WARN_DAYS,
+        help=f'Warn when the balance lasts fewer than this many days at the current burn (default: {DEFAULT_CREDIT_WARN_DAYS})'
+    )
+    credits_parser.add_argument(
+        '--output',
+        choices=STATUS_FORMATS,
+        help='Also print the credit report to stdout in this format'
+    )
+
+    # Measurement listing command
+    list_parser = subparsers.add_parser('list', help='List your RIPE Atlas measurements')
+    list_parser.add_argument('--status', choices=list(MEASUREMENT_STATUSES), help='Only measurements in this status')
+    list_parser.add_argument('--type', choices=CREATE_TYPES, help='Only measurements of this type')
+    list_parser.add_argument('--target', help='Only measurements whose target contains this text')
+    list_parser.add_argument(
+        '--tag',
+        action='append',
+        help='Only measurements with this tag (repeat to require several)'
+    )
+    list_parser.add_argument(
+        '--output',
+        choices=STATUS_FORMATS,
+        help='Print the measurements in this format instead of a table'
+    )
+    list_parser.add_argument(
+        '--local',
+        action='store_true',
+        help='List the measurements Sintra created from its saved state instead of querying the API'
+    )
+
+    # Status check command
+    statuscheck_parser = subparsers.add_parser(
+        'statuscheck', help='Show per-probe up/down state of a ping measurement from the Atlas status check'
+    )
+    statuscheck_parser.add_argument(
+        'measurement_id',
+        type=int,
+        help='Measurement ID to check'
+    )
+    statuscheck_parser.add_argument(
+        '--max-packet-loss',
+        type=float,
+        help='Mark a probe down above this packet loss percentage (Atlas default: 100)'
+    )
+    statuscheck_parser.add_argument(
+        '--lookback',
+        type=int,
+        help='Number of recent results per probe the check considers (Atlas default: 1)'
+    )
+    statuscheck_parser.add_argument(
+        '--output',
+        choices=STATUS_FORMATS,
+        help='Also print the full per-probe status to stdout in this format'
+    )
+
+    # Probe metadata cache command
+    probes_parser = subparsers.add_parser(
+        'probes', help='Search RIPE Atlas probes and manage the on-disk probe metadata cache'
+    )
+    probes_parser.add_argument(
+        'action',
+        choices=['sync', 'search'],
+        help='sync: download metadata of every Atlas probe into the cache used by enrichment and probe selection; '
+             'search: list probes matching the filters below'
+    )
+    probes_parser.add_argument('--country', help='search: ISO 3166-1 alpha-2 country code of the probes')
+    probes_parser.add_argument('--asn', help='search: autonomous system the probes are hosted in, e.g. 3320 or AS3320')
+    probes_parser.add_argument(
+        '--status',
+        choices=list(PROBE_STATUSES) + ['any'],
+        default='connected',
+        help='search: probe status (default: connected)'
+    )
+    probes_parser.add_argument('--tags', help='search: comma-separated probe tags every probe must have')
+    probes_parser.add_argument(
+        '--limit',
+        type=int,
+        default=DEFAULT_PROBE_SEARCH_LIMIT,
+        help=f'search: list at most this many probes (default: {DEFAULT_PROBE_SEARCH_LIMIT})'
+    )
+    probes_parser.add_argument(
+        '--output',
+        choices=STATUS_FORMATS,
+        help='search: print the probes in this format instead of a table'
+    )
+    probes_parser.add_argument(
+        '--write-config',
+        help='search: set the probes of a definition in this create config to the IDs found (comments are not kept)'
+    )
+    probes_parser.add_argument(
+        '--definition',
+        type=int,
+        default=0,
+        help='search: index of the definition --write-config updates (default: 0, the first)'
+    )
+
+    # Results inspection command
+    results_parser = subparsers.add_parser('results', help='Inspect raw measurement results')
+    results_parser.add_argument(
+        'action',
+        choices=['latest', 'validate-schema'],
+        help='latest: show the most recent result of each probe; '
+             'validate-schema: check a sample of results for the fields and types the parsers expect'
+    )
+    results_parser.add_argument('measurement_id', type=int, help='Measurement ID')
+    results_parser.add_argument(
+        '--sample',
+        type=int,
+        default=DEFAULT_SCHEMA_SAMPLE_SIZE,
+        help=f'Number of latest results to check (default: {DEFAULT_SCHEMA_SAMPLE_SIZE})'
+    )
+    results_parser.add_argument(
+        '--probes',
+        help='With latest, only show these comma-separated probe IDs'
+    )
+    results_parser.add_argument(
+        '--output',
+        choices=STATUS_FORMATS,
+        help='With latest, print the per-probe summaries in this format instead of a table'
+    )
+
+    # Stop command
+    stop_parser = subparsers.add_parser('stop', help='Stop running RIPE Atlas measurements')
+    stop_parser.add_argument(
+        'measurement_ids',
+        type=int,
+        nargs='*',
+        help='Measurement IDs to stop'
+    )
+    stop_parser.add_argument(
+        '--all',
+        action='store_true',
+        help='Stop every measurement created by Sintra (those with saved info in the results directory)'
+    )
+    stop_parser.add_argument(
+        '--expired',
+        action='store_true',
+        help='Stop the measurements created by Sintra that are still running past their saved stop time'
+    )
+    stop_parser.add_argument(
+        '--wait',
+        action='store_true',
+        help='Poll each measurement until Atlas reports it stopped and show its final status'
+    )
+    stop_parser.add_argument(
+        '--wait-timeout',
+        type=int,
+        default=DEFAULT_STOP_WAIT_TIMEOUT,
+        help=f'Maximum seconds to wait per measurement with --wait (default: {DEFAULT_STOP_WAIT_TIMEOUT})'
+    )
+
+    # Probe replacement command
+    reconcile_parser = subparsers.add_parser(
+        'reconcile', help='Replace disconnected probes of running measurements with probes matching their selector'
+    )
+    reconcile_parser.add_argument(
+        'measurement_ids',
+        type=int,
+        nargs='*',
+        help='Measurement IDs to reconcile (default: every measurement created by Sintra)'
+    )
+    reconcile_parser.add_argument(
+        '--dry-run',
+        action='store_true',
+        help='Report the disconnected probes and their replacements without changing the measurements'
+    )
+
+    # Probe set modification command
+    modify_parser = subparsers.add_parser(
+        'modify', help='Add probes to or remove probes from a running measurement through participation requests'
+    )
+    modify_parser.add_argument(
+        'measurement_id',
+        type=int,
+        help='Measurement ID to modify'
+    )
+    add_group = modify_parser.add_mutually_exclusive_group()
+    add_group.add_argument(
+        '--add-probes',
+        help='Add these comma-separated probe IDs'
+    )
+    add_group.add_argument(
+        '--add-country',
+        help='Add --count probes from this country code'
+    )
+    add_group.add_argument(
+        '--add-asn',
+        help='Add --count probes from this ASN (e.g. 3320 or AS3320)'
+    )
+    add_group.add_argument(
+        '--add-prefix',
+        help='Add --count probes from this IP prefix'
+    )
+    add_group.add_argument(
+        '--add-area',
+        choices=PROBE_AREAS,
+        help='Add --count probes from this Atlas area'
+    )
+    modify_parser.add_argument(
+        '--count',
+        type=int,
+        default=DEFAULT_MODIFY_PROBE_COUNT,
+        help=f'Number of probes to add with --add-country/--add-asn/--add-prefix/--add-area '
+             f'(default: {DEFAULT_MODIFY_PROBE_COUNT})'
+    )
+    modify_parser.add_argument(
+        '--remove-probes',
+        help='Remove these comma-separated probe IDs'
+    )
+
+    # Declarative plan/apply commands
+    plan_parser = subparsers.add_parser(
+        'plan', help='Show what apply would create, modify, replace or stop to match the create config'
+    )
+    apply_parser = subparsers.add_parser(
+        'apply', help='Create, modify, replace and stop measurements so the running ones match the create config'
+    )
+    for declarative_parser in (plan_parser, apply_parser):
+        declarative_parser.add_argument(
+            '--config',
+            default='measurement_client/create_config.yaml',
+            help='Configuration file path (default: measurement_client/create_config.yaml)'
+        )
+    plan_parser.add_argument(
+        '--output',
+        choices=STATUS_FORMATS,
+        help='Print the planned changes in this format instead of a summary'
+    )
+    apply_parser.add_argument(
+        '--yes', '--auto-approve',
+        dest='auto_approve',
+        action='store_true',
+        help='Apply the plan without asking for confirmation, e.g. from cron or CI'
+    )
+
+    # Orphaned measurement cleanup command
+    gc_parser = subparsers.add_parser(
+        'gc', help='Find running Sintra-tagged measurements that Sintra no longer tracks, and optionally stop them'
+    )
+    gc_parser.add_argument(
+        '--config',
+        help='Also count measurements that no definition of this create config matches as orphaned'
+    )
+    gc_parser.add_argument(
+        '--stop',
+        action='store_true',
+        help='Stop the orphaned measurements (default: only report them)'
+    )
+    gc_parser.add_argument(
+        '--output',
+        choices=STATUS_FORMATS,
+        help='Print the orphaned measurements in this format instead of a table'
+    )
+
+    # Compare command
+    compare_parser = subparsers.add_parser(
+        'compare', help='Compare one probe\'s RTT and loss across two measurements, timestamp by timestamp'
+    )
+    compare_parser.add_argument(
+        'measurement_a',
+        type=int,
+        help='First measurement ID (deltas are B - A)'
+    )
+    compare_parser.add_argument(
+        'measurement_b',
+        type=int,
+        help='Second measurement ID'
+    )
+    compare_parser.add_argument(
+        '--probe',
+        type=int,
+        required=True,
+        help='Probe ID to compare'
+    )
+    compare_parser.add_argument(
+        '--resolution',
+        type=int,
+        default=DEFAULT_MATRIX_RESOLUTION,
+        help=f'Time bucket width in seconds used to pair up results (default: {DEFAULT_MATRIX_RESOLUTION})'
+    )
+    compare_parser.add_argument(
+        '--since',
+        help='Only compare results from the last duration, e.g. 1h, 2d, 1w'
+    )
+
+    # Live dashboard command
+    tui_parser = subparsers.add_parser('tui', help='Interactive terminal dashboard with live RTT/loss per measurement')
+    tui_parser.add_argument(
+        '--config',
+        default='measurement_client/fetch_config.yaml',
+        help='Configuration file listing measurement_ids (default: measurement_client/fetch_config.yaml)'
+    )
+    tui_parser.add_argument(
+        '--measurement-id',
+        type=int,
+        action='append',
+        help='Measurement ID to monitor (repeatable, overrides config file)'
+    )
+    tui_parser.add_argument(
+        '--interval',
+        type=int,
+        default=60,
+        help='Seconds between result polls (default: 60)'
+    )
+
+    # Prometheus exporter command
+    exporter_parser = subparsers.add_parser(
+        'exporter', help='Serve RTTs of the latest results and API health as Prometheus metrics'
+    )
+    exporter_parser.add_argument(
+        '--config',
+        default='measurement_client/fetch_config.yaml',
+        help='Configuration file listing measurement_ids (default: measurement_client/fetch_config.yaml)'
+    )
+    exporter_parser.add_argument(
+        '--measurement-id',
+        type=int,
+        action='append',
+        help='Measurement ID to export (repeatable, overrides config file)'
+    )
+    exporter_parser.add_argument(
+        '--port',
+        type=int,
+        default=DEFAULT_METRICS_PORT,
+        help=f'Port to serve /metrics on (default: {DEFAULT_METRICS_PORT}, as in prometheus.yml)'
+    )
+    exporter_parser.add_argument(
+        '--interval',
+        type=int,
+        default=60,
+        help='Seconds between result polls (default: 60)'
+    )
+    exporter_parser.add_argument(
+        '--exemplars',
+        action='store_true',
+        help='Serve OpenMetrics with measurement_id/probe_id exemplars on the RTT histogram '
+             '(the scraper must accept OpenMetrics, e.g. Prometheus with exemplar storage enabled)'
+    )
+
+    # Result stream command
+    stream_parser = subparsers.add_parser(
+        'stream', help='Print results as JSON lines as they arrive on the RIPE Atlas result stream'
+    )
+    stream_parser.add_argument(
+        '--config',
+        default='measurement_client/fetch_config.yaml',
+        help='Configuration file listing measurement_ids (default: measurement_client/fetch_config.yaml)'
+    )
+    stream_parser.add_argument(
+        '--measurement-id',
+        type=int,
+        action='append',
+        help='Measurement ID to stream (repeatable, overrides config file)'
+    )
+    stream_parser.add_argument(
+        '--url',
+        default=DEFAULT_STREAM_URL,
+        help=f'WebSocket URL of the result stream (default: {DEFAULT_STREAM_URL})'
+    )
+
+    # Config migration command
+    migrate_parser = subparsers.add_parser('migrate', help='Upgrade a config file to the current schema version')
+    migrate_parser.add_argument(
+        '--config',
+        default='measurement_client/create_config.yaml',
+        help='Configuration file to migrate (default: measurement_client/create_config.yaml)'
+    )
+    migrate_target = migrate_parser.add_mutually_exclusive_group()
+    migrate_target.add_argument(
+        '--in-place',
+        action='store_true',
+        help='Overwrite the config file with the migrated version'
+    )
+    migrate_target.add_argument(
+        '--output',
+        help='Write the migrated config to this path (default: print to stdout)'
+    )
+
+    # Effective config export command
+    export_config_parser = subparsers.add_parser(
+        'export-config', help='Print the create config as create will act on it, after all resolution steps'
+    )
+    export_config_parser.add_argument(
+        '--config',
+        default='measurement_client/create_config.yaml',
+        help='Configuration file path, or - to read it from stdin (default: measurement_client/create_config.yaml)'
+    )
+    export_config_parser.add_argument(
+        '--probe-set-file',
+        help='YAML library of named probe sets that definitions reference with probe_set_ref'
+    )
+    export_config_parser.add_argument(
+        '--format',
+        choices=STATUS_FORMATS,
+        default='yaml',
+        help='Output format (default: yaml)'
+    )
+    export_config_parser.add_argument(
+        '--resolve-probes',
+        action='store_true',
+        help='Also resolve probe_query into probe IDs (queries the probes API)'
+    )
+
+    # Shell completion command
+    completion_parser = subparsers.add_parser('completion', help='Print a shell completion script')
+    completion_parser.add_argument(
+        'shell',
+        choices=COMPLETION_SHELLS,
+        help='Shell to generate completion for'
+    )
+
     return parser
 
+def client_from_args(args, **extra):
+    """A SintraMeasurementClient set up from the global API, transport and cache flags, plus extra."""
+    return SintraMeasurementClient(api_base=args.api_base, request_timeout=args.timeout,
+                                   api_key_file=args.api_key_file, debug_http=args.debug_http,
+                                   max_retries=args.max_retries, retry_delay=args.retry_delay, proxy=args.proxy,
+                                   ca_bundle=args.ca_bundle, connect_timeout=args.connect_timeout,
+                                   http_cache=not args.no_http_cache, **extra)
+
 # This function handles the create measurements command
 # It initializes the SintraMeasurementClient and creates measurements based on the provided configuration
 def handle_create_command(args):
+    summary = None
     try:
         logger.info("=== Creating RIPE Atlas Measurements ===")
         
-        # Check if config file exists
-        if not Path(args.config).exists():
+        # Check if config file exists ('-' reads it from stdin)
+        if args.config != STDIN_CONFIG and not Path(args.config).exists():
             logger.error(f"Configuration file not found: {args.config}")
             logger.info("Please create the configuration file or check the path")
+            summary = {"error": f"Configuration file not found: {args.config}"}
             return
         
-        client = SintraMeasurementClient(config_path=args.config)
+        client = client_from_args(args, config_path=args.config)
         
+        client.auto_tags = not args.no_auto_tags
+        client.tag_config_name = not args.no_config_tag
+        client.skip_duplicates = not args.force
+        client.batch_create = args.batch
+        client.probe_set_file = args.probe_set_file
+        if args.max_probes_per_measurement <= 0:
+            raise ValueError("--max-probes-per-measurement must be greater than zero")
+        client.max_probes = args.max_probes_per_measurement
+
+        if args.remote and not args.dry_run:
+            raise ValueError("--remote requires --dry-run")
+        if args.wait:
+            if not args.oneoff or args.dry_run:
+                raise ValueError("--wait requires --oneoff and cannot be combined with --dry-run")
+            if args.wait_timeout <= 0:
+                raise ValueError("--wait-timeout must be greater than zero")
+        client.oneoff = args.oneoff
+
         if args.dry_run:
             logger.info("Dry-run mode: Validating configuration only")
             client.load_config("create")
             logger.info("Configuration validation successful")
+            if args.remote:
+                report = client.validate_measurements_remote()
+                if report["invalid"]:
+                    raise ValueError(f"{len(report['invalid'])} measurement(s) rejected by remote validation")
+                if report["available"]:
+                    logger.info(f"Remote validation successful: {len(report['valid'])} measurement(s) accepted")
             return
         
-        client.create_measurements()
+        summary = client.create_measurements()
         logger.info("Measurement creation process completed")
+
+        if args.wait:
+            print(json.dumps(collect_oneoff_results(client, summary["created"], args.wait_timeout), indent=2))
         
     except Exception as e:
         logger.error(f"Failed to create measurements: {e}")
+        summary = {"error": str(e)}
         raise
+    finally:
+        # Written even when the run failed so CI always gets an artifact
+        if args.summary_file and not args.dry_run:
+            write_summary_file(args.summary_file, summary)
+
+
+def collect_oneoff_results(client, created, timeout):
+    """Wait for each created one-off and summarize its results per probe.
+
+    A measurement whose results never arrive or cannot be downloaded keeps
+    an error and no results rather than failing the others.
+    """
+    collected = []
+    for measurement in created:
+        measurement_id = measurement["measurement_id"]
+        entry = {key: measurement[key] for key in ("measurement_id", "target", "type")}
+        logger.info(f"Waiting up to {timeout}s for one-off measurement {measurement_id} to finish")
+        try:
+            results = client.wait_for_oneoff_results(measurement_id, timeout)
+        except (ResultsTimeoutError, requests.RequestException) as e:
+            logger.warning(f"[WARN] {e}")
+            entry["error"] = str(e)
+            results = []
+        entry["results"] = probe_result_summaries(results, measurement["type"])
+        collected.append(entry)
+    return collected
+
+
+def probe_result_summaries(results, measurement_type):
+    """Probe ID and timestamp of each raw result with its summarize_result summary."""
+    return [
+        dict({"probe_id": result.get("prb_id"), "timestamp": result.get("timestamp")},
+             **summarize_result(result, measurement_type))
+        for result in results
+    ]
+
+
+def handle_schedule_command(args):
+    """Run the create logic on a cron schedule in the foreground until interrupted."""
+    from measurement_client.scheduler import CronSchedule, Scheduler
+
+    schedule = CronSchedule(args.cron)
+    if not Path(args.config).exists():
+        logger.error(f"Configuration file not found: {args.config}")
+        return
+
+    # One client for every run so its connection pool is reused
+    client = client_from_args(args, config_path=args.config)
+    client.auto_tags = not args.no_auto_tags
+    client.tag_config_name = not args.no_config_tag
+    client.probe_set_file = args.probe_set_file
+
+    metrics_server = None
+    if args.metrics_port is not None:
+        metrics_server = serve_metrics([client.api_metrics, client.circuit_breaker], args.metrics_port)
+        logger.info(f"Serving API metrics at http://localhost:{metrics_server.server_port}/metrics")
+
+    scheduler = Scheduler(schedule, lambda: scheduled_create_run(client), summarize_create_run)
+    logger.info(f"Scheduling create with '{args.cron}' (UTC); press Ctrl+C to stop")
+    try:
+        scheduler.run_forever()
+    except KeyboardInterrupt:
+        logger.info("Stopping scheduler; cancelling the current run")
+        client.context.cancel()
+    finally:
+        scheduler.stop()
+        if metrics_server:
+            metrics_server.shutdown()
+            metrics_server.server_close()
+    logger.info(f"Scheduler stopped after {scheduler.runs} run(s), {scheduler.skipped} skipped")
+
+
+def scheduled_create_run(client):
+    """One schedule tick: stop measurements running past their saved stop time, then run create.
+
+    Definitions still running from an earlier tick are always skipped, so
+    ticks never pile up duplicate measurements.
+    """
+    client.skip_duplicates = True
+    try:
+        for measurement_id in client.expired_measurements():
+            client.stop_measurement(measurement_id)
+            logger.info(f"Stopped measurement {measurement_id}, past its stop time")
+    except requests.RequestException as e:
+        logger.error(f"Failed to stop expired measurements: {e}")
+    return client.create_measurements()
+
+
+def summarize_create_run(summary) -> str:
+    """One-line description of a create run summary for the scheduler log."""
+    summary = summary or {}
+    text = (f"{len(summary.get('created', []))} created, {len(summary.get('failed', []))} failed, "
+            f"{summary.get('timed_out', 0)} timed out")
+    if summary.get("skipped"):
+        text += f", {len(summary['skipped'])} already running"
+    if summary.get("error"):
+        text += f" (error: {summary['error']})"
+    return text
+
+
+def write_summary_file(path: str, summary) -> None:
+    """Write a run summary as JSON, filling in the fields a partial run may lack."""
+    summary = dict(summary or {})
+    summary.setdefault("created", [])
+    summary.setdefault("failed", [])
+    summary.setdefault("skipped", [])
+    summary.setdefault("timed_out", 0)
+    summary.setdefault("estimated_credits", 0)
+    summary["success"] = not summary["failed"] and "error" not in summary
+
+    try:
+        summary_path = Path(path)
+        summary_path.parent.mkdir(parents=True, exist_ok=True)
+        with open(summary_path, "w") as f:
+            json.dump(summary, f, indent=2)
+        logger.info(f"Run summary written to {summary_path}")
+    except OSError as e:
+        logger.error(f"Failed to write run summary to {path}: {e}")
 
 def parse_since_duration(since_str: str) -> int:
     """Parse a duration string like '30m', '24h', '7d', or '2w' into a Unix timestamp.
@@ -168,7 +1062,7 @@ def handle_fetch_command(args):
     try:
         logger.info("=== Fetching Measurement Results ===")
         
-        client = SintraMeasurementClient(config_path=args.config)
+        client = client_from_args(args, config_path=args.config)
         
         # Parse --since flag and set on client
         if args.since:
@@ -179,33 +1073,272 @@ def handle_fetch_command(args):
             except ValueError as e:
                 logger.error(str(e))
                 return
+
+        if args.measurement is not None:
+            if args.measurement_id is not None and args.measurement_id != args.measurement:
+                logger.error("Give the measurement ID either as an argument or with --measurement-id, not both")
+                return
+            args.measurement_id = args.measurement
+
+        try:
+            apply_fetch_filters(client, args)
+        except ValueError as e:
+            logger.error(str(e))
+            return
+
+        client.enrich = args.enrich
+        client.only_requested_probes = args.only_requested_probes
+        client.incremental = args.incremental
+
+        if args.wait:
+            if args.wait_timeout <= 0:
+                logger.error("--wait-timeout must be greater than zero")
+                return
+            client.wait_timeout = args.wait_timeout
+            logger.info(f"Waiting up to {args.wait_timeout}s for results to appear")
+        
+        if (args.file or args.annotate or args.stream) and not args.output:
+            logger.error("--file, --annotate and --stream require --output")
+            return
+
+        matrix = args.output in (MATRIX_FORMAT, CHART_FORMAT)
+        if matrix:
+            if args.annotate:
+                logger.error(f"--annotate cannot be combined with --output {args.output}")
+                return
+            if args.resolution <= 0:
+                logger.error("--resolution must be greater than zero")
+                return
+
+        if args.concurrency != 1 or args.reorder_buffer != DEFAULT_REORDER_BUFFER:
+            if args.split_by or matrix:
+                logger.error("--concurrency and --reorder-buffer cannot be combined with --split-by or "
+                             f"--output {args.output}")
+                return
+            if args.reorder_buffer != DEFAULT_REORDER_BUFFER and not args.stream:
+                logger.error("--reorder-buffer requires --stream")
+                return
+            if args.concurrency <= 0 or args.reorder_buffer <= 0:
+                logger.error("--concurrency and --reorder-buffer must be greater than zero")
+                return
+        client.fetch_concurrency = args.concurrency
+
+        if args.split_by:
+            if args.output != "json" or not args.output_dir:
+                logger.error("--split-by requires --output json and --output-dir")
+                return
+            if args.file:
+                logger.error("--split-by cannot be combined with --file")
+                return
+            if args.incremental:
+                logger.error("--incremental cannot be combined with --split-by; use --resume to continue a pull")
+                return
+        elif args.resume:
+            logger.error("--resume requires --split-by")
+            return
+
+        if args.output == TABLE_FORMAT and (args.stream or args.split_by):
+            logger.error("--output table cannot be streamed; drop --stream/--split-by or pick another format")
+            return
+
+        if args.stream or matrix or args.split_by:
+            if args.wait:
+                logger.error("--stream, --split-by and --output matrix/chartjs cannot be combined with --wait")
+                return
+            if args.all:
+                measurement_ids = client._get_saved_measurement_ids()
+            elif args.measurement_id or Path(args.config).exists():
+                measurement_ids = client.resolve_fetch_ids(args.measurement_id)
+            else:
+                logger.error(f"Configuration file not found: {args.config}")
+                return
+            if matrix:
+                export_result_matrix(client, measurement_ids, args)
+            else:
+                stream_fetched_results(client, measurement_ids, args)
+            logger.info("Fetch process completed")
+            return
         
+        fetched_ids = []
         if args.all:
             # Fetch all saved measurements, ignore config
             saved_ids = client._get_saved_measurement_ids()
             if saved_ids:
                 logger.info(f"Fetching all {len(saved_ids)} saved measurements")
-                for measurement_id in saved_ids:
-                    client.fetch_measurements(measurement_id)
+                fetched_ids = client.fetch_measurements(measurement_ids=saved_ids)
             else:
                 logger.warning("No saved measurements found")
         elif args.measurement_id:
             # Fetch specific measurement ID
-            client.fetch_measurements(args.measurement_id)
+            fetched_ids = client.fetch_measurements(args.measurement_id)
         else:
             # Use config file
             if not Path(args.config).exists():
                 logger.error(f"Configuration file not found: {args.config}")
                 logger.info("Please create the configuration file, use --measurement-id, or use --all")
                 return
-            client.fetch_measurements()
+            fetched_ids = client.fetch_measurements()
+
+        if args.output:
+            export_fetched_results(client, fetched_ids, args)
             
         logger.info("Fetch process completed")
         
+    except ResultsTimeoutError as e:
+        logger.error(f"Fetch failed: {e}")
+        sys.exit(1)
     except Exception as e:
         logger.error(f"Failed to fetch measurements: {e}")
         raise
 
+def parse_time_option(option: str, value: str) -> int:
+    """Unix seconds of a --start/--stop value given as unix seconds or RFC 3339. Raises ValueError."""
+    try:
+        return int(parse_schedule_time(int(value) if value.strip().isdigit() else value).timestamp())
+    except ValueError:
+        raise ValueError(f"Invalid {option} '{value}': use RFC 3339 (2024-01-01T00:00:00Z) or unix seconds") from None
+
+
+def apply_fetch_filters(client, args) -> None:
+    """Set the --start/--stop window and --probes filter on the client. Raises ValueError on bad values."""
+    if args.start is not None:
+        if args.since:
+            raise ValueError("--start cannot be combined with --since")
+        client.since_timestamp = parse_time_option("--start", args.start)
+    if args.stop is not None:
+        client.until_timestamp = parse_time_option("--stop", args.stop)
+        start = client.since_timestamp
+        if start is not None and client.until_timestamp <= start:
+            raise ValueError("--stop must be after the start of the window")
+    if args.probes:
+        client.probe_ids = parse_probe_id_list("--probes", args.probes)
+
+
+def parse_probe_id_list(option: str, value: str) -> list:
+    """Probe IDs of a comma-separated option value. Raises ValueError."""
+    try:
+        return [int(probe_id) for probe_id in value.split(",") if probe_id.strip()]
+    except ValueError:
+        raise ValueError(f"{option} must be comma-separated probe IDs, got '{value}'") from None
+
+
+def export_fetched_results(client, measurement_ids, args) -> None:
+    """Export the saved results of the fetched measurements as per-probe rows."""
+    rows = []
+    for measurement_id in measurement_ids:
+        results_file = client.fetched_measurements_dir / f"measurement_{measurement_id}_result.json"
+        try:
+            with open(results_file, "r") as f:
+                rows.extend(result_rows(json.load(f)))
+        except (json.JSONDecodeError, IOError) as e:
+            logger.error(f"Failed to read {results_file} for export: {e}")
+
+    if args.annotate:
+        annotate_rows(rows, args.latency_threshold, args.loss_threshold)
+
+    if args.file:
+        with open(args.file, "w", newline="") as f:
+            write_rows(rows, args.output, f)
+        logger.info(f"Exported {len(rows)} rows to {args.file}")
+    else:
+        write_rows(rows, args.output, sys.stdout)
+
+def stream_fetched_results(client, measurement_ids, args) -> None:
+    """Stream raw results straight into the export without saving processed results.
+
+    Rows are per raw result rather than aggregated per probe, and are written
+    as they arrive so memory stays bounded regardless of result size. With
+    --split-by they are appended to one JSON lines file per probe instead,
+    fetched page by page so an interrupted pull can be resumed.
+    """
+    fields = EXPORT_FIELDS + (ENRICHED_FIELDS if args.enrich else []) + (["anomaly"] if args.annotate else [])
+    stream = open(args.file, "w", newline="") if args.file else sys.stdout
+    try:
+        if args.split_by:
+            writer = SplitRowWriter(args.output_dir, args.split_by)
+        else:
+            writer = RowWriter(args.output, stream, fields)
+
+        def write(row):
+            if args.annotate:
+                annotate_rows([row], args.latency_threshold, args.loss_threshold)
+            writer.write(row)
+
+        if args.concurrency > 1:
+            stream_concurrently(client, measurement_ids, write, args.concurrency, args.reorder_buffer)
+        else:
+            for measurement_id in measurement_ids:
+                try:
+                    if args.split_by:
+                        count = client.stream_paged_results(measurement_id, writer, write, args.resume)
+                    else:
+                        count = client.stream_result_rows(measurement_id, write)
+                    logger.info(f"Streamed {count} rows for measurement {measurement_id}")
+                except Exception as e:
+                    logger.error(f"Failed to stream results for measurement {measurement_id}: {e}")
+        writer.close()
+    finally:
+        if args.file:
+            stream.close()
+
+    if args.split_by:
+        logger.info(f"Exported {writer.count} rows to {len(writer.files)} files in {args.output_dir}")
+    elif args.file:
+        logger.info(f"Exported {writer.count} rows to {args.file}")
+
+def stream_concurrently(client, measurement_ids, write, concurrency: int, reorder_buffer: int) -> None:
+    """Stream several measurements at once, writing their rows in measurement order.
+
+    Rows of the measurement currently due are written as they arrive; rows
+    of later measurements wait in a ReorderBuffer until all earlier ones
+    are done, so the output is the same as a sequential fetch.
+    """
+    ordered = ReorderBuffer(write, reorder_buffer)
+
+    def fetch(index, measurement_id):
+        try:
+            count = client.stream_result_rows(measurement_id, lambda row: ordered.put(index, row))
+            logger.info(f"Streamed {count} rows for measurement {measurement_id}")
+        except Exception as e:
+            logger.error(f"Failed to stream results for measurement {measurement_id}: {e}")
+        finally:
+            ordered.finish(index)
+
+    # The pool starts fetches in submission order, so the measurement due is always running
+    with ThreadPoolExecutor(max_workers=concurrency) as pool:
+        try:
+            for index, measurement_id in enumerate(measurement_ids):
+                pool.submit(fetch, index, measurement_id)
+            pool.shutdown(wait=True)
+        except KeyboardInterrupt:
+            # Unwind the running downloads at their next chunk rather than finishing them
+            client.context.cancel()
+            raise
+
+def export_result_matrix(client, measurement_ids, args) -> None:
+    """Export a probe x time matrix of --metric built from the raw results, as CSV or Chart.js JSON."""
+    rows = []
+    for measurement_id in measurement_ids:
+        try:
+            client.stream_result_rows(measurement_id, rows.append)
+        except Exception as e:
+            logger.error(f"Failed to fetch results for measurement {measurement_id}: {e}")
+
+    probes, times, values = result_matrix(rows, args.resolution, args.metric)
+
+    def write(stream):
+        if args.output == CHART_FORMAT:
+            write_chartjs(probes, times, values, stream, args.metric)
+        else:
+            write_matrix(probes, times, values, stream)
+
+    if args.file:
+        with open(args.file, "w", newline="") as f:
+            write(f)
+        logger.info(f"Exported {len(probes)}x{len(times)} matrix to {args.file}")
+    else:
+        write(sys.stdout)
+
 # This function handles the anomaly detection command
 # It initializes the event manager and runs the analysis on fetched measurement results
 def handle_detect_command(args):
@@ -456,6 +1589,623 @@ def plot():
     logger.info("Plot generation completed!")
     logger.info("Check 'visualization/plots/' directory for results")
 
+def monitored_measurement_ids(args, client):
+    """IDs from --measurement-id, else the config's measurement_ids, else the saved measurements."""
+    if args.measurement_id:
+        return args.measurement_id
+    measurement_ids = []
+    if Path(args.config).exists():
+        client.load_config("fetch")
+        measurement_ids = client.fetch_config.get('measurement_ids', [])
+    return measurement_ids or client._get_saved_measurement_ids()
+
+
+def poll_latest_rtts(client, measurement_ids, histogram: RttHistogram) -> int:
+    """Add the latest results of every measurement to the RTT histogram. Returns the new result count."""
+    added = 0
+    for measurement_id in measurement_ids:
+        try:
+            added += histogram.observe_results(measurement_id, client.fetch_latest_results(measurement_id))
+        except Exception as e:
+            logger.warning(f"Polling latest results of measurement {measurement_id} failed: {e}")
+    return added
+
+
+def handle_exporter_command(args):
+    """Serve Prometheus metrics until interrupted, polling the latest results on an interval."""
+    if args.interval <= 0:
+        logger.error("--interval must be greater than zero")
+        return
+
+    client = client_from_args(args, config_path=args.config)
+    measurement_ids = monitored_measurement_ids(args, client)
+    if not measurement_ids:
+        logger.error("No measurements to export. Use --measurement-id or list measurement_ids in the config")
+        return
+
+    histogram = RttHistogram()
+    server = serve_metrics([histogram, client.api_metrics, client.circuit_breaker], args.port,
+                           openmetrics=args.exemplars)
+    fmt = "OpenMetrics with exemplars" if args.exemplars else "Prometheus text format"
+    logger.info(f"Serving metrics for {len(measurement_ids)} measurement(s) at "
+                f"http://localhost:{server.server_port}/metrics ({fmt}); press Ctrl+C to stop")
+    stop = threading.Event()
+    try:
+        while not stop.is_set():
+            added = poll_latest_rtts(client, measurement_ids, histogram)
+            logger.debug(f"Polled latest results: {added} new")
+            stop.wait(args.interval)
+    except KeyboardInterrupt:
+        logger.info("Stopping exporter")
+    finally:
+        server.shutdown()
+        server.server_close()
+
+
+def handle_stream_command(args):
+    """Print each streamed result as one JSON line on stdout until interrupted."""
+    client = client_from_args(args, config_path=args.config)
+    measurement_ids = monitored_measurement_ids(args, client)
+    if not measurement_ids:
+        logger.error("No measurements to stream. Use --measurement-id or list measurement_ids in the config")
+        return
+
+    def emit(result):
+        print(json.dumps(result, separators=(",", ":")), flush=True)
+
+    stream = AtlasResultStream(measurement_ids, url=args.url, proxy=args.proxy, ca_bundle=args.ca_bundle,
+                               connect_timeout=args.connect_timeout or STREAM_CONNECT_TIMEOUT)
+    logger.info(f"Streaming results of {len(measurement_ids)} measurement(s); press Ctrl+C to stop")
+    try:
+        stream.run(emit)
+    except KeyboardInterrupt:
+        logger.info("Stopping stream")
+    finally:
+        stream.stop()
+
+
+def handle_tui_command(args):
+    """Handle the tui command for the live terminal dashboard."""
+    from measurement_client.tui import SintraDashboard
+
+    if args.interval <= 0:
+        logger.error("--interval must be greater than zero")
+        return
+
+    client = client_from_args(args, config_path=args.config)
+
+    measurement_ids = monitored_measurement_ids(args, client)
+    if not measurement_ids:
+        logger.error("No measurements to monitor. Use --measurement-id or list measurement_ids in the config")
+        return
+
+    logger.info(f"Starting dashboard for {len(measurement_ids)} measurement(s)")
+
+    # Log lines written to the terminal would corrupt the curses screen
+    logger.disabled = True
+    try:
+        SintraDashboard(client, measurement_ids, interval=args.interval).run()
+    finally:
+        logger.disabled = False
+
+
+def handle_export_config_command(args):
+    """Handle the export-config command by printing the normalized, redacted create config."""
+    if args.config != STDIN_CONFIG and not Path(args.config).exists():
+        logger.error(f"Configuration file not found: {args.config}")
+        return
+
+    client = client_from_args(args, config_path=args.config)
+    client.probe_set_file = args.probe_set_file
+    client.load_config("create")
+    config = client.effective_create_config(resolve_probes=args.resolve_probes)
+
+    if args.format == "json":
+        print(json.dumps(config, indent=2))
+    else:
+        dump_yaml(config, sys.stdout)
+
+
+def handle_migrate_command(args):
+    """Handle the migrate command by upgrading a config file to the current schema."""
+    config_path = Path(args.config)
+    if not config_path.exists():
+        logger.error(f"Configuration file not found: {args.config}")
+        return
+
+    with open(config_path, "r") as f:
+        config = yaml.safe_load(f) or {}
+
+    migrated, changes = migrate_config(config)
+    if not changes:
+        logger.info(f"{args.config} is already at config version {CURRENT_CONFIG_VERSION}; nothing to migrate")
+        return
+
+    for change in changes:
+        logger.info(f"  {change}")
+
+    output = yaml.safe_dump(migrated, sort_keys=False, default_flow_style=False)
+    target = config_path if args.in_place else (Path(args.output) if args.output else None)
+    if target is None:
+        print(output, end="")
+        return
+
+    with open(target, "w") as f:
+        f.write(output)
+    logger.info(f"Wrote migrated config ({len(changes)} change(s)) to {target}; comments are not preserved")
+
+
+def handle_completion_command(args):
+    """Handle the completion command by printing the script for the requested shell."""
+    print(completion_script(create_parser(), args.shell), end="")
+
+
+def handle_credits_command(args):
+    """Handle the credits command: balance, estimated burn rate and days remaining."""
+    if args.warn_days < 0:
+        logger.error("--warn-days cannot be negative")
+        return
+
+    client = client_from_args(args)
+    report = client.credit_report()
+
+    logger.info(f"Credit balance: {report['balance']:,}")
+    logger.info(f"Estimated daily burn: {report['daily_burn']:,} credits "
+                f"({report['ongoing_measurements']} ongoing measurement(s)), income: {report['daily_income']:,}")
+    days_remaining = report["days_remaining"]
+    if days_remaining is None:
+        logger.info("Days remaining: not being drawn down")
+    elif days_remaining < args.warn_days:
+        logger.warning(f"[WARN] Days remaining: {days_remaining} (below {args.warn_days:g})")
+    else:
+        logger.info(f"Days remaining: {days_remaining}")
+
+    if args.output == "yaml":
+        dump_yaml(report, sys.stdout)
+    elif args.output:
+        print(json.dumps(report, indent=2))
+
+
+def handle_list_command(args):
+    """Handle the list command by printing the API key owner's measurements matching the filters."""
+    if args.local:
+        list_saved_measurements(args)
+        return
+    filters = {}
+    if args.status:
+        filters["status"] = MEASUREMENT_STATUSES[args.status]
+    if args.type:
+        filters["type"] = args.type
+    if args.target:
+        filters["target__contains"] = args.target
+    if args.tag:
+        filters["tags"] = ",".join(args.tag)
+
+    client = client_from_args(args)
+    measurements = [measurement_summary(measurement) for measurement in client.list_measurements(filters)]
+    logger.info(f"Found {len(measurements)} measurement(s)")
+
+    if args.output == "yaml":
+        dump_yaml(measurements, sys.stdout)
+    elif args.output:
+        print(json.dumps(measurements, indent=2))
+    elif measurements:
+        print(f"{'ID':>10} {'Type':<10} {'Status':<18} {'Target':<30} Description")
+        for measurement in measurements:
+            print(f"{measurement['id']:>10} {measurement['type'] or '-':<10} {measurement['status'] or '-':<18} "
+                  f"{measurement['target'] or '-':<30} {measurement['description'] or ''}")
+
+
+def list_saved_measurements(args) -> None:
+    """Print the measurements Sintra created, from their saved info, matching the list filters."""
+    if args.status:
+        raise ValueError("--status needs the API and cannot be combined with --local")
+    client = client_from_args(args)
+    measurements = [
+        measurement for measurement in map(saved_measurement_summary, client.saved_measurements())
+        if (not args.type or measurement["type"] == args.type)
+        and (not args.target or args.target in str(measurement["target"] or ""))
+        and set(args.tag or []) <= set(measurement["tags"])
+    ]
+    logger.info(f"Found {len(measurements)} saved measurement(s)")
+
+    if args.output == "yaml":
+        dump_yaml(measurements, sys.stdout)
+    elif args.output:
+        print(json.dumps(measurements, indent=2))
+    elif measurements:
+        print(f"{'ID':>10} {'Type':<10} {'Created':<22} {'Target':<30} Description")
+        for measurement in measurements:
+            created_at = (measurement['created_at'] or '-')[:19]
+            print(f"{measurement['id']:>10} {measurement['type'] or '-':<10} {created_at:<22} "
+                  f"{measurement['target'] or '-':<30} {measurement['description'] or ''}")
+
+
+def handle_statuscheck_command(args):
+    """Handle the statuscheck command with a compact up/down summary of one measurement."""
+    if args.lookback is not None and args.lookback <= 0:
+        logger.error("--lookback must be greater than zero")
+        return
+
+    client = client_from_args(args)
+    check = client.status_check(args.measurement_id, args.max_packet_loss, args.lookback)
+    if check is None:
+        return
+
+    counts = check["counts"]
+    logger.info(f"Measurement {args.measurement_id}: {counts['up']} up, {counts['down']} down, "
+                f"{counts['unknown']} unknown")
+    for probe_id, probe in sorted(check["probes"].items(), key=lambda item: int(item[0])):
+        if probe["state"] == "down":
+            reasons = ", ".join(probe["alert_reasons"]) or "alert"
+            logger.warning(f"[WARN] Probe {probe_id} down ({reasons})")
+    if check["global_alert"]:
+        logger.warning(f"[WARN] Global alert: {check['total_alerts']} probe alert(s)")
+
+    if args.output == "yaml":
+        dump_yaml(check, sys.stdout)
+    elif args.output:
+        print(json.dumps(check, indent=2))
+
+
+def handle_probes_command(args):
+    """Handle the probes command; sync refreshes the probe metadata cache from Atlas, search lists probes."""
+    client = client_from_args(args)
+    if args.action == 'search':
+        search_probes(client, args)
+        return
+
+    logger.info("Downloading metadata of all RIPE Atlas probes...")
+    count = client.sync_probe_cache()
+    hours = client.probe_cache.ttl / 3600
+    logger.info(f"Cached {count} probes in {client.probe_cache.path} (valid for {hours:g}h)")
+
+
+def search_probes(client, args):
+    """List the probes matching the search filters, optionally writing their IDs into a create config."""
+    if args.limit <= 0:
+        raise ValueError("--limit must be greater than zero")
+    country = args.country.upper() if args.country else None
+    if country and country not in COUNTRY_NAMES:
+        raise ValueError(f"--country '{args.country}' is not an ISO 3166-1 alpha-2 country code")
+    query = {
+        "country": country,
+        "asn": parse_asn(args.asn) if args.asn else None,
+        "tags": probe_tags(args.tags.split(",")) if args.tags else [],
+        "status": PROBE_STATUSES.get(args.status)
+    }
+    filters = {key: value for key, value in probe_query_filters(query).items() if value is not None}
+
+    probes = [probe_summary(probe) for probe in client.search_probes(filters, max_results=args.limit)]
+    logger.info(f"Found {len(probes)} probe(s) matching {filters or 'no filters'}")
+
+    if args.output == "yaml":
+        dump_yaml(probes, sys.stdout)
+    elif args.output:
+        print(json.dumps(probes, indent=2))
+    elif probes:
+        print(f"{'ID':>7} {'Country':<7} {'ASN v4':>8} {'ASN v6':>8} {'Status':<15} Tags")
+        for probe in probes:
+            print(f"{probe['id']:>7} {probe['country_code'] or '-':<7} {probe['asn_v4'] or '-':>8} "
+                  f"{probe['asn_v6'] or '-':>8} {probe['status'] or '-':<15} {','.join(probe['tags'])}")
+
+    if args.write_config:
+        if not probes:
+            logger.warning(f"No probes found; {args.write_config} left unchanged")
+            return
+        config_path = Path(args.write_config)
+        with open(config_path, "r") as f:
+            config = yaml.safe_load(f) or {}
+        set_definition_probe_ids(config, args.definition, [probe["id"] for probe in probes])
+        with open(config_path, "w") as f:
+            f.write(yaml.safe_dump(config, sort_keys=False, default_flow_style=False))
+        logger.info(f"Set the probes of definition {args.definition} in {config_path} to {len(probes)} probe ID(s); "
+                    "comments are not preserved")
+
+
+def show_latest_results(args):
+    """Print the most recent result of each probe from the latest endpoint, without downloading history."""
+    probe_ids = parse_probe_id_list("--probes", args.probes) if args.probes else None
+    client = client_from_args(args)
+    measurement_info = client._get_measurement_info(args.measurement_id)
+    if not measurement_info:
+        raise RuntimeError(f"Could not get measurement {args.measurement_id}")
+    results = sorted(client.fetch_latest_results(args.measurement_id, probe_ids) or [],
+                     key=lambda result: result.get("prb_id") or 0)
+    summaries = probe_result_summaries(results, measurement_info.get("type"))
+
+    if args.output == "yaml":
+        dump_yaml(summaries, sys.stdout)
+    elif args.output:
+        print(json.dumps(summaries, indent=2))
+    elif summaries:
+        print(f"{'Probe':>8} {'Last result':<20} {'RTT avg':>9} {'Loss':>7}")
+        for summary in summaries:
+            timestamp = summary["timestamp"]
+            rtt = (summary.get("latency_stats") or {}).get("avg")
+            loss = summary.get("packet_loss_percentage")
+            when = datetime.fromtimestamp(timestamp, timezone.utc).strftime("%Y-%m-%dT%H:%M:%SZ") if timestamp else "-"
+            rtt_str = f"{rtt:.1f} ms" if rtt is not None else "-"
+            loss_str = f"{loss:.1f}%" if loss is not None else "-"
+            print(f"{summary['probe_id'] or '-':>8} {when:<20} {rtt_str:>9} {loss_str:>7}")
+    if not summaries:
+        logger.warning(f"Measurement {args.measurement_id} has no latest results"
+                       + (" for the given probes" if probe_ids else ""))
+
+
+def handle_results_command(args):
+    """Handle the results command; latest shows each probe's newest result, validate-schema reports parser drift."""
+    if args.action == "latest":
+        show_latest_results(args)
+        return
+    if args.sample <= 0:
+        logger.error("--sample must be greater than zero")
+        return
+
+    client = client_from_args(args)
+    measurement_info = client._get_measurement_info(args.measurement_id)
+    if not measurement_info:
+        raise RuntimeError(f"Could not get measurement {args.measurement_id}")
+    measurement_type = measurement_info.get("type")
+    results = client.fetch_latest_results(args.measurement_id)[:args.sample]
+    if not results:
+        logger.warning(f"Measurement {args.measurement_id} has no results to check")
+        return
+    if measurement_type not in RESULT_SCHEMAS:
+        logger.warning(f"No parser schema for {measurement_type} results; only checking the common fields")
+
+    drift = validate_result_schema(results, measurement_type)
+    if not drift:
+        logger.info(f"{len(results)} {measurement_type} result(s) of measurement {args.measurement_id} "
+                    "match the expected schema")
+        return
+    for entry in drift:
+        if entry["problem"] == "missing":
+            logger.error(f"{entry['field']}: missing from {entry['results']}/{len(results)} result(s) "
+                         f"(expected {entry['expected']})")
+        else:
+            logger.error(f"{entry['field']}: {entry['found']} in {entry['results']}/{len(results)} result(s) "
+                         f"(expected {entry['expected']})")
+    raise RuntimeError(f"Results of measurement {args.measurement_id} drifted from the expected schema "
+                       f"in {len(drift)} place(s); the parsers may need updating")
+
+
+def handle_stop_command(args):
+    """Handle the stop command, optionally waiting until each measurement is confirmed stopped."""
+    if args.wait and args.wait_timeout <= 0:
+        logger.error("--wait-timeout must be greater than zero")
+        return
+    if [bool(args.measurement_ids), args.all, args.expired].count(True) != 1:
+        raise ValueError("Give either measurement IDs, --all or --expired")
+
+    client = client_from_args(args)
+    if args.expired:
+        measurement_ids = client.expired_measurements()
+    else:
+        measurement_ids = args.measurement_ids or sorted(client._get_saved_measurement_ids())
+    if not measurement_ids:
+        logger.warning("No measurements created by Sintra to stop")
+        return
+
+    failed = 0
+    for measurement_id in measurement_ids:
+        try:
+            requested = client.stop_measurement(measurement_id)
+        except requests.RequestException as e:
+            logger.error(f"Failed to stop measurement {measurement_id}: {e}")
+            failed += 1
+            continue
+        if not requested:
+            continue
+
+        if not args.wait:
+            logger.info(f"Stop requested for measurement {measurement_id}")
+            continue
+        status = client.wait_until_stopped(measurement_id, args.wait_timeout)
+        if status and status["stopped"]:
+            logger.info(f"Measurement {measurement_id}: {status['name']}")
+        else:
+            state = status["name"] if status else "status unavailable"
+            logger.warning(f"[WARN] Measurement {measurement_id} not stopped after {args.wait_timeout}s ({state})")
+            failed += 1
+
+    if failed:
+        raise RuntimeError(f"{failed} of {len(measurement_ids)} measurement(s) could not be confirmed stopped")
+
+
+def handle_reconcile_command(args):
+    """Handle the reconcile command by swapping disconnected probes for replacements through participation requests."""
+    client = client_from_args(args)
+    measurement_ids = args.measurement_ids or sorted(client._get_saved_measurement_ids())
+    if not measurement_ids:
+        logger.warning("No measurements to reconcile")
+        return
+
+    failed = 0
+    for measurement_id in measurement_ids:
+        try:
+            report = client.replace_disconnected_probes(measurement_id, dry_run=args.dry_run)
+        except requests.RequestException as e:
+            logger.error(f"Failed to reconcile measurement {measurement_id}: {e}")
+            failed += 1
+            continue
+        if report and report["replaced"]:
+            logger.info(f"Measurement {measurement_id}: requested replacements for "
+                        f"{len(report['disconnected'])} disconnected probe(s)")
+
+    if failed:
+        raise RuntimeError(f"{failed} of {len(measurement_ids)} measurement(s) could not be reconciled")
+
+
+def handle_modify_command(args):
+    """Handle the modify command by growing or pruning a running measurement's probe set."""
+    if args.add_probes:
+        probes = {"ids": parse_probe_id_list("--add-probes", args.add_probes)}
+    else:
+        selectors = {"country": args.add_country, "asn": args.add_asn, "prefix": args.add_prefix,
+                     "area": args.add_area}
+        probes = {key: value for key, value in selectors.items() if value}
+        if probes:
+            if args.count < 1:
+                raise ValueError(f"--count must be a positive integer, got {args.count}")
+            probes["count"] = args.count
+    removed = parse_probe_id_list("--remove-probes", args.remove_probes) if args.remove_probes else []
+    if not probes and not removed:
+        raise ValueError("Give probes to add (--add-probes/--add-country/--add-asn/--add-prefix/--add-area) "
+                         "or to remove (--remove-probes)")
+
+    client = client_from_args(args)
+    if probes:
+        source = client.add_probes(args.measurement_id, probes)
+        logger.info(f"Measurement {args.measurement_id}: requested {source['requested']} probe(s) "
+                    f"from {source['type']} {source['value']}")
+    if removed:
+        client.remove_probes(args.measurement_id, removed)
+        logger.info(f"Measurement {args.measurement_id}: requested removal of probe(s) {removed}")
+
+
+def load_plan(args):
+    """Load the create config given to plan/apply and compare it with the running measurements."""
+    if not Path(args.config).exists():
+        raise ValueError(f"Configuration file not found: {args.config}")
+    client = client_from_args(args, config_path=args.config)
+    client.load_config("create")
+    return client, client.plan_measurements()
+
+
+def print_plan(plan) -> None:
+    """Print one line per planned change, then a count per action."""
+    for change in plan:
+        if change["action"] == "unchanged":
+            continue
+        line = (f"{PLAN_SYMBOLS[change['action']]:>3} {change['action']:<8} {change.get('measurement_id') or '':>10} "
+                f"{change['type']:<10} {change['target'] or '-'}")
+        if change.get("changes"):
+            line += f" (changed: {', '.join(change['changes'])})"
+        if change.get("add") or change.get("remove"):
+            line += f" (add probes {change.get('add') or []}, remove probes {change.get('remove') or []})"
+        print(line)
+    counts = {action: sum(1 for change in plan if change["action"] == action) for action in PLAN_ACTIONS}
+    print(f"Plan: {counts['create']} to create, {counts['modify']} to modify, {counts['replace']} to replace, "
+          f"{counts['stop']} to stop, {counts['unchanged']} unchanged")
+
+
+def handle_plan_command(args):
+    """Handle the plan command by showing how the running measurements differ from the create config."""
+    _, plan = load_plan(args)
+    if args.output == "yaml":
+        dump_yaml(plan, sys.stdout)
+    elif args.output:
+        print(json.dumps(plan, indent=2))
+    else:
+        print_plan(plan)
+
+
+def handle_apply_command(args):
+    """Handle the apply command by carrying out the plan for the create config once it is confirmed."""
+    client, plan = load_plan(args)
+    print_plan(plan)
+    if all(change["action"] == "unchanged" for change in plan):
+        logger.info("Running measurements already match the configuration")
+        return
+    if not args.auto_approve:
+        if not sys.stdin.isatty():
+            raise ValueError("apply asks for confirmation, but stdin is not a terminal; pass --yes to apply anyway")
+        if input("Apply these changes? Type 'yes' to continue: ").strip().lower() != "yes":
+            logger.info("Apply cancelled; nothing was changed")
+            return
+
+    summary = client.apply_plan(plan)
+    logger.info(f"Apply complete: {len(summary['created'])} created, {len(summary['modified'])} modified, "
+                f"{len(summary['stopped'])} stopped, {len(summary['failed'])} failed")
+    if summary["failed"]:
+        raise RuntimeError(f"{len(summary['failed'])} of {len(plan)} planned change(s) failed")
+
+
+def handle_gc_command(args):
+    """Handle the gc command by reporting, and with --stop stopping, orphaned Sintra measurements."""
+    if args.config and not Path(args.config).exists():
+        raise ValueError(f"Configuration file not found: {args.config}")
+    client = client_from_args(args, config_path=args.config)
+    if args.config:
+        client.load_config("create")
+    orphans = client.find_orphaned_measurements(check_config=bool(args.config))
+    daily_credits = sum(orphan["daily_credits"] for orphan in orphans)
+
+    if args.output == "yaml":
+        dump_yaml(orphans, sys.stdout)
+    elif args.output:
+        print(json.dumps(orphans, indent=2))
+    elif orphans:
+        print(f"{'ID':>10} {'Type':<10} {'Reason':<13} {'Credits/day':>11} Target")
+        for orphan in orphans:
+            print(f"{orphan['id']:>10} {orphan['type'] or '-':<10} {orphan['reason']:<13} "
+                  f"{orphan['daily_credits']:>11.0f} {orphan['target'] or '-'}")
+    if not orphans:
+        logger.info("No orphaned measurements found")
+        return
+    logger.info(f"Found {len(orphans)} orphaned measurement(s) using about {daily_credits:.0f} credits/day")
+    if not args.stop:
+        logger.info("Run 'sintra gc --stop' to stop them")
+        return
+
+    failed = 0
+    for orphan in orphans:
+        try:
+            client.stop_measurement(orphan["id"])
+            logger.info(f"Stopped orphaned measurement {orphan['id']}")
+        except requests.RequestException as e:
+            logger.error(f"Failed to stop measurement {orphan['id']}: {e}")
+            failed += 1
+    if failed:
+        raise RuntimeError(f"{failed} of {len(orphans)} orphaned measurement(s) could not be stopped")
+
+
+def _format_value(value) -> str:
+    return "-" if value is None else f"{value:.2f}"
+
+
+def _format_delta(value) -> str:
+    return "-" if value is None else f"{value:+.2f}"
+
+
+def handle_compare_command(args):
+    """Handle the compare command: one probe's RTT/loss in two measurements side by side."""
+    if args.resolution <= 0:
+        logger.error("--resolution must be greater than zero")
+        return
+
+    client = client_from_args(args)
+    if args.since:
+        client.since_timestamp = parse_since_duration(args.since)
+    params = dict(client._results_params(), probe_ids=[args.probe])
+
+    rows = {}
+    for measurement_id in (args.measurement_a, args.measurement_b):
+        measurement_rows = []
+        client.stream_result_rows(measurement_id, measurement_rows.append, params=params)
+        rows[measurement_id] = [row for row in measurement_rows if row.get("probe_id") == args.probe]
+
+    missing = [measurement_id for measurement_id, probe_rows in rows.items() if not probe_rows]
+    if missing:
+        for measurement_id in missing:
+            logger.error(f"Probe {args.probe} has no results in measurement {measurement_id}")
+        logger.error("Nothing to compare; pick a probe that took part in both measurements")
+        return
+
+    comparison = compare_probe_rows(rows[args.measurement_a], rows[args.measurement_b], args.resolution)
+    logger.info(f"Probe {args.probe}: measurement {args.measurement_a} (A) vs {args.measurement_b} (B), "
+                f"{len(comparison)} time bucket(s) of {args.resolution}s")
+
+    header = ("timestamp", "rtt_a", "rtt_b", "rtt_delta", "loss_a", "loss_b", "loss_delta")
+    print(f"{header[0]:<20} " + " ".join(f"{name:>10}" for name in header[1:]))
+    for entry in comparison:
+        cells = [_format_value(entry["rtt_a"]), _format_value(entry["rtt_b"]), _format_delta(entry["rtt_delta"]),
+                 _format_value(entry["loss_a"]), _format_value(entry["loss_b"]), _format_delta(entry["loss_delta"])]
+        timestamp = entry["timestamp"].isoformat().replace("+00:00", "Z")
+        print(f"{timestamp:<20} " + " ".join(f"{cell:>10}" for cell in cells))
+
+
 def handle_status_command(args):
     """Handle the status command to show a quick overview of Sintra's state."""
     try:
@@ -490,6 +2240,13 @@ def handle_status_command(args):
             logger.info(f"Last fetch: {last_fetch_time}")
         else:
             logger.info("Last fetch: Never")
+
+        # Probe participation of fetched measurements
+        if fetched_count:
+            report_probe_participation(fetched_files, created_dir)
+
+        # Live state of created measurements
+        live = report_live_status(args) if args.live else None
         
         # Anomaly detection status
         event_count = 0
@@ -517,6 +2274,22 @@ def handle_status_command(args):
         logger.info(f"Total anomalies: {total_anomalies}")
         logger.info(f"Critical alerts: {critical_alerts}")
         
+        if args.output:
+            status = {
+                "created_measurements": created_count,
+                "fetched_measurements": fetched_count,
+                "last_fetch": last_fetch_time,
+                "anomaly_files_analyzed": event_count,
+                "total_anomalies": total_anomalies,
+                "critical_alerts": critical_alerts
+            }
+            if live is not None:
+                status.update(live)
+            if args.output == "yaml":
+                dump_yaml(status, sys.stdout)
+            else:
+                print(json.dumps(status, indent=2))
+
         # Overall health
         if critical_alerts > 0:
             logger.warning(f"[WARN] Network health: {critical_alerts} critical issue(s) detected")
@@ -535,6 +2308,92 @@ def handle_status_command(args):
         raise
 
 
+def report_live_status(args) -> dict:
+    """Log the live state of every created measurement. Returns {"measurements", "daily_credits"} for --output."""
+    client = client_from_args(args)
+    overviews = []
+    for measurement_id in sorted(client._get_saved_measurement_ids()):
+        try:
+            overview = client.measurement_overview(measurement_id)
+        except requests.RequestException as e:
+            overview = None
+            logger.warning(f"Could not read latest results of measurement {measurement_id}: {e}")
+        if overview is None:
+            overviews.append({"measurement_id": measurement_id, "status": "Unavailable"})
+            continue
+        overviews.append(overview)
+        probes = f"{overview['probes_assigned'] or 0}/{overview['probes_requested'] or '?'} probes"
+        logger.info(f"Measurement {measurement_id} ({overview['type']} {overview['target']}): {overview['status']}, "
+                    f"{probes}, last result {overview['last_result'] or 'never'}, "
+                    f"~{overview['daily_credits']:,} credits/day")
+
+    counts = {}
+    for overview in overviews:
+        counts[overview["status"]] = counts.get(overview["status"], 0) + 1
+    daily_credits = round(sum(overview.get("daily_credits", 0) for overview in overviews), 1)
+    if counts:
+        logger.info("Live: " + ", ".join(f"{count} {name}" for name, count in sorted(counts.items())))
+    logger.info(f"Estimated daily credit use: {daily_credits:,}")
+    return {"measurements": overviews, "daily_credits": daily_credits}
+
+
+def report_probe_participation(result_files, created_dir: Path) -> None:
+    """Log requested vs participating probes, warning on measurements well below request."""
+    low = 0
+    for result_file in sorted(result_files):
+        try:
+            with open(result_file, "r") as f:
+                processed = json.load(f)
+        except (json.JSONDecodeError, IOError):
+            continue
+
+        measurement_id = processed.get("measurement_id")
+        saved_info = None
+        info_file = created_dir / f"measurement_{measurement_id}_info.json"
+        if info_file.exists():
+            try:
+                with open(info_file, "r") as f:
+                    saved_info = json.load(f)
+            except (json.JSONDecodeError, IOError):
+                pass
+
+        participation = probe_participation(processed, saved_info)
+        requested = participation["requested"]
+        if requested is None:
+            logger.info(f"Measurement {measurement_id}: {participation['participating']} probe(s) participated")
+            continue
+
+        message = (f"Measurement {measurement_id}: {participation['participating']}/{requested} "
+                   f"requested probes participated ({participation['ratio']:.0%})")
+        if participation_is_low(participation):
+            low += 1
+            logger.warning(f"[WARN] {message}")
+        else:
+            logger.info(message)
+
+    if low:
+        logger.warning(f"[WARN] {low} measurement(s) below {PARTICIPATION_WARN_RATIO:.0%} of requested probes")
+
+
+def report_api_error(error: ApiError) -> None:
+    """Log an API failure that ended a command, with what to do about it."""
+    if isinstance(error, UnauthorizedError):
+        logger.error(f"RIPE Atlas refused the request ({error}); check that RIPE_ATLAS_API_KEY or "
+                     "--api-key-file holds a valid key with permission for this action")
+    elif isinstance(error, QuotaExceededError):
+        logger.error(f"RIPE Atlas account limit reached ({error}); check the balance with 'sintra credits'")
+    elif isinstance(error, RateLimitedError):
+        wait = f"wait {error.retry_after:.0f}s" if error.retry_after is not None else "wait a while"
+        logger.error(f"RIPE Atlas is rate limiting this key ({error}); {wait} or set a lower rate_limit "
+                     "in the config")
+    elif isinstance(error, ValidationError) and error.field_errors:
+        logger.error(f"RIPE Atlas rejected the request: {error.detail or error.title or error.status_code}")
+        for field, message in error.field_errors:
+            logger.error(f"  {field}: {message}")
+    else:
+        logger.error(f"Command failed: {error}")
+
+
 # Main entry point for the Sintra
 def main():
     parser = create_parser()
@@ -543,16 +2402,37 @@ def main():
     # Set up logging
     try:
         setup_logging(args.log_level)
+        validate_api_base(args.api_base)
+        if args.timeout <= 0:
+            raise ValueError("--timeout must be greater than zero")
+        if args.max_retries < 0:
+            raise ValueError("--max-retries cannot be negative")
+        if args.retry_delay <= 0:
+            raise ValueError("--retry-delay must be greater than zero")
+        if args.proxy:
+            validate_proxy(args.proxy)
+        if args.ca_bundle and not Path(args.ca_bundle).is_file():
+            raise ValueError(f"--ca-bundle file '{args.ca_bundle}' does not exist")
+        if args.connect_timeout is not None and args.connect_timeout <= 0:
+            raise ValueError("--connect-timeout must be greater than zero")
     except ValueError as e:
         print(f"Error: {e}", file=sys.stderr)
         sys.exit(1)
     
+    # Completion scripts are sourced or redirected to a file, so print nothing else
+    if args.command == 'completion':
+        handle_completion_command(args)
+        return
+
     logger.info(f"Sintra Network Management Tool - Command: {args.command}")
     
     try:
         if args.command == 'create':
             handle_create_command(args)
             
+        elif args.command == 'schedule':
+            handle_schedule_command(args)
+
         elif args.command == 'fetch':
             handle_fetch_command(args)
         
@@ -567,7 +2447,58 @@ def main():
         
         elif args.command == 'status':
             handle_status_command(args)
-            
+
+        elif args.command == 'credits':
+            handle_credits_command(args)
+
+        elif args.command == 'list':
+            handle_list_command(args)
+
+        elif args.command == 'statuscheck':
+            handle_statuscheck_command(args)
+
+        elif args.command == 'probes':
+            handle_probes_command(args)
+
+        elif args.command == 'results':
+            handle_results_command(args)
+
+        elif args.command == 'stop':
+            handle_stop_command(args)
+
+        elif args.command == 'reconcile':
+            handle_reconcile_command(args)
+
+        elif args.command == 'modify':
+            handle_modify_command(args)
+
+        elif args.command == 'plan':
+            handle_plan_command(args)
+
+        elif args.command == 'apply':
+            handle_apply_command(args)
+
+        elif args.command == 'gc':
+            handle_gc_command(args)
+
+        elif args.command == 'compare':
+            handle_compare_command(args)
+
+        elif args.command == 'tui':
+            handle_tui_command(args)
+
+        elif args.command == 'exporter':
+            handle_exporter_command(args)
+
+        elif args.command == 'stream':
+            handle_stream_command(args)
+
+        elif args.command == 'export-config':
+            handle_export_config_command(args)
+
+        elif args.command == 'migrate':
+            handle_migrate_command(args)
+
         elif len(sys.argv) > 1 and sys.argv[1] == "plot":
             plot()
         else:
@@ -579,6 +2510,11 @@ def main():
     except KeyboardInterrupt:
         logger.info("Operation cancelled by user")
         sys.exit(1)
+    except ApiError as e:
+        report_api_error(e)
+        if args.log_level == 'DEBUG':
+            logger.exception("Full traceback:")
+        sys.exit(1)
     except Exception as e:
         logger.error(f"Command failed: {e}")
         if args.log_level == 'DEBUG':
@@ -0,0 +1,32 @@
+"""
+Shared fixtures and helpers for the Sintra test suite.
+"""
+import json
+import pytest
+import requests
+from unittest.mock import MagicMock
+from measurement_client.client import SintraMeasurementClient
+
+
+@pytest.fixture
+def client(tmp_path, monkeypatch):
+    """Create a client with a dummy API key whose results dirs live under tmp_path."""
+    monkeypatch.setenv("RIPE_ATLAS_API_KEY", "test-key")
+    monkeypatch.chdir(tmp_path)
+    return SintraMeasurementClient()
+
+
+def make_response(json_data=None, status_code=200, headers=None):
+    """Helper to create a mocked requests.Response."""
+    response = MagicMock()
+    response.status_code = status_code
+    response.headers = headers or {}
+    response.json.return_value = json_data
+    response.content = json.dumps(json_data).encode()
+    response.iter_content.return_value = [response.content]
+    response.text = str(json_data)
+    if status_code >= 400:
+        response.raise_for_status.side_effect = requests.HTTPError(
+            f"{status_code} Error", response=response
+        )
+    return response
@@ -8,6 +8,7 @@ import pytest
 from pathlib import Path
 from unittest.mock import patch, MagicMock
 from event_manager.eventmanager import SintraEventManager
+from event_manager.anomaly_utils import percentile
 
 
 @pytest.fixture
@@ -273,6 +274,43 @@ class TestPerTargetThresholds:
         assert "latency_spike" not in anomaly_types
 
 
+# === Test: Certificate Expiry ===
+
+def make_sslcert_result(probe_id, target, days_to_expiry):
+    """Helper to create a processed sslcert result."""
+    return {
+        "probe_id": probe_id,
+        "measurement_type": "sslcert",
+        "target_address": target,
+        "days_to_expiry": days_to_expiry
+    }
+
+
+class TestCertificateExpiry:
+    def test_expiring_certificate_alerts(self, event_manager):
+        """A certificate expiring in 10 days should alert (threshold=14 days)."""
+        data = make_measurement_data("test_cert", [
+            make_sslcert_result("probe_1", "193.0.6.139", 10.0),
+            make_sslcert_result("probe_2", "193.0.6.139", 90.0)
+        ])
+        events = [e for e in event_manager.analyze_measurement(data) if e["anomaly"] == "certificate_expiry"]
+        assert [(e["probe_id"], e["value"], e["severity"]) for e in events] == [("probe_1", 10.0, "warning")]
+
+    def test_expired_certificate_is_critical(self, event_manager):
+        data = make_measurement_data("test_cert", [make_sslcert_result("probe_1", "193.0.6.139", -1.5)])
+        events = event_manager.analyze_measurement(data)
+        assert [e["severity"] for e in events if e["anomaly"] == "certificate_expiry"] == ["critical"]
+
+    def test_per_target_expiry_threshold(self, event_manager):
+        event_manager.config["target_thresholds"] = {"193.0.6.139": {"certificate_expiry_days": 30.0}}
+        data = make_measurement_data("test_cert", [
+            make_sslcert_result("probe_1", "193.0.6.139", 20.0),
+            make_sslcert_result("probe_2", "10.0.0.1", 20.0)
+        ])
+        events = event_manager.analyze_measurement(data)
+        assert [e["probe_id"] for e in events if e["anomaly"] == "certificate_expiry"] == ["probe_1"]
+
+
 # === Test: Rolling Baseline ===
 
 class TestRollingBaseline:
@@ -445,3 +483,62 @@ class TestFullPipelineCorrelation:
         assert "latency_spike" in anomaly_types
         assert "route_change" in anomaly_types
         assert "correlated_routing_event" in anomaly_types
+
+
+# === Test: Tail Latency Detection ===
+
+# Mostly fast with an occasional slow reply: average 67ms, P95 481ms
+SKEWED_RTTS = [20.0] * 18 + [500.0, 480.0]
+
+
+class TestTailLatency:
+    def test_skewed_distribution_triggers_tail_event(self, event_manager):
+        """A few slow replies should raise high_tail_latency even though the average is fine."""
+        data = make_measurement_data("test_tail", [
+            make_ping_result("p1", "8.8.8.8", sum(SKEWED_RTTS) / len(SKEWED_RTTS), rtts=SKEWED_RTTS)
+        ])
+        events = event_manager.analyze_measurement(data)
+        anomalies = [e["anomaly"] for e in events]
+        assert "high_tail_latency" in anomalies
+        assert "latency_spike" not in anomalies
+
+        tail = next(e for e in events if e["anomaly"] == "high_tail_latency")
+        assert tail["metric"] == "ping_rtt_p95_ms"
+        assert tail["value"] == pytest.approx(481.0)
+        assert tail["threshold"] == 300.0
+
+    def test_percentile_is_configurable(self, event_manager):
+        """At P50 the same distribution is unremarkable."""
+        event_manager.config["thresholds"]["tail_latency_percentile"] = 50.0
+        data = make_measurement_data("test_tail", [
+            make_ping_result("p1", "8.8.8.8", 67.0, rtts=SKEWED_RTTS)
+        ])
+        events = event_manager.analyze_measurement(data)
+        assert "high_tail_latency" not in [e["anomaly"] for e in events]
+
+    def test_window_keeps_only_recent_samples(self, event_manager):
+        """Old slow samples outside the window no longer count."""
+        event_manager.config["thresholds"]["tail_latency_window"] = 12
+        rtts = [500.0] * 10 + [20.0] * 12
+        data = make_measurement_data("test_tail", [make_ping_result("p1", "8.8.8.8", 240.0, rtts=rtts)])
+        events = event_manager.analyze_measurement(data)
+        assert "high_tail_latency" not in [e["anomaly"] for e in events]
+
+    def test_too_few_samples_skipped(self, event_manager):
+        data = make_measurement_data("test_tail", [
+            make_ping_result("p1", "8.8.8.8", 340.0, rtts=[20.0, 500.0, 500.0])
+        ])
+        events = event_manager.analyze_measurement(data)
+        assert "high_tail_latency" not in [e["anomaly"] for e in events]
+
+    def test_per_target_threshold(self, event_manager):
+        event_manager.config["target_thresholds"] = {"8.8.8.8": {"tail_latency_ms": 600.0}}
+        data = make_measurement_data("test_tail", [
+            make_ping_result("p1", "8.8.8.8", 67.0, rtts=SKEWED_RTTS)
+        ])
+        events = event_manager.analyze_measurement(data)
+        assert "high_tail_latency" not in [e["anomaly"] for e in events]
+
+    @pytest.mark.parametrize("pct, expected", [(0, 1.0), (50, 2.5), (95, 3.85), (100, 4.0)])
+    def test_percentile_interpolates(self, pct, expected):
+        assert percentile([4.0, None, 1.0, 3.0, 2.0], pct) == pytest.approx(expected)
@@ -0,0 +1,183 @@
+"""
+Unit tests for the sintra_api_* request metrics, the RTT histogram and their Prometheus endpoint.
+"""
+import threading
+import pytest
+import requests
+from unittest.mock import patch, MagicMock
+import sintra
+from measurement_client.api_metrics import (
+    ApiMetrics, RttHistogram, OPENMETRICS_CONTENT_TYPE, render_metrics, serve_metrics, status_class
+)
+from tests.conftest import make_response
+
+
+def sample(metrics, line_prefix):
+    """Value of the first exposition line starting with line_prefix."""
+    for line in metrics.render().splitlines():
+        if line.startswith(line_prefix + " "):
+            return float(line.split()[-1])
+    return None
+
+
+# === Test: Counters ===
+
+class TestApiMetrics:
+    @pytest.mark.parametrize("status_code, label", [(200, "2xx"), (302, "3xx"), (429, "4xx"), (503, "5xx"),
+                                                    (None, "network")])
+    def test_status_class(self, status_code, label):
+        assert status_class(status_code) == label
+
+    def test_histogram_buckets_cumulative(self):
+        metrics = ApiMetrics()
+        for duration in (0.01, 0.3, 0.3, 120.0):
+            metrics.observe_request(200, duration)
+
+        assert sample(metrics, 'sintra_api_request_duration_seconds_bucket{le="0.05"}') == 1
+        assert sample(metrics, 'sintra_api_request_duration_seconds_bucket{le="0.5"}') == 3
+        assert sample(metrics, 'sintra_api_request_duration_seconds_bucket{le="60"}') == 3
+        assert sample(metrics, 'sintra_api_request_duration_seconds_bucket{le="+Inf"}') == 4
+        assert sample(metrics, "sintra_api_request_duration_seconds_count") == 4
+
+    def test_concurrent_updates_not_lost(self):
+        metrics = ApiMetrics()
+
+        def work():
+            for _ in range(1000):
+                metrics.observe_request(200, 0.1)
+                metrics.observe_retry()
+        threads = [threading.Thread(target=work) for _ in range(8)]
+        for thread in threads:
+            thread.start()
+        for thread in threads:
+            thread.join()
+
+        assert metrics.requests == {"2xx": 8000}
+        assert metrics.retries == 8000
+        assert metrics.duration_count == 8000
+
+
+# === Test: Client wiring ===
+
+class TestClientMetrics:
+    @patch("measurement_client.client.Context.wait")
+    @patch("measurement_client.client.requests.Session.request")
+    def test_retried_then_successful_request(self, mock_request, mock_sleep, client):
+        mock_request.side_effect = [make_response(status_code=503), make_response(status_code=429),
+                                    make_response({"id": 1})]
+
+        client._request_with_backoff(f"{client.base_url}/measurements/1/")
+
+        metrics = client.api_metrics
+        assert sample(metrics, 'sintra_api_requests_total{status_class="5xx"}') == 1
+        assert sample(metrics, 'sintra_api_requests_total{status_class="4xx"}') == 1
+        assert sample(metrics, 'sintra_api_requests_total{status_class="2xx"}') == 1
+        assert sample(metrics, "sintra_api_retries_total") == 2
+        assert metrics.errors == {}
+        assert sample(metrics, "sintra_api_request_duration_seconds_count") == 3
+
+    @patch("measurement_client.client.Context.wait")
+    @patch("measurement_client.client.requests.Session.request")
+    def test_final_failures_counted_by_status_class(self, mock_request, mock_sleep, client):
+        mock_request.return_value = make_response(status_code=404)
+        with pytest.raises(requests.HTTPError):
+            client._request_with_backoff(f"{client.base_url}/measurements/1/")
+
+        mock_request.return_value = None
+        mock_request.side_effect = requests.ConnectionError("refused")
+        with pytest.raises(requests.ConnectionError):
+            client._request_with_backoff(f"{client.base_url}/measurements/1/", max_retries=1)
+
+        metrics = client.api_metrics
+        assert metrics.errors == {"4xx": 1, "network": 1}
+        assert sample(metrics, 'sintra_api_requests_total{status_class="network"}') == 2
+        assert sample(metrics, "sintra_api_retries_total") == 1
+
+
+# === Test: RTT histogram exemplars ===
+
+def ping_result(probe_id, rtt, timestamp):
+    return {"type": "ping", "prb_id": probe_id, "timestamp": timestamp, "result": [{"rtt": rtt}]}
+
+
+class TestRttHistogram:
+    def test_exemplars_in_openmetrics_only(self):
+        histogram = RttHistogram()
+        histogram.observe(41.3, 123, 6042, 1700000000)
+        histogram.observe(3000.0, 123, 7)
+
+        openmetrics = histogram.render(openmetrics=True).splitlines()
+        classic = histogram.render()
+
+        assert ('sintra_rtt_milliseconds_bucket{le="50"} 1 '
+                '# {measurement_id="123",probe_id="6042"} 41.3 1700000000.000') in openmetrics
+        assert 'sintra_rtt_milliseconds_bucket{le="+Inf"} 2 # {measurement_id="123",probe_id="7"} 3000' in openmetrics
+        assert 'sintra_rtt_milliseconds_bucket{le="100"} 1' in openmetrics  # no exemplar on empty buckets
+        assert " # {" not in classic
+
+    def test_latest_exemplar_per_bucket(self):
+        histogram = RttHistogram()
+        histogram.observe(30.0, 1, 10, 1700000000)
+        histogram.observe(45.0, 2, 20, 1700000060)
+
+        assert '# {measurement_id="2",probe_id="20"} 45 1700000060.000' in histogram.render(openmetrics=True)
+
+    def test_same_latest_result_counted_once(self):
+        histogram = RttHistogram()
+
+        assert histogram.observe_results(123, [ping_result(1, 20.0, 100), ping_result(2, 30.0, 100)]) == 2
+        assert histogram.observe_results(123, [ping_result(1, 20.0, 100), ping_result(2, 35.0, 160)]) == 1
+        assert histogram.count == 3
+
+    def test_openmetrics_exposition(self):
+        metrics = ApiMetrics()
+        metrics.observe_request(200, 0.1)
+
+        body = render_metrics([RttHistogram(), metrics], openmetrics=True)
+
+        assert body.endswith("# EOF\n")
+        assert "# TYPE sintra_api_requests counter" in body
+        assert 'sintra_api_requests_total{status_class="2xx"} 1' in body
+        assert "# TYPE sintra_api_requests_total counter" in metrics.render()
+
+    def test_exporter_polls_each_measurement(self):
+        client = MagicMock()
+        client.fetch_latest_results.side_effect = [[ping_result(1, 12.0, 100)], requests.ConnectionError("down")]
+        histogram = RttHistogram()
+
+        assert sintra.poll_latest_rtts(client, [111, 222], histogram) == 1
+        assert '# {measurement_id="111",probe_id="1"} 12' in histogram.render(openmetrics=True)
+
+
+# === Test: /metrics endpoint ===
+
+class TestServeMetrics:
+    def test_metrics_served_over_http(self):
+        metrics = ApiMetrics()
+        metrics.observe_request(200, 0.2)
+        server = serve_metrics(metrics, port=0, host="127.0.0.1")
+        try:
+            base = f"http://127.0.0.1:{server.server_port}"
+            response = requests.get(f"{base}/metrics", timeout=5)
+            missing = requests.get(f"{base}/other", timeout=5)
+        finally:
+            server.shutdown()
+            server.server_close()
+
+        assert response.status_code == 200
+        assert response.headers["Content-Type"].startswith("text/plain")
+        assert 'sintra_api_requests_total{status_class="2xx"} 1' in response.text
+        assert missing.status_code == 404
+
+    def test_openmetrics_content_type(self):
+        histogram = RttHistogram()
+        histogram.observe(12.0, 111, 1, 1700000000)
+        server = serve_metrics([histogram], port=0, host="127.0.0.1", openmetrics=True)
+        try:
+            response = requests.get(f"http://127.0.0.1:{server.server_port}/metrics", timeout=5)
+        finally:
+            server.shutdown()
+            server.server_close()
+
+        assert response.headers["Content-Type"] == OPENMETRICS_CONTENT_TYPE
+        assert '# {measurement_id="111",probe_id="1"} 12 1700000000.000' in response.text
@@ -0,0 +1,95 @@
+"""
+Unit tests for decoding the certificates in sslcert results and summarizing those results.
+"""
+import pytest
+from measurement_client.certificates import decode_certificate, decode_certificate_chain
+from measurement_client.processors import process_sslcert_result, summarize_result
+
+# Self-signed test certificates: a leaf valid for 30 days and a root valid
+# until 2054, which DER encodes as GeneralizedTime rather than UTCTime
+LEAF_PEM = """\
+-----BEGIN CERTIFICATE-----
+MIIB0DCCAXWgAwIBAgIUYOr+2CB1UHQhAl7WQnzWTgbfOtEwCgYIKoZIzj0EAwIw
+PTELMAkGA1UEBhMCTkwxFDASBgNVBAoMC0V4YW1wbGUgT3JnMRgwFgYDVQQDDA93
+d3cuZXhhbXBsZS5jb20wHhcNMjYxMDE1MDkyMjAyWhcNMjYxMTE0MDkyMjAyWjA9
+MQswCQYDVQQGEwJOTDEUMBIGA1UECgwLRXhhbXBsZSBPcmcxGDAWBgNVBAMMD3d3
+dy5leGFtcGxlLmNvbTBZMBMGByqGSM49AgEGCCqGSM49AwEHA0IABI7zSUZHFAXY
+5dlIUxPbU2BpuZPPXvNuzbXaAFfsFCIkvGEdJHX2dQaOHVEA3ov9QJdENtkMmPsb
+zKcgYM09ZZijUzBRMB0GA1UdDgQWBBRXyyE2KwNGRZln9l67SUF361ZBBjAfBgNV
+HSMEGDAWgBRXyyE2KwNGRZln9l67SUF361ZBBjAPBgNVHRMBAf8EBTADAQH/MAoG
+CCqGSM49BAMCA0kAMEYCIQCO1Y1lwp8qkowSvpmx+PsvkxYsrzxyV+Km/xpxJldq
+RAIhAMiFuTNUKc1KymRaZvhymKx5fnuwOEpik0p+5/1QqFez
+-----END CERTIFICATE-----
+"""
+
+ROOT_PEM = """\
+-----BEGIN CERTIFICATE-----
+MIIBrzCCAVWgAwIBAgIUdgNvtzm/Ci1SQabdnlF48P7bddMwCgYIKoZIzj0EAwIw
+LDETMBEGA1UECgwKRXhhbXBsZSBDQTEVMBMGA1UEAwwMRXhhbXBsZSBSb290MCAX
+DTI2MTAxNTA5MjIwMloYDzIwNTQwMzAyMDkyMjAyWjAsMRMwEQYDVQQKDApFeGFt
+cGxlIENBMRUwEwYDVQQDDAxFeGFtcGxlIFJvb3QwWTATBgcqhkjOPQIBBggqhkjO
+PQMBBwNCAAR29hKw/qpbuVR48641h5WOu6QRAospgiUzv+Lj8sfAkgSbsvEU6xn3
+R0DdH86t1yKsmkyGQRlYQiirKWu7Jm5No1MwUTAdBgNVHQ4EFgQUHaGBtP96dbT9
+2YLMM3z8Uvqep6QwHwYDVR0jBBgwFoAUHaGBtP96dbT92YLMM3z8Uvqep6QwDwYD
+VR0TAQH/BAUwAwEB/zAKBggqhkjOPQQDAgNIADBFAiB83n2lnjOFatE7hWkEc+ss
+QalTSXCRfLdpqegT+eMcIAIhANjHIVO2ms3UktxZf+GcPqgnY7+Yd0P/X+NPGS+r
+7fMk
+-----END CERTIFICATE-----
+"""
+
+# 2026-11-04T09:22:02Z, ten days before the leaf expires
+RESULT_TIMESTAMP = 1793784122
+
+
+# === Test: Certificate decoding ===
+
+class TestDecodeCertificate:
+    def test_leaf_fields(self):
+        assert decode_certificate(LEAF_PEM) == {
+            "subject": {"country": "NL", "organization": "Example Org", "common_name": "www.example.com"},
+            "issuer": {"country": "NL", "organization": "Example Org", "common_name": "www.example.com"},
+            "not_before": "2026-10-15T09:22:02Z",
+            "not_after": "2026-11-14T09:22:02Z"
+        }
+
+    def test_generalized_time_validity(self):
+        certificate = decode_certificate(ROOT_PEM)
+        assert certificate["not_after"] == "2054-03-02T09:22:02Z"
+        assert certificate["subject"] == {"organization": "Example CA", "common_name": "Example Root"}
+
+    def test_bare_base64_accepted(self):
+        body = "".join(LEAF_PEM.splitlines()[1:-1])
+        assert decode_certificate(body)["not_after"] == "2026-11-14T09:22:02Z"
+
+    @pytest.mark.parametrize("pem", ["not a certificate", LEAF_PEM.replace("MIIB0DCC", "MIIB")])
+    def test_garbage_rejected(self, pem):
+        with pytest.raises(ValueError, match="Cannot decode certificate"):
+            decode_certificate(pem)
+
+    def test_chain_keeps_going_past_bad_entry(self):
+        chain = decode_certificate_chain([LEAF_PEM, "garbage", ROOT_PEM])
+        assert [c.get("subject", {}).get("common_name") for c in chain] == ["www.example.com", None, "Example Root"]
+        assert "error" in chain[1]
+
+
+# === Test: sslcert summaries ===
+
+class TestSslcertSummary:
+    def test_handshake_and_expiry(self):
+        summary = summarize_result({"type": "sslcert", "timestamp": RESULT_TIMESTAMP, "rt": 48.5, "ttc": 20.1,
+                                    "cert": [LEAF_PEM, ROOT_PEM]})
+
+        assert summary["latency_stats"]["avg"] == 48.5
+        assert summary["packet_loss_percentage"] == 0.0
+        assert summary["expires_at"] == "2026-11-14T09:22:02Z"
+        assert summary["days_to_expiry"] == 10.0
+        assert len(summary["certificates"]) == 2
+
+    def test_failed_handshake_counts_as_lost(self):
+        summary = process_sslcert_result({"type": "sslcert", "timestamp": RESULT_TIMESTAMP, "rt": 12.0,
+                                          "alert": {"level": 2, "description": 40}})
+
+        assert summary["packet_loss_percentage"] == 100.0
+        assert summary["latency_stats"]["avg"] is None
+        assert summary["certificates"] == []
+        assert summary["days_to_expiry"] is None
@@ -0,0 +1,121 @@
+"""
+Unit tests for the Atlas API circuit breaker and its use by the client.
+"""
+import pytest
+import requests
+from unittest.mock import patch
+from measurement_client.api_metrics import render_metrics
+from measurement_client.circuit_breaker import CLOSED, HALF_OPEN, OPEN, CircuitBreaker, CircuitOpenError
+from tests.conftest import make_response
+
+
+@pytest.fixture
+def clock():
+    now = [1000.0]
+    with patch("measurement_client.circuit_breaker.time.monotonic", lambda: now[0]):
+        yield now
+
+
+def trip(breaker):
+    for _ in range(breaker.failure_threshold):
+        breaker.before_request()
+        breaker.record(None)
+
+
+# === Test: States ===
+
+class TestCircuitBreaker:
+    def test_opens_after_consecutive_failures(self, clock):
+        breaker = CircuitBreaker(failure_threshold=3)
+        breaker.record(503)
+        breaker.record(404)  # the API answered, so it is up
+        breaker.record(None)
+        breaker.record(502)
+        assert breaker.state == CLOSED
+
+        breaker.record(500)
+        assert breaker.state == OPEN and breaker.trips == 1
+        with pytest.raises(CircuitOpenError, match="next attempt in 30s"):
+            breaker.before_request()
+        assert breaker.rejected == 1
+
+    def test_single_probe_when_half_open(self, clock):
+        breaker = CircuitBreaker(failure_threshold=1, open_timeout=30)
+        trip(breaker)
+
+        clock[0] += 30
+        breaker.before_request()  # the probe
+        assert breaker.state == HALF_OPEN
+        with pytest.raises(CircuitOpenError):
+            breaker.before_request()  # others still wait for its outcome
+
+        breaker.record(200)
+        assert breaker.state == CLOSED
+        breaker.before_request()
+
+    def test_failed_probes_back_off_exponentially(self, clock):
+        breaker = CircuitBreaker(failure_threshold=1, open_timeout=10, max_open_timeout=25)
+        trip(breaker)
+
+        for timeout in (10, 20, 25, 25):
+            clock[0] += timeout - 1
+            with pytest.raises(CircuitOpenError):
+                breaker.before_request()
+            clock[0] += 1
+            breaker.before_request()
+            breaker.record(None)
+            assert breaker.state == OPEN
+        assert breaker.trips == 1
+
+        clock[0] += 25
+        breaker.before_request()
+        breaker.record(200)
+        trip(breaker)
+        clock[0] += 10  # recovering resets the timeout
+        breaker.before_request()
+
+    @pytest.mark.parametrize("kwargs", [{"failure_threshold": 0}, {"open_timeout": 0},
+                                        {"open_timeout": 60, "max_open_timeout": 30}])
+    def test_invalid_settings_rejected(self, kwargs):
+        with pytest.raises(ValueError, match="Circuit breaker"):
+            CircuitBreaker(**kwargs)
+
+    def test_metrics(self, clock):
+        breaker = CircuitBreaker(failure_threshold=1)
+        trip(breaker)
+        with pytest.raises(CircuitOpenError):
+            breaker.before_request()
+
+        text = render_metrics([breaker])
+        assert 'sintra_api_circuit_state{state="open"} 1' in text
+        assert 'sintra_api_circuit_state{state="closed"} 0' in text
+        assert "sintra_api_circuit_trips_total 1" in text
+        assert "sintra_api_circuit_rejected_total 1" in text
+        assert "# TYPE sintra_api_circuit_trips counter" in render_metrics([breaker], openmetrics=True)
+
+
+# === Test: Client wiring ===
+
+class TestClientCircuitBreaker:
+    @patch("measurement_client.client.Context.wait")
+    @patch("measurement_client.client.requests.Session.request")
+    def test_outage_stops_requests(self, mock_request, mock_wait, client):
+        mock_request.return_value = make_response(status_code=503)
+
+        with pytest.raises(CircuitOpenError):
+            client._request_with_backoff(f"{client.base_url}/measurements/1/", max_retries=10)
+        assert mock_request.call_count == client.circuit_breaker.failure_threshold
+
+        with pytest.raises(requests.RequestException):
+            client._request_with_backoff(f"{client.base_url}/measurements/2/")
+        assert mock_request.call_count == client.circuit_breaker.failure_threshold
+
+    @patch("measurement_client.client.Context.wait")
+    @patch("measurement_client.client.requests.Session.request")
+    def test_failed_lookups_reported_not_raised(self, mock_request, mock_wait, client):
+        mock_request.side_effect = requests.ConnectionError("refused")
+        client.circuit_breaker = CircuitBreaker(failure_threshold=2)
+
+        assert client._get_measurement_info(1) is None
+        assert client._get_measurement_info(2) is None
+        assert mock_request.call_count == 2
@@ -0,0 +1,1230 @@
+"""
+Unit tests for the Sintra command line handlers.
+
+Handlers are exercised with argparse namespaces built by the real parser and a
+mocked measurement client, so no RIPE Atlas API calls are made.
+"""
+import io
+import json
+import os
+import time
+from pathlib import Path
+import pytest
+import requests
+import yaml
+from unittest.mock import patch, MagicMock
+import sintra
+from measurement_client.atlas_stream import DEFAULT_STREAM_URL, STREAM_CONNECT_TIMEOUT
+from measurement_client.tags import target_tag
+from tests.conftest import make_response
+
+
+@pytest.fixture
+def create_config(tmp_path):
+    config_file = tmp_path / "create_config.yaml"
+    config_file.write_text("measurements:\n  - type: ping\n    target: 8.8.8.8\n")
+    return config_file
+
+
+GOLDEN_DIR = Path(__file__).parent / "golden"
+
+
+def parse(*argv):
+    return sintra.create_parser().parse_args(list(argv))
+
+
+# === Test: Run summary file ===
+
+class TestSummaryFile:
+    @patch("sintra.SintraMeasurementClient")
+    def test_summary_written_after_create(self, mock_client_cls, create_config, tmp_path):
+        """The summary returned by the client should be written as JSON."""
+        mock_client_cls.return_value.create_measurements.return_value = {
+            "created": [{"measurement_id": 111, "target": "8.8.8.8", "type": "ping", "estimated_credits": 90}],
+            "failed": [{"index": 1, "target": "1.1.1.1", "type": "ping"}],
+            "estimated_credits": 90,
+            "duration_seconds": 1.5
+        }
+        summary_file = tmp_path / "out" / "summary.json"
+        args = parse("create", "--config", str(create_config), "--summary-file", str(summary_file))
+
+        sintra.handle_create_command(args)
+
+        data = json.loads(summary_file.read_text())
+        assert [c["measurement_id"] for c in data["created"]] == [111]
+        assert data["failed"][0]["target"] == "1.1.1.1"
+        assert data["estimated_credits"] == 90
+        assert data["duration_seconds"] == 1.5
+        assert data["success"] is False
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_summary_written_when_run_raises(self, mock_client_cls, create_config, tmp_path):
+        """An unexpected error should still leave a summary with the error."""
+        mock_client_cls.return_value.create_measurements.side_effect = RuntimeError("boom")
+        summary_file = tmp_path / "summary.json"
+        args = parse("create", "--config", str(create_config), "--summary-file", str(summary_file))
+
+        with pytest.raises(RuntimeError):
+            sintra.handle_create_command(args)
+
+        data = json.loads(summary_file.read_text())
+        assert data["error"] == "boom"
+        assert data["created"] == []
+        assert data["success"] is False
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_no_summary_without_flag(self, mock_client_cls, create_config, tmp_path):
+        mock_client_cls.return_value.create_measurements.return_value = {"created": [], "failed": []}
+        args = parse("create", "--config", str(create_config))
+
+        sintra.handle_create_command(args)
+
+        assert not list(tmp_path.glob("*.json"))
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_batch_option(self, mock_client_cls, create_config):
+        mock_client_cls.return_value.create_measurements.return_value = {"created": [], "failed": []}
+
+        sintra.handle_create_command(parse("create", "--config", str(create_config), "--batch"))
+
+        assert mock_client_cls.return_value.batch_create is True
+
+    @pytest.mark.parametrize("argv, skip_duplicates", [((), True), (("--force",), False)])
+    @patch("sintra.SintraMeasurementClient")
+    def test_force_creates_duplicates(self, mock_client_cls, create_config, tmp_path, argv, skip_duplicates):
+        client = mock_client_cls.return_value
+        client.create_measurements.return_value = {
+            "created": [], "failed": [],
+            "skipped": [{"index": 0, "target": "8.8.8.8", "type": "ping", "measurement_id": 111}]
+        }
+        summary_file = tmp_path / "summary.json"
+
+        sintra.handle_create_command(parse("create", "--config", str(create_config), "--summary-file",
+                                           str(summary_file), *argv))
+
+        assert client.skip_duplicates is skip_duplicates
+        assert client.batch_create is False
+        data = json.loads(summary_file.read_text())
+        assert data["skipped"][0]["measurement_id"] == 111
+        assert data["success"] is True
+
+
+# === Test: Remote dry-run validation ===
+
+class TestRemoteDryRun:
+    @patch("sintra.SintraMeasurementClient")
+    def test_rejection_fails_the_command(self, mock_client_cls, create_config):
+        mock_client_cls.return_value.validate_measurements_remote.return_value = {
+            "valid": [], "invalid": [{"index": 0, "target": "8.8.8.8", "error": "bad"}], "available": True
+        }
+        args = parse("create", "--config", str(create_config), "--dry-run", "--remote")
+
+        with pytest.raises(ValueError, match="rejected by remote validation"):
+            sintra.handle_create_command(args)
+        mock_client_cls.return_value.create_measurements.assert_not_called()
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_remote_requires_dry_run(self, mock_client_cls, create_config):
+        args = parse("create", "--config", str(create_config), "--remote")
+
+        with pytest.raises(ValueError, match="--remote requires --dry-run"):
+            sintra.handle_create_command(args)
+        mock_client_cls.return_value.create_measurements.assert_not_called()
+
+
+# === Test: One-off create ===
+
+class TestOneoffCreate:
+    @patch("sintra.SintraMeasurementClient")
+    def test_wait_prints_results_per_measurement(self, mock_client_cls, create_config, capsys):
+        client = mock_client_cls.return_value
+        client.create_measurements.return_value = {"created": [
+            {"measurement_id": 111, "target": "8.8.8.8", "type": "ping", "estimated_credits": 10},
+            {"measurement_id": 222, "target": "1.1.1.1", "type": "ping", "estimated_credits": 10},
+        ], "failed": []}
+        client.wait_for_oneoff_results.side_effect = [
+            [{"type": "ping", "prb_id": 6042, "timestamp": 1700000000, "sent": 3, "rcvd": 3,
+              "result": [{"rtt": 10.0}, {"rtt": 12.0}, {"rtt": 14.0}]}],
+            sintra.ResultsTimeoutError(222, 60),
+        ]
+        args = parse("create", "--config", str(create_config), "--oneoff", "--wait", "--wait-timeout", "60")
+
+        sintra.handle_create_command(args)
+
+        assert client.oneoff is True
+        client.wait_for_oneoff_results.assert_any_call(111, 60)
+        output = json.loads(capsys.readouterr().out)
+        assert output[0]["measurement_id"] == 111
+        assert output[0]["results"][0]["probe_id"] == 6042
+        assert output[0]["results"][0]["latency_stats"]["avg"] == 12.0
+        assert output[1]["results"] == []
+        assert "timed out waiting for results for measurement 222" in output[1]["error"]
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_failed_download_kept_as_error(self, mock_client_cls, create_config, capsys):
+        client = mock_client_cls.return_value
+        client.create_measurements.return_value = {"created": [
+            {"measurement_id": 111, "target": "8.8.8.8", "type": "ping", "estimated_credits": 10},
+        ], "failed": []}
+        client.wait_for_oneoff_results.side_effect = requests.ConnectionError("connection reset")
+
+        sintra.handle_create_command(parse("create", "--config", str(create_config), "--oneoff", "--wait"))
+
+        output = json.loads(capsys.readouterr().out)
+        assert output == [{"measurement_id": 111, "target": "8.8.8.8", "type": "ping", "results": [],
+                           "error": "connection reset"}]
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_oneoff_without_wait_prints_nothing(self, mock_client_cls, create_config, capsys):
+        mock_client_cls.return_value.create_measurements.return_value = {"created": [], "failed": []}
+        args = parse("create", "--config", str(create_config), "--oneoff")
+
+        sintra.handle_create_command(args)
+
+        assert mock_client_cls.return_value.oneoff is True
+        mock_client_cls.return_value.wait_for_oneoff_results.assert_not_called()
+        assert capsys.readouterr().out == ""
+
+    @pytest.mark.parametrize("argv", [["--wait"], ["--oneoff", "--wait", "--dry-run"]])
+    @patch("sintra.SintraMeasurementClient")
+    def test_wait_requires_oneoff(self, mock_client_cls, create_config, argv):
+        args = parse("create", "--config", str(create_config), *argv)
+
+        with pytest.raises(ValueError, match="--wait requires --oneoff"):
+            sintra.handle_create_command(args)
+        mock_client_cls.return_value.create_measurements.assert_not_called()
+
+
+# === Test: Fetch filters and table output ===
+
+class TestFetchFilters:
+    @patch("sintra.SintraMeasurementClient")
+    def test_positional_id_window_and_probes(self, mock_client_cls):
+        client = mock_client_cls.return_value
+        client.since_timestamp = None
+        client.fetch_measurements.return_value = []
+
+        sintra.handle_fetch_command(parse("fetch", "123", "--start", "2024-01-01T00:00:00Z", "--stop", "1704153600",
+                                          "--probes", "6042, 10012"))
+
+        client.fetch_measurements.assert_called_once_with(123)
+        assert client.since_timestamp == 1704067200
+        assert client.until_timestamp == 1704153600
+        assert client.probe_ids == [6042, 10012]
+
+    @pytest.mark.parametrize("argv", [
+        ["--since", "1h", "--start", "2024-01-01T00:00:00Z"],
+        ["--start", "1704153600", "--stop", "1704067200"],
+        ["--probes", "6042,abc"],
+        ["--stop", "yesterday"],
+        ["--measurement-id", "7"],
+        ["--output", "table", "--stream"],
+    ])
+    @patch("sintra.SintraMeasurementClient")
+    def test_invalid_combinations_fetch_nothing(self, mock_client_cls, argv):
+        client = mock_client_cls.return_value
+        client.since_timestamp = None
+
+        sintra.handle_fetch_command(parse("fetch", "123", *argv))
+
+        client.fetch_measurements.assert_not_called()
+        client.stream_result_rows.assert_not_called()
+
+    def test_results_params_carry_filters(self, client):
+        client.since_timestamp = 1704067200
+        client.until_timestamp = 1704153600
+        client.probe_ids = [6042]
+
+        params = client._results_params()
+
+        assert params == {"format": "json", "start": 1704067200, "stop": 1704153600, "probe_ids": [6042]}
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_table_output(self, mock_client_cls, tmp_path, capsys):
+        client = mock_client_cls.return_value
+        client.since_timestamp = None
+        client.fetched_measurements_dir = tmp_path
+        client.fetch_measurements.return_value = [123]
+        (tmp_path / "measurement_123_result.json").write_text(json.dumps({
+            "measurement_id": 123, "measurement_type": "ping", "target": "example.com",
+            "results": [{"probe_id": 6042, "probe_country_code": "NL", "latency_stats": {"avg": 12.345}}]
+        }))
+
+        sintra.handle_fetch_command(parse("fetch", "123", "--output", "table"))
+
+        header, row = capsys.readouterr().out.splitlines()
+        assert header.split()[:3] == ["measurement_id", "measurement_type", "probe_id"]
+        assert row.split()[:4] == ["123", "ping", "6042", "NL"]
+        assert "12.35" in row.split()
+
+
+# === Test: Waiting for fetch results ===
+
+class TestFetchWait:
+    @patch("measurement_client.client.requests.Session.request")
+    def test_timeout_reported_and_exits_non_zero(self, mock_request, tmp_path, monkeypatch):
+        """Results that never appear should fail the command with the timeout, not a generic failure."""
+        monkeypatch.setenv("RIPE_ATLAS_API_KEY", "test-key")
+        monkeypatch.chdir(tmp_path)
+        clock = [1000.0]
+        monkeypatch.setattr("measurement_client.client.time.monotonic", lambda: clock[0])
+        monkeypatch.setattr("measurement_client.client.Context.wait",
+                            lambda self, seconds: clock.__setitem__(0, clock[0] + seconds))
+        mock_request.side_effect = lambda method, url, **kwargs: make_response(
+            [] if url.endswith("/results/") else {"id": 123, "type": "ping"}
+        )
+
+        with patch("sintra.logger") as mock_logger, pytest.raises(SystemExit) as exit_info:
+            sintra.handle_fetch_command(parse("fetch", "--measurement-id", "123", "--wait", "--wait-timeout", "30"))
+
+        assert exit_info.value.code == 1
+        assert "timed out waiting for results for measurement 123" in mock_logger.error.call_args.args[0]
+        results_polls = [c for c in mock_request.call_args_list if c.args[1].endswith("/123/results/")]
+        assert len(results_polls) > 1
+
+
+# === Test: Per-probe split fetch ===
+
+class TestSplitFetch:
+    @patch("sintra.SintraMeasurementClient")
+    def test_rows_written_per_probe(self, mock_client_cls, tmp_path):
+        def stream_pages(measurement_id, writer, write, resume=False):
+            for probe_id in (1, 2, 1):
+                write({"measurement_id": measurement_id, "probe_id": probe_id})
+            return 3
+        mock_client_cls.return_value.stream_paged_results.side_effect = stream_pages
+        archive = tmp_path / "archive"
+        args = parse("fetch", "--measurement-id", "5", "--output", "json",
+                     "--split-by", "probe", "--output-dir", str(archive))
+        mock_client_cls.return_value.resolve_fetch_ids.return_value = [5]
+
+        sintra.handle_fetch_command(args)
+
+        assert len((archive / "1.jsonl").read_text().splitlines()) == 2
+        assert len((archive / "2.jsonl").read_text().splitlines()) == 1
+        mock_client_cls.return_value.fetch_measurements.assert_not_called()
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_split_requires_json_and_dir(self, mock_client_cls, tmp_path):
+        args = parse("fetch", "--measurement-id", "5", "--output", "csv", "--split-by", "probe",
+                     "--output-dir", str(tmp_path))
+
+        sintra.handle_fetch_command(args)
+
+        mock_client_cls.return_value.stream_result_rows.assert_not_called()
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_incremental_not_split(self, mock_client_cls, tmp_path):
+        args = parse("fetch", "--measurement-id", "5", "--output", "json", "--split-by", "probe",
+                     "--output-dir", str(tmp_path), "--incremental")
+
+        sintra.handle_fetch_command(args)
+
+        mock_client_cls.return_value.stream_result_rows.assert_not_called()
+
+
+# === Test: Incremental fetch ===
+
+class TestIncrementalFetchCommand:
+    @patch("sintra.SintraMeasurementClient")
+    def test_sets_client_mode(self, mock_client_cls):
+        client = mock_client_cls.return_value
+        client.fetch_measurements.return_value = [5]
+
+        sintra.handle_fetch_command(parse("fetch", "--measurement-id", "5", "--incremental"))
+
+        assert client.incremental is True
+        client.fetch_measurements.assert_called_once_with(5)
+
+
+# === Test: Resuming an interrupted split fetch ===
+
+class TestConcurrentFetch:
+    @staticmethod
+    def slow_first(finished):
+        """Earlier measurements take longer, so they finish in reverse order."""
+        def stream_rows(measurement_id, callback, params=None):
+            for n in range(3):
+                time.sleep(0.01 * (4 - measurement_id))
+                callback({"measurement_id": measurement_id, "probe_id": n})
+            finished.append(measurement_id)
+            return 3
+        return stream_rows
+
+    def test_rows_written_in_measurement_order(self):
+        finished, written = [], []
+        client = MagicMock()
+        client.stream_result_rows.side_effect = self.slow_first(finished)
+
+        sintra.stream_concurrently(client, [1, 2, 3], written.append, concurrency=3, reorder_buffer=10)
+
+        assert finished == [3, 2, 1]
+        assert [(row["measurement_id"], row["probe_id"]) for row in written] == [
+            (m, n) for m in (1, 2, 3) for n in range(3)
+        ]
+
+    def test_failed_measurement_does_not_block_later_ones(self):
+        written = []
+        client = MagicMock()
+
+        def stream_rows(measurement_id, callback, params=None):
+            if measurement_id == 1:
+                raise ConnectionError("reset")
+            callback({"measurement_id": measurement_id})
+            return 1
+        client.stream_result_rows.side_effect = stream_rows
+
+        sintra.stream_concurrently(client, [1, 2], written.append, concurrency=2, reorder_buffer=10)
+
+        assert written == [{"measurement_id": 2}]
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_json_export_in_order(self, mock_client_cls, tmp_path):
+        mock_client_cls.return_value.stream_result_rows.side_effect = self.slow_first([])
+        mock_client_cls.return_value.resolve_fetch_ids.return_value = [1, 2, 3]
+        out = tmp_path / "rows.json"
+        args = parse("fetch", "--measurement-id", "1", "--stream", "--output", "json", "--file", str(out),
+                     "--concurrency", "3")
+
+        sintra.handle_fetch_command(args)
+
+        assert [row["measurement_id"] for row in json.loads(out.read_text())] == [1, 1, 1, 2, 2, 2, 3, 3, 3]
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_concurrency_without_stream_uses_worker_pool(self, mock_client_cls, tmp_path, monkeypatch):
+        monkeypatch.chdir(tmp_path)
+        client = mock_client_cls.return_value
+        client._get_saved_measurement_ids.return_value = [1, 2, 3]
+        client.fetch_measurements.return_value = [1, 3]
+
+        sintra.handle_fetch_command(parse("fetch", "--all", "--concurrency", "4"))
+
+        assert client.fetch_concurrency == 4
+        client.fetch_measurements.assert_called_once_with(measurement_ids=[1, 2, 3])
+        client.stream_result_rows.assert_not_called()
+
+    @pytest.mark.parametrize("argv", [
+        ["--concurrency", "4", "--output", "json", "--split-by", "probe", "--output-dir", "out"],
+        ["--concurrency", "4", "--output", "matrix"],
+        ["--reorder-buffer", "10"],
+        ["--concurrency", "0"],
+    ])
+    @patch("sintra.SintraMeasurementClient")
+    def test_invalid_concurrency_rejected(self, mock_client_cls, argv):
+        sintra.handle_fetch_command(parse("fetch", "--measurement-id", "1", *argv))
+
+        mock_client_cls.return_value.stream_result_rows.assert_not_called()
+        mock_client_cls.return_value.fetch_measurements.assert_not_called()
+
+
+class TestResumeFetch:
+    @patch("sintra.SintraMeasurementClient")
+    def test_resume_requires_split(self, mock_client_cls):
+        sintra.handle_fetch_command(parse("fetch", "--measurement-id", "5", "--resume"))
+
+        mock_client_cls.return_value.stream_result_rows.assert_not_called()
+
+
+# === Test: Probe participation in status ===
+
+class TestStatusParticipation:
+    @patch("sintra.logger")
+    def test_low_participation_warns(self, mock_logger, tmp_path, monkeypatch):
+        monkeypatch.chdir(tmp_path)
+        fetched_dir = tmp_path / "measurement_client/results/fetched_measurements"
+        fetched_dir.mkdir(parents=True)
+        (fetched_dir / "measurement_1_result.json").write_text(json.dumps({
+            "measurement_id": 1, "probes_requested": 10,
+            "results": [{"probe_id": 101}, {"probe_id": 102}]
+        }))
+        (fetched_dir / "measurement_2_result.json").write_text(json.dumps({
+            "measurement_id": 2, "probes_requested": 2,
+            "results": [{"probe_id": 201}, {"probe_id": 202}]
+        }))
+
+        sintra.handle_status_command(parse("status"))
+
+        warnings = [c.args[0] for c in mock_logger.warning.call_args_list]
+        infos = [c.args[0] for c in mock_logger.info.call_args_list]
+        assert any("Measurement 1: 2/10 requested probes participated (20%)" in w for w in warnings)
+        assert any("Measurement 2: 2/2 requested probes participated (100%)" in i for i in infos)
+        assert any("1 measurement(s) below 80%" in w for w in warnings)
+
+
+# === Test: Status output ===
+
+class TestStatusOutput:
+    @pytest.fixture
+    def state(self, tmp_path, monkeypatch):
+        monkeypatch.chdir(tmp_path)
+        created_dir = tmp_path / "measurement_client/results/created_measurements"
+        fetched_dir = tmp_path / "measurement_client/results/fetched_measurements"
+        events_dir = tmp_path / "event_manager/results"
+        for directory in (created_dir, fetched_dir, events_dir):
+            directory.mkdir(parents=True)
+        (created_dir / "measurement_1_info.json").write_text("{}")
+        (created_dir / "measurement_2_info.json").write_text("{}")
+        result_file = fetched_dir / "measurement_1_result.json"
+        result_file.write_text(json.dumps({"measurement_id": 1, "results": []}))
+        os.utime(result_file, (1704067200, 1704067200))
+        (events_dir / "events.json").write_text(json.dumps({"events": [
+            {"severity": "critical"}, {"severity": "warning"}, {"severity": "info"}
+        ]}))
+
+    def test_yaml_matches_golden(self, state, capsys):
+        sintra.handle_status_command(parse("status", "--output", "yaml"))
+        assert capsys.readouterr().out == (GOLDEN_DIR / "status.yaml").read_text()
+
+    def test_json_has_same_fields(self, state, capsys):
+        sintra.handle_status_command(parse("status", "--output", "json"))
+        status = json.loads(capsys.readouterr().out)
+        assert status == yaml.safe_load((GOLDEN_DIR / "status.yaml").read_text())
+
+    def test_nothing_printed_without_output(self, state, capsys):
+        sintra.handle_status_command(parse("status"))
+        assert capsys.readouterr().out == ""
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_live_adds_measurement_overviews(self, mock_client_cls, state, capsys):
+        client = mock_client_cls.return_value
+        client._get_saved_measurement_ids.return_value = [2, 1]
+        overview = {"measurement_id": 1, "type": "ping", "target": "8.8.8.8", "status": "Ongoing",
+                    "probes_requested": 10, "probes_assigned": 9, "last_result": "2024-01-01T00:00:00Z",
+                    "daily_credits": 1080.0}
+        client.measurement_overview.side_effect = [overview, None]
+
+        sintra.handle_status_command(parse("status", "--live", "--output", "json"))
+
+        status = json.loads(capsys.readouterr().out)
+        assert status["measurements"] == [overview, {"measurement_id": 2, "status": "Unavailable"}]
+        assert status["daily_credits"] == 1080.0
+        assert status["created_measurements"] == 2
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_offline_by_default(self, mock_client_cls, state):
+        sintra.handle_status_command(parse("status"))
+        mock_client_cls.assert_not_called()
+
+
+# === Test: list command ===
+
+class TestListCommand:
+    MEASUREMENTS = [
+        {"id": 1001, "type": "ping", "target": "example.com", "status": {"id": 2, "name": "Ongoing"},
+         "description": "Edge latency", "tags": ["sintra"]},
+        {"id": 1002, "type": "dns", "target": None, "status": {"id": 4, "name": "Stopped"}, "description": None}
+    ]
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_filters_and_table(self, mock_client_cls, capsys):
+        client = mock_client_cls.return_value
+        client.list_measurements.return_value = self.MEASUREMENTS
+
+        sintra.handle_list_command(parse("list", "--status", "ongoing", "--type", "ping", "--target", "example",
+                                         "--tag", "sintra", "--tag", "prod"))
+
+        client.list_measurements.assert_called_once_with(
+            {"status": 2, "type": "ping", "target__contains": "example", "tags": "sintra,prod"}
+        )
+        lines = capsys.readouterr().out.splitlines()
+        assert lines[1].split() == ["1001", "ping", "Ongoing", "example.com", "Edge", "latency"]
+        assert lines[2].split() == ["1002", "dns", "Stopped", "-"]
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_retry_options_passed_to_client(self, mock_client_cls, capsys):
+        mock_client_cls.return_value.list_measurements.return_value = []
+
+        sintra.handle_list_command(parse("--max-retries", "0", "--retry-delay", "0.5", "list"))
+
+        assert mock_client_cls.call_args.kwargs["max_retries"] == 0
+        assert mock_client_cls.call_args.kwargs["retry_delay"] == 0.5
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_proxy_options_passed_to_client(self, mock_client_cls, capsys):
+        mock_client_cls.return_value.list_measurements.return_value = []
+
+        sintra.handle_list_command(parse("--proxy", "http://proxy:3128", "--ca-bundle", "root.pem",
+                                         "--connect-timeout", "3", "list"))
+
+        assert mock_client_cls.call_args.kwargs["proxy"] == "http://proxy:3128"
+        assert mock_client_cls.call_args.kwargs["ca_bundle"] == "root.pem"
+        assert mock_client_cls.call_args.kwargs["connect_timeout"] == 3.0
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_json_output(self, mock_client_cls, capsys):
+        mock_client_cls.return_value.list_measurements.return_value = self.MEASUREMENTS[:1]
+
+        sintra.handle_list_command(parse("list", "--output", "json"))
+
+        mock_client_cls.return_value.list_measurements.assert_called_once_with({})
+        assert json.loads(capsys.readouterr().out) == [
+            {"id": 1001, "type": "ping", "target": "example.com", "status": "Ongoing",
+             "description": "Edge latency", "tags": ["sintra"]}
+        ]
+
+    def test_unknown_status_rejected(self):
+        with pytest.raises(SystemExit):
+            parse("list", "--status", "running")
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_local_lists_saved_state(self, mock_client_cls, capsys):
+        client = mock_client_cls.return_value
+        client.saved_measurements.return_value = [
+            {"measurement_id": 1001, "target": "example.com", "type": "ping", "created_at": "2024-01-01T00:00:00Z",
+             "config": {"description": "Edge latency", "tags": ["sintra"]}},
+            {"measurement_id": 1002, "target": "example.org", "type": "dns", "created_at": "2024-01-02T00:00:00Z",
+             "config": {}}
+        ]
+
+        sintra.handle_list_command(parse("list", "--local", "--type", "ping", "--tag", "sintra"))
+
+        client.list_measurements.assert_not_called()
+        lines = capsys.readouterr().out.splitlines()
+        assert len(lines) == 2
+        assert lines[1].split() == ["1001", "ping", "2024-01-01T00:00:00", "example.com", "Edge", "latency"]
+
+    def test_local_rejects_status(self):
+        with pytest.raises(ValueError, match="--status"):
+            sintra.handle_list_command(parse("list", "--local", "--status", "ongoing"))
+
+
+# === Test: statuscheck command ===
+
+class TestStatusCheckCommand:
+    CHECK = {
+        "measurement_id": 123, "global_alert": False, "total_alerts": 1,
+        "counts": {"up": 1, "down": 1, "unknown": 0},
+        "probes": {
+            "1001": {"state": "up", "last_rtt": 12.5, "last_packet_loss": 0.0, "alert_reasons": []},
+            "1002": {"state": "down", "last_rtt": None, "last_packet_loss": 100.0, "alert_reasons": ["loss"]}
+        }
+    }
+
+    @patch("sintra.logger")
+    @patch("sintra.SintraMeasurementClient")
+    def test_compact_summary(self, mock_client_cls, mock_logger):
+        mock_client_cls.return_value.status_check.return_value = self.CHECK
+
+        sintra.handle_statuscheck_command(parse("statuscheck", "123", "--max-packet-loss", "50"))
+
+        mock_client_cls.return_value.status_check.assert_called_once_with(123, 50.0, None)
+        infos = [c.args[0] for c in mock_logger.info.call_args_list]
+        warnings = [c.args[0] for c in mock_logger.warning.call_args_list]
+        assert "Measurement 123: 1 up, 1 down, 0 unknown" in infos
+        assert "[WARN] Probe 1002 down (loss)" in warnings
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_yaml_output(self, mock_client_cls, capsys):
+        mock_client_cls.return_value.status_check.return_value = self.CHECK
+
+        sintra.handle_statuscheck_command(parse("statuscheck", "123", "--output", "yaml"))
+
+        assert yaml.safe_load(capsys.readouterr().out) == self.CHECK
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_no_status_check_prints_nothing(self, mock_client_cls, capsys):
+        mock_client_cls.return_value.status_check.return_value = None
+
+        sintra.handle_statuscheck_command(parse("statuscheck", "456", "--output", "json"))
+
+        assert capsys.readouterr().out == ""
+
+
+# === Test: credits command ===
+
+class TestCreditsCommand:
+    REPORT = {"balance": 100000, "daily_income": 2000, "daily_burn": 16560.0, "net_daily_burn": 14560.0,
+              "days_remaining": 6.9, "ongoing_measurements": 3}
+
+    @patch("sintra.logger")
+    @patch("sintra.SintraMeasurementClient")
+    def test_warns_below_threshold(self, mock_client_cls, mock_logger):
+        mock_client_cls.return_value.credit_report.return_value = self.REPORT
+
+        sintra.handle_credits_command(parse("credits"))
+
+        infos = [c.args[0] for c in mock_logger.info.call_args_list]
+        warnings = [c.args[0] for c in mock_logger.warning.call_args_list]
+        assert "Credit balance: 100,000" in infos
+        assert "[WARN] Days remaining: 6.9 (below 7)" in warnings
+
+    @patch("sintra.logger")
+    @patch("sintra.SintraMeasurementClient")
+    def test_threshold_configurable(self, mock_client_cls, mock_logger):
+        mock_client_cls.return_value.credit_report.return_value = self.REPORT
+
+        sintra.handle_credits_command(parse("credits", "--warn-days", "3"))
+
+        mock_logger.warning.assert_not_called()
+        assert "Days remaining: 6.9" in [c.args[0] for c in mock_logger.info.call_args_list]
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_json_output(self, mock_client_cls, capsys):
+        mock_client_cls.return_value.credit_report.return_value = dict(self.REPORT, days_remaining=None)
+
+        sintra.handle_credits_command(parse("credits", "--output", "json"))
+
+        assert json.loads(capsys.readouterr().out)["days_remaining"] is None
+
+
+# === Test: probes command ===
+
+class TestProbesCommand:
+    @patch("sintra.logger")
+    @patch("sintra.SintraMeasurementClient")
+    def test_sync_reports_count(self, mock_client_cls, mock_logger):
+        client = mock_client_cls.return_value
+        client.sync_probe_cache.return_value = 41234
+        client.probe_cache.ttl = 86400
+        client.probe_cache.path = "measurement_client/results/probe_cache.json"
+
+        sintra.handle_probes_command(parse("probes", "sync"))
+
+        client.sync_probe_cache.assert_called_once_with()
+        infos = [c.args[0] for c in mock_logger.info.call_args_list]
+        assert "Cached 41234 probes in measurement_client/results/probe_cache.json (valid for 24h)" in infos
+
+    @staticmethod
+    def _probes():
+        return [
+            {"id": 6042, "country_code": "DE", "asn_v4": 3320, "asn_v6": None, "status": {"id": 1, "name": "Connected"},
+             "tags": [{"slug": "home"}, {"slug": "system-ipv6-works"}]},
+            {"id": 10012, "country_code": "DE", "asn_v4": 3320, "asn_v6": 3320,
+             "status": {"id": 1, "name": "Connected"}, "tags": []}
+        ]
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_search_filters_and_table(self, mock_client_cls, capsys):
+        client = mock_client_cls.return_value
+        client.search_probes.return_value = self._probes()
+
+        sintra.handle_probes_command(parse("probes", "search", "--country", "de", "--asn", "AS3320",
+                                           "--tags", "Home,system-ipv6-works", "--limit", "2"))
+
+        client.search_probes.assert_called_once_with(
+            {"tags": "home,system-ipv6-works", "country_code": "DE", "asn": 3320, "status": 1}, max_results=2
+        )
+        lines = capsys.readouterr().out.splitlines()
+        assert lines[1].split() == ["6042", "DE", "3320", "-", "Connected", "home,system-ipv6-works"]
+        assert lines[2].split()[0] == "10012"
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_search_any_status_as_json(self, mock_client_cls, capsys):
+        client = mock_client_cls.return_value
+        client.search_probes.return_value = self._probes()[:1]
+
+        sintra.handle_probes_command(parse("probes", "search", "--status", "any", "--output", "json"))
+
+        client.search_probes.assert_called_once_with({}, max_results=sintra.DEFAULT_PROBE_SEARCH_LIMIT)
+        probes = json.loads(capsys.readouterr().out)
+        assert probes[0]["id"] == 6042
+        assert probes[0]["status"] == "Connected"
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_search_writes_ids_into_config(self, mock_client_cls, tmp_path):
+        mock_client_cls.return_value.search_probes.return_value = self._probes()
+        config_file = tmp_path / "create_config.yaml"
+        config_file.write_text(
+            "measurements:\n"
+            "  - type: ping\n    target: 8.8.8.8\n"
+            "  - type: ping\n    target: 1.1.1.1\n    probe_query: {strategy: best, count: 5}\n"
+        )
+
+        sintra.handle_probes_command(parse("probes", "search", "--country", "DE", "--write-config", str(config_file),
+                                           "--definition", "1"))
+
+        measurements = yaml.safe_load(config_file.read_text())["measurements"]
+        assert "probes" not in measurements[0]
+        assert measurements[1]["probes"] == {"ids": [6042, 10012]}
+        assert "probe_query" not in measurements[1]
+
+    @pytest.mark.parametrize("argv, message", [
+        (["--country", "XX"], "not an ISO 3166-1 alpha-2 country code"),
+        (["--limit", "0"], "--limit must be greater than zero"),
+        (["--write-config", "{config}", "--definition", "3"], "no measurement definition 3"),
+    ])
+    @patch("sintra.SintraMeasurementClient")
+    def test_search_invalid_arguments(self, mock_client_cls, create_config, argv, message):
+        mock_client_cls.return_value.search_probes.return_value = self._probes()
+        argv = [arg.format(config=create_config) for arg in argv]
+        with pytest.raises(ValueError, match=message):
+            sintra.handle_probes_command(parse("probes", "search", *argv))
+
+
+# === Test: results command ===
+
+class TestResultsCommand:
+    @patch("sintra.logger")
+    @patch("sintra.SintraMeasurementClient")
+    def test_drift_reported_and_fails(self, mock_client_cls, mock_logger):
+        client = mock_client_cls.return_value
+        client._get_measurement_info.return_value = {"id": 111, "type": "ping"}
+        client.fetch_latest_results.return_value = [
+            {"type": "ping", "prb_id": 1, "timestamp": 1700000000, "from": "192.0.2.1", "dst_addr": "198.51.100.1",
+             "result": [{"round_trip": 10.0}]}
+        ]
+
+        with pytest.raises(RuntimeError, match="drifted from the expected schema in 1 place"):
+            sintra.handle_results_command(parse("results", "validate-schema", "111"))
+
+        errors = [c.args[0] for c in mock_logger.error.call_args_list]
+        assert errors == ["result[].rtt: missing from 1/1 result(s) (expected int or float)"]
+
+    @patch("sintra.logger")
+    @patch("sintra.SintraMeasurementClient")
+    def test_sample_limits_checked_results(self, mock_client_cls, mock_logger):
+        client = mock_client_cls.return_value
+        client._get_measurement_info.return_value = {"id": 111, "type": "traceroute"}
+        client.fetch_latest_results.return_value = [
+            {"type": "traceroute", "prb_id": probe_id, "timestamp": 1, "from": "192.0.2.1", "dst_addr": "198.51.100.1",
+             "result": []}
+            for probe_id in range(5)
+        ]
+
+        sintra.handle_results_command(parse("results", "validate-schema", "111", "--sample", "3"))
+
+        mock_logger.info.assert_called_with("3 traceroute result(s) of measurement 111 match the expected schema")
+        mock_logger.error.assert_not_called()
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_latest_table_per_probe(self, mock_client_cls, capsys):
+        client = mock_client_cls.return_value
+        client._get_measurement_info.return_value = {"id": 111, "type": "ping"}
+        client.fetch_latest_results.return_value = [
+            {"type": "ping", "prb_id": 7, "timestamp": 1704067200, "sent": 3, "rcvd": 0, "result": [{"x": "*"}] * 3},
+            {"type": "ping", "prb_id": 2, "timestamp": 1704067260, "sent": 2, "rcvd": 2,
+             "result": [{"rtt": 10.0}, {"rtt": 20.0}]}
+        ]
+
+        sintra.handle_results_command(parse("results", "latest", "111", "--probes", "7,2"))
+
+        client.fetch_latest_results.assert_called_once_with(111, [7, 2])
+        lines = capsys.readouterr().out.splitlines()
+        assert lines[0].split() == ["Probe", "Last", "result", "RTT", "avg", "Loss"]
+        assert lines[1].split() == ["2", "2024-01-01T00:01:00Z", "15.0", "ms", "0.0%"]
+        assert lines[2].split() == ["7", "2024-01-01T00:00:00Z", "-", "100.0%"]
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_latest_json_output(self, mock_client_cls, capsys):
+        client = mock_client_cls.return_value
+        client._get_measurement_info.return_value = {"id": 111, "type": "ping"}
+        client.fetch_latest_results.return_value = [
+            {"type": "ping", "prb_id": 2, "timestamp": 1704067260, "sent": 1, "rcvd": 1, "result": [{"rtt": 10.0}]}
+        ]
+
+        sintra.handle_results_command(parse("results", "latest", "111", "--output", "json"))
+
+        client.fetch_latest_results.assert_called_once_with(111, None)
+        [summary] = json.loads(capsys.readouterr().out)
+        assert (summary["probe_id"], summary["timestamp"], summary["packet_loss_percentage"]) == (2, 1704067260, 0.0)
+
+
+# === Test: export-config command ===
+
+class TestExportConfigCommand:
+    @pytest.fixture(autouse=True)
+    def api_key(self, monkeypatch, tmp_path):
+        monkeypatch.setenv("RIPE_ATLAS_API_KEY", "test-key")
+        monkeypatch.chdir(tmp_path)
+
+    def test_prints_normalized_yaml(self, tmp_path, capsys):
+        config_file = tmp_path / "prod-eu.yaml"
+        config_file.write_text("api_key: abc123\nmeasurements:\n  - target: 8.8.8.8\n")
+
+        sintra.handle_export_config_command(parse("export-config", "--config", str(config_file)))
+
+        exported = yaml.safe_load(capsys.readouterr().out)
+        assert exported["api_key"] == "[REDACTED]"
+        assert exported["measurements"] == [{
+            "index": 0, "type": "ping", "af": 4, "target": "8.8.8.8", "description": "Sintra ping to 8.8.8.8",
+            "tags": ["sintra", "type-ping", target_tag("8.8.8.8"), "af-4", "cfg-prod-eu"],
+            "interval": 240, "probes": {"type": "area", "value": "WW", "requested": 5}, "duration_hours": 1
+        }]
+
+    def test_json_from_stdin(self, monkeypatch, capsys):
+        monkeypatch.setattr("sys.stdin", io.StringIO("measurements:\n  - target: 1.1.1.1\n"))
+
+        sintra.handle_export_config_command(parse("export-config", "--config", "-", "--format", "json"))
+
+        exported = json.loads(capsys.readouterr().out)
+        assert exported["measurements"][0]["target"] == "1.1.1.1"
+        assert not any(tag.startswith("cfg-") for tag in exported["measurements"][0]["tags"])
+
+
+# === Test: stop command ===
+
+class TestStopCommand:
+    STOPPED = {"id": 4, "name": "Stopped", "stopped": True}
+
+    @patch("sintra.logger")
+    @patch("sintra.SintraMeasurementClient")
+    def test_wait_reports_final_status_per_id(self, mock_client_cls, mock_logger):
+        client = mock_client_cls.return_value
+        client.stop_measurement.side_effect = [True, False]
+        client.wait_until_stopped.return_value = self.STOPPED
+
+        sintra.handle_stop_command(parse("stop", "111", "222", "--wait", "--wait-timeout", "60"))
+
+        client.wait_until_stopped.assert_called_once_with(111, 60)
+        assert "Measurement 111: Stopped" in [c.args[0] for c in mock_logger.info.call_args_list]
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_without_wait_does_not_poll(self, mock_client_cls):
+        client = mock_client_cls.return_value
+        client.stop_measurement.return_value = True
+
+        sintra.handle_stop_command(parse("stop", "111"))
+
+        client.wait_until_stopped.assert_not_called()
+
+    @patch("sintra.logger")
+    @patch("sintra.SintraMeasurementClient")
+    def test_still_running_after_timeout_fails(self, mock_client_cls, mock_logger):
+        client = mock_client_cls.return_value
+        client.stop_measurement.return_value = True
+        client.wait_until_stopped.return_value = {"id": 2, "name": "Ongoing", "stopped": False}
+
+        with pytest.raises(RuntimeError, match="1 of 1"):
+            sintra.handle_stop_command(parse("stop", "111", "--wait", "--wait-timeout", "30"))
+
+        warnings = [c.args[0] for c in mock_logger.warning.call_args_list]
+        assert "[WARN] Measurement 111 not stopped after 30s (Ongoing)" in warnings
+
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_all_stops_saved_measurements(self, mock_client_cls):
+        client = mock_client_cls.return_value
+        client._get_saved_measurement_ids.return_value = [333, 111]
+        client.stop_measurement.return_value = True
+
+        sintra.handle_stop_command(parse("stop", "--all"))
+
+        assert [c.args[0] for c in client.stop_measurement.call_args_list] == [111, 333]
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_expired_stops_measurements_past_their_stop_time(self, mock_client_cls):
+        client = mock_client_cls.return_value
+        client.expired_measurements.return_value = [222]
+        client.stop_measurement.return_value = True
+
+        sintra.handle_stop_command(parse("stop", "--expired"))
+
+        client._get_saved_measurement_ids.assert_not_called()
+        assert [c.args[0] for c in client.stop_measurement.call_args_list] == [222]
+
+    @pytest.mark.parametrize("argv", [["stop"], ["stop", "111", "--all"], ["stop", "--all", "--expired"]])
+    def test_ids_or_all_required(self, argv):
+        with pytest.raises(ValueError, match="either measurement IDs, --all or --expired"):
+            sintra.handle_stop_command(parse(*argv))
+
+
+# === Test: reconcile command ===
+
+class TestReconcileCommand:
+    @patch("sintra.SintraMeasurementClient")
+    def test_defaults_to_saved_measurements(self, mock_client_cls):
+        client = mock_client_cls.return_value
+        client._get_saved_measurement_ids.return_value = [222, 111]
+        client.replace_disconnected_probes.return_value = None
+
+        sintra.handle_reconcile_command(parse("reconcile", "--dry-run"))
+
+        assert [c.args for c in client.replace_disconnected_probes.call_args_list] == [(111,), (222,)]
+        assert client.replace_disconnected_probes.call_args.kwargs == {"dry_run": True}
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_api_failure_fails_command(self, mock_client_cls):
+        client = mock_client_cls.return_value
+        client.replace_disconnected_probes.side_effect = [
+            {"measurement_id": 111, "disconnected": [5], "added": [6], "replaced": True},
+            requests.ConnectionError("down")
+        ]
+
+        with pytest.raises(RuntimeError, match="1 of 2 measurement"):
+            sintra.handle_reconcile_command(parse("reconcile", "111", "222"))
+
+
+# === Test: modify command ===
+
+class TestModifyCommand:
+    @patch("sintra.SintraMeasurementClient")
+    def test_add_and_remove_probe_ids(self, mock_client_cls):
+        client = mock_client_cls.return_value
+        client.add_probes.return_value = {"type": "probes", "value": "13,14", "requested": 2}
+
+        sintra.handle_modify_command(parse("modify", "555", "--add-probes", "13,14", "--remove-probes", "12"))
+
+        client.add_probes.assert_called_once_with(555, {"ids": [13, 14]})
+        client.remove_probes.assert_called_once_with(555, [12])
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_add_from_selector_with_count(self, mock_client_cls):
+        client = mock_client_cls.return_value
+        client.add_probes.return_value = {"type": "country", "value": "NL", "requested": 3}
+
+        sintra.handle_modify_command(parse("modify", "555", "--add-country", "NL", "--count", "3"))
+
+        client.add_probes.assert_called_once_with(555, {"country": "NL", "count": 3})
+        client.remove_probes.assert_not_called()
+
+    def test_selectors_are_exclusive(self):
+        with pytest.raises(SystemExit):
+            parse("modify", "555", "--add-country", "NL", "--add-asn", "3320")
+
+    @pytest.mark.parametrize("argv, message", [
+        ((), "Give probes to add"),
+        (("--remove-probes", "12,x"), "--remove-probes must be comma-separated"),
+        (("--add-area", "West", "--count", "0"), "--count must be a positive integer"),
+    ])
+    @patch("sintra.SintraMeasurementClient")
+    def test_invalid_requests_rejected(self, mock_client_cls, argv, message):
+        with pytest.raises(ValueError, match=message):
+            sintra.handle_modify_command(parse("modify", "555", *argv))
+        mock_client_cls.assert_not_called()
+
+
+# === Test: plan and apply commands ===
+
+class TestPlanApplyCommands:
+    PLAN = [
+        {"action": "create", "index": 0, "type": "ping", "target": "example.com"},
+        {"action": "modify", "index": 1, "type": "ping", "target": "8.8.8.8", "measurement_id": 101,
+         "add": [2], "remove": [3]},
+        {"action": "unchanged", "index": 2, "type": "dns", "target": None, "measurement_id": 102},
+        {"action": "stop", "type": "ping", "target": "1.1.1.1", "measurement_id": 103}
+    ]
+
+    @pytest.fixture
+    def config_file(self, tmp_path):
+        path = tmp_path / "create.yaml"
+        path.write_text("measurements: []\n")
+        return str(path)
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_plan_summary(self, mock_client_cls, config_file, capsys):
+        mock_client_cls.return_value.plan_measurements.return_value = self.PLAN
+
+        sintra.handle_plan_command(parse("plan", "--config", config_file))
+
+        mock_client_cls.return_value.load_config.assert_called_once_with("create")
+        mock_client_cls.return_value.apply_plan.assert_not_called()
+        lines = capsys.readouterr().out.splitlines()
+        assert lines[0].split() == ["+", "create", "ping", "example.com"]
+        assert lines[1].startswith("  ~ modify")
+        assert lines[1].endswith("(add probes [2], remove probes [3])")
+        assert lines[2].split() == ["-", "stop", "103", "ping", "1.1.1.1"]
+        assert lines[3] == "Plan: 1 to create, 1 to modify, 0 to replace, 1 to stop, 1 unchanged"
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_plan_json_output(self, mock_client_cls, config_file, capsys):
+        mock_client_cls.return_value.plan_measurements.return_value = self.PLAN
+
+        sintra.handle_plan_command(parse("plan", "--config", config_file, "--output", "json"))
+
+        assert json.loads(capsys.readouterr().out) == self.PLAN
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_apply_fails_on_failed_changes(self, mock_client_cls, config_file):
+        client = mock_client_cls.return_value
+        client.plan_measurements.return_value = self.PLAN
+        client.apply_plan.return_value = {"created": [], "stopped": [103], "modified": [101],
+                                          "failed": [dict(self.PLAN[0], error="rejected")]}
+
+        with pytest.raises(RuntimeError, match="1 of 4 planned change"):
+            sintra.handle_apply_command(parse("apply", "--config", config_file, "--yes"))
+        client.apply_plan.assert_called_once_with(self.PLAN)
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_apply_skips_unchanged_plan(self, mock_client_cls, config_file):
+        mock_client_cls.return_value.plan_measurements.return_value = [self.PLAN[2]]
+
+        sintra.handle_apply_command(parse("apply", "--config", config_file))
+
+        mock_client_cls.return_value.apply_plan.assert_not_called()
+
+    @pytest.mark.parametrize("answer, applied", [("yes", True), ("YES ", True), ("y", False), ("", False)])
+    @patch("builtins.input")
+    @patch("sintra.sys.stdin")
+    @patch("sintra.SintraMeasurementClient")
+    def test_apply_asks_for_confirmation(self, mock_client_cls, mock_stdin, mock_input, config_file, answer, applied):
+        client = mock_client_cls.return_value
+        client.plan_measurements.return_value = self.PLAN
+        client.apply_plan.return_value = {"created": [], "stopped": [], "modified": [], "failed": []}
+        mock_stdin.isatty.return_value = True
+        mock_input.return_value = answer
+
+        sintra.handle_apply_command(parse("apply", "--config", config_file))
+
+        mock_input.assert_called_once()
+        assert client.apply_plan.called is applied
+
+    @patch("builtins.input")
+    @patch("sintra.sys.stdin")
+    @patch("sintra.SintraMeasurementClient")
+    def test_apply_without_terminal_needs_yes(self, mock_client_cls, mock_stdin, mock_input, config_file):
+        client = mock_client_cls.return_value
+        client.plan_measurements.return_value = self.PLAN
+        client.apply_plan.return_value = {"created": [], "stopped": [], "modified": [], "failed": []}
+        mock_stdin.isatty.return_value = False
+
+        with pytest.raises(ValueError, match="pass --yes"):
+            sintra.handle_apply_command(parse("apply", "--config", config_file))
+        client.apply_plan.assert_not_called()
+
+        sintra.handle_apply_command(parse("apply", "--config", config_file, "--auto-approve"))
+        mock_input.assert_not_called()
+        client.apply_plan.assert_called_once_with(self.PLAN)
+
+    def test_missing_config_rejected(self, tmp_path):
+        with pytest.raises(ValueError, match="not found"):
+            sintra.handle_plan_command(parse("plan", "--config", str(tmp_path / "missing.yaml")))
+
+
+# === Test: gc command ===
+
+class TestGcCommand:
+    ORPHANS = [
+        {"id": 102, "type": "ping", "target": "1.1.1.1", "status": "Ongoing", "description": None, "tags": ["sintra"],
+         "reason": "unconfigured", "daily_credits": 10800.0},
+        {"id": 103, "type": "traceroute", "target": "example.com", "status": "Scheduled", "description": None,
+         "tags": ["sintra"], "reason": "untracked", "daily_credits": 14400.0}
+    ]
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_report_only_by_default(self, mock_client_cls, create_config, capsys):
+        client = mock_client_cls.return_value
+        client.find_orphaned_measurements.return_value = self.ORPHANS
+
+        sintra.handle_gc_command(parse("gc", "--config", str(create_config)))
+
+        client.load_config.assert_called_once_with("create")
+        client.find_orphaned_measurements.assert_called_once_with(check_config=True)
+        client.stop_measurement.assert_not_called()
+        lines = capsys.readouterr().out.splitlines()
+        assert lines[1].split() == ["102", "ping", "unconfigured", "10800", "1.1.1.1"]
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_stop_orphans(self, mock_client_cls):
+        client = mock_client_cls.return_value
+        client.find_orphaned_measurements.return_value = self.ORPHANS
+        client.stop_measurement.side_effect = [True, requests.ConnectionError("down")]
+
+        with pytest.raises(RuntimeError, match="1 of 2 orphaned"):
+            sintra.handle_gc_command(parse("gc", "--stop"))
+
+        client.load_config.assert_not_called()
+        client.find_orphaned_measurements.assert_called_once_with(check_config=False)
+        assert [c.args for c in client.stop_measurement.call_args_list] == [(102,), (103,)]
+
+
+# === Test: compare command ===
+
+class TestCompareCommand:
+    BASE = 1704067200  # 2024-01-01T00:00:00Z
+
+    def mock_results(self, mock_client_cls, rows_by_measurement):
+        client = mock_client_cls.return_value
+        client._results_params.return_value = {"format": "json"}
+
+        def stream(measurement_id, callback, params=None):
+            for row in rows_by_measurement.get(measurement_id, []):
+                callback(row)
+        client.stream_result_rows.side_effect = stream
+        return client
+
+    @patch("sintra.SintraMeasurementClient")
+    def test_shared_probe_table_with_deltas(self, mock_client_cls, capsys):
+        client = self.mock_results(mock_client_cls, {
+            111: [{"probe_id": 6042, "timestamp": self.BASE + 10, "rtt_avg": 20.0, "packet_loss_percentage": 0.0},
+                  {"probe_id": 6042, "timestamp": self.BASE + 3600, "rtt_avg": 22.0, "packet_loss_percentage": 0.0}],
+            222: [{"probe_id": 6042, "timestamp": self.BASE - 30, "rtt_avg": 25.5, "packet_loss_percentage": 33.3},
+                  {"probe_id": 7, "timestamp": self.BASE, "rtt_avg": 99.0, "packet_loss_percentage": 0.0}],
+        })
+
+        sintra.handle_compare_command(parse("compare", "111", "222", "--probe", "6042"))
+
+        assert client.stream_result_rows.call_args.kwargs["params"]["probe_ids"] == [6042]
+        lines = capsys.readouterr().out.splitlines()
+        assert lines[0].split() == ["timestamp", "rtt_a", "rtt_b", "rtt_delta", "loss_a", "loss_b", "loss_delta"]
+        assert lines[1].split() == ["2024-01-01T00:00:00Z", "20.00", "25.50", "+5.50", "0.00", "33.30", "+33.30"]
+        assert lines[2].split() == ["2024-01-01T01:00:00Z", "22.00", "-", "-", "0.00", "-", "-"]
+
+    @patch("sintra.logger")
+    @patch("sintra.SintraMeasurementClient")
+    def test_missing_probe_reported(self, mock_client_cls, mock_logger, capsys):
+        self.mock_results(mock_client_cls, {
+            111: [{"probe_id": 6042, "timestamp": self.BASE, "rtt_avg": 20.0, "packet_loss_percentage": 0.0}],
+            222: [{"probe_id": 7, "timestamp": self.BASE, "rtt_avg": 99.0, "packet_loss_percentage": 0.0}],
+        })
+
+        sintra.handle_compare_command(parse("compare", "111", "222", "--probe", "6042"))
+
+        errors = [c.args[0] for c in mock_logger.error.call_args_list]
+        assert "Probe 6042 has no results in measurement 222" in errors
+        assert capsys.readouterr().out == ""
+
+
+# === Test: stream command ===
+
+class TestStreamCommand:
+    @patch("sintra.AtlasResultStream")
+    @patch("sintra.SintraMeasurementClient")
+    def test_prints_results_as_json_lines(self, mock_client_cls, mock_stream_cls, capsys):
+        def run(callback):
+            callback({"msm_id": 1001, "prb_id": 1})
+            callback({"msm_id": 2002, "prb_id": 2})
+            raise KeyboardInterrupt
+        mock_stream_cls.return_value.run.side_effect = run
+
+        sintra.handle_stream_command(parse("stream", "--measurement-id", "1001", "--measurement-id", "2002",
+                                           "--url", "ws://localhost:8080/stream/"))
+
+        mock_stream_cls.assert_called_once_with([1001, 2002], url="ws://localhost:8080/stream/", proxy=None,
+                                                ca_bundle=None, connect_timeout=STREAM_CONNECT_TIMEOUT)
+        mock_stream_cls.return_value.stop.assert_called_once()
+        assert [json.loads(line) for line in capsys.readouterr().out.splitlines()] == [
+            {"msm_id": 1001, "prb_id": 1}, {"msm_id": 2002, "prb_id": 2}
+        ]
+
+    @patch("sintra.AtlasResultStream")
+    @patch("sintra.SintraMeasurementClient")
+    def test_transport_options_passed_to_stream(self, mock_client_cls, mock_stream_cls):
+        sintra.handle_stream_command(parse("--proxy", "http://proxy:3128", "--ca-bundle", "root.pem",
+                                           "--connect-timeout", "5", "stream", "--measurement-id", "1001"))
+
+        assert mock_stream_cls.call_args.kwargs == {"url": DEFAULT_STREAM_URL, "proxy": "http://proxy:3128",
+                                                    "ca_bundle": "root.pem", "connect_timeout": 5.0}
+
+    @patch("sintra.AtlasResultStream")
+    @patch("sintra.SintraMeasurementClient")
+    def test_nothing_to_stream(self, mock_client_cls, mock_stream_cls, tmp_path):
+        mock_client_cls.return_value._get_saved_measurement_ids.return_value = []
+
+        sintra.handle_stream_command(parse("stream", "--config", str(tmp_path / "missing.yaml")))
+
+        mock_stream_cls.assert_not_called()
+
+
+# === Test: Config migration ===
+
+class TestMigrateCommand:
+    def test_writes_migrated_copy(self, tmp_path):
+        old_config = tmp_path / "old.yaml"
+        old_config.write_text(
+            "measurements:\n"
+            "  - type: ping\n"
+            "    target: 8.8.8.8\n"
+            "    probes: {type: country, value: JP, requested: 3}\n"
+        )
+        migrated_file = tmp_path / "new.yaml"
+
+        sintra.handle_migrate_command(parse("migrate", "--config", str(old_config), "--output", str(migrated_file)))
+
+        migrated = yaml.safe_load(migrated_file.read_text())
+        assert migrated["version"] == 2
+        assert migrated["measurements"][0]["probes"] == {"country": "JP", "count": 3}
+        assert "type: country" in old_config.read_text()
+
+    def test_in_place_and_output_are_exclusive(self):
+        with pytest.raises(SystemExit):
+            parse("migrate", "--in-place", "--output", "x.yaml")
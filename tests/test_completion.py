@@ -0,0 +1,72 @@
+"""
+Unit tests for the shell completion generator.
+
+Scripts are generated from the real CLI parser so new commands and option
+values are picked up without touching the generator.
+"""
+import shutil
+import subprocess
+import pytest
+import sintra
+from completion import COMPLETION_SHELLS, completion_spec, completion_script
+from measurement_client.exporters import EXPORT_FORMATS
+
+
+@pytest.fixture
+def parser():
+    return sintra.create_parser()
+
+
+def option(options, name):
+    return next(o for o in options if o["option"] == name)
+
+
+# === Test: Completion spec ===
+
+class TestCompletionSpec:
+    def test_values_shared_with_validation(self, parser):
+        """Completion values should be the same lists the parser validates against."""
+        spec = completion_spec(parser)
+        assert option(spec["commands"]["fetch"]["options"], "--output")["choices"] == EXPORT_FORMATS
+        assert option(spec["options"], "--log-level")["choices"] == sintra.LOG_LEVELS
+
+    def test_every_command_listed(self, parser):
+        spec = completion_spec(parser)
+        assert {"create", "fetch", "tui", "completion"} <= set(spec["commands"])
+        assert spec["commands"]["completion"]["arguments"] == COMPLETION_SHELLS
+
+    def test_path_options_marked(self, parser):
+        spec = completion_spec(parser)
+        assert option(spec["commands"]["create"]["options"], "--summary-file")["path"]
+        assert not option(spec["commands"]["fetch"]["options"], "--since")["path"]
+
+
+# === Test: Generated scripts ===
+
+class TestCompletionScripts:
+    @pytest.mark.parametrize("shell", COMPLETION_SHELLS)
+    def test_script_mentions_commands_and_values(self, parser, shell):
+        script = completion_script(parser, shell)
+        for word in ("create", "fetch", "summary-file", "json", "csv", "WARNING"):
+            assert word in script
+
+    def test_unknown_shell_rejected(self, parser):
+        with pytest.raises(ValueError, match="Unsupported shell"):
+            completion_script(parser, "tcsh")
+
+    @pytest.mark.skipif(shutil.which("bash") is None, reason="bash not installed")
+    def test_bash_completes_output_values(self, parser, tmp_path):
+        script_path = tmp_path / "sintra.bash"
+        script_path.write_text(completion_script(parser, "bash"))
+        probe = (
+            f'source "{script_path}"; COMP_WORDS=(sintra fetch --output ""); COMP_CWORD=3; '
+            '_sintra_completion; echo "${COMPREPLY[*]}"'
+        )
+        result = subprocess.run(["bash", "-c", probe], capture_output=True, text=True, check=True)
+        assert result.stdout.split() == EXPORT_FORMATS
+
+    def test_completion_command_prints_only_script(self, capsys, monkeypatch):
+        monkeypatch.setattr("sys.argv", ["sintra", "completion", "bash"])
+        sintra.main()
+        out = capsys.readouterr().out
+        assert out.startswith("# bash completion for sintra")
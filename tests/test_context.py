@@ -0,0 +1,102 @@
+"""
+Unit tests for Context cancellation and deadlines, and how the client honours them.
+"""
+import threading
+import time
+import pytest
+from unittest.mock import patch
+from measurement_client.context import Cancelled, Context, DeadlineExceeded
+from tests.conftest import make_response
+
+
+# === Test: Context ===
+
+class TestContext:
+    def test_cancel_reaches_derived_contexts(self):
+        root = Context()
+        child = root.with_cancel()
+        grandchild = child.with_timeout(60)
+
+        child.cancel()
+        assert child.cancelled and grandchild.cancelled
+        assert not root.cancelled
+
+        root.cancel()
+        assert root.with_cancel().cancelled  # derived after cancelling starts out cancelled
+
+    def test_deadline_never_later_than_parent(self):
+        parent = Context().with_timeout(10)
+        assert parent.with_timeout(60).deadline == parent.deadline
+        assert parent.with_timeout(1).deadline < parent.deadline
+        assert Context().remaining() is None
+
+    def test_check_raises_when_done(self):
+        Context().check()
+
+        cancelled = Context()
+        cancelled.cancel()
+        with pytest.raises(Cancelled, match="cancelled"):
+            cancelled.check()
+
+        with pytest.raises(DeadlineExceeded):
+            Context(deadline=time.monotonic() - 1).check()
+
+    def test_wait_wakes_on_cancel(self):
+        context = Context()
+        threading.Timer(0.05, context.cancel).start()
+        started = time.monotonic()
+
+        with pytest.raises(Cancelled):
+            context.wait(30)
+        assert time.monotonic() - started < 5
+
+    def test_wait_stops_at_deadline(self):
+        with pytest.raises(DeadlineExceeded):
+            Context().with_timeout(0.05).wait(30)
+
+
+# === Test: Client wiring ===
+
+class TestClientContext:
+    @patch("measurement_client.client.requests.Session.request")
+    def test_cancelled_client_makes_no_requests(self, mock_request, client):
+        client.context.cancel()
+
+        with pytest.raises(Cancelled):
+            client._request_with_backoff(f"{client.base_url}/measurements/1/")
+        mock_request.assert_not_called()
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_request_timeout_capped_at_deadline(self, mock_request, client):
+        mock_request.return_value = make_response({"id": 1})
+
+        client.with_context(Context().with_timeout(2))._request_with_backoff(f"{client.base_url}/measurements/1/")
+        assert mock_request.call_args.kwargs["timeout"] <= 2
+
+        client._request_with_backoff(f"{client.base_url}/measurements/1/")
+        assert mock_request.call_args.kwargs["timeout"] == client.request_timeout
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_cancel_interrupts_retry_backoff(self, mock_request, client):
+        mock_request.return_value = make_response(status_code=503)
+        threading.Timer(0.05, client.context.cancel).start()
+
+        with pytest.raises(Cancelled):
+            client._request_with_backoff(f"{client.base_url}/measurements/1/", base_delay=30)
+        assert mock_request.call_count == 1
+
+    def test_with_context_shares_the_pool(self, client):
+        context = Context()
+        bound = client.with_context(context)
+
+        assert bound.context is context and client.context is not context
+        assert bound.session is client.session and bound.api_metrics is client.api_metrics
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_cancelled_fetch_is_not_counted_as_a_failure(self, mock_request, client):
+        """Cancellation ends fetch_measurements instead of being logged per measurement and skipped."""
+        client.context.cancel()
+
+        with pytest.raises(Cancelled):
+            client.fetch_measurements(measurement_ids=[1, 2, 3])
+        mock_request.assert_not_called()
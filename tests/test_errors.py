@@ -0,0 +1,132 @@
+"""
+Unit tests for the typed RIPE Atlas API errors and how the client and CLI react to them.
+"""
+from datetime import datetime, timezone
+import pytest
+import requests
+from unittest.mock import patch, MagicMock
+import sintra
+from measurement_client.errors import (
+    ApiError, NotFoundError, QuotaExceededError, RateLimitedError, ServerError, UnauthorizedError,
+    ValidationError, api_error, error_text, parse_retry_after
+)
+from tests.conftest import make_response
+
+# An Atlas error body for a rejected measurement definition
+INVALID_DEFINITION = {"error": {
+    "status": 400, "code": 104, "title": "Bad Request", "detail": "There was a problem with your request",
+    "errors": [{"source": {"pointer": "/definitions/0/target"}, "detail": "This field is required."},
+               {"source": {"pointer": "/probes/0/requested"}, "detail": "Ensure this value is at most 1000."}]
+}}
+
+
+# === Test: Error types ===
+
+class TestApiError:
+    @pytest.mark.parametrize("status_code, body, error_type", [
+        (401, {"error": {"detail": "Authentication credentials were not provided."}}, UnauthorizedError),
+        (403, {"error": {"detail": "You do not have permission to perform this action."}}, UnauthorizedError),
+        (403, {"error": {"detail": "Not enough credits to schedule this measurement"}}, QuotaExceededError),
+        (400, {"error": {"detail": "You have too many concurrent measurements"}}, QuotaExceededError),
+        (402, None, QuotaExceededError),
+        (404, {"detail": "Not found."}, NotFoundError),
+        (400, INVALID_DEFINITION, ValidationError),
+        (429, None, RateLimitedError),
+        (503, None, ServerError),
+        (409, None, ApiError)
+    ])
+    def test_type_by_status_and_detail(self, status_code, body, error_type):
+        error = api_error(make_response(body, status_code=status_code))
+
+        assert type(error) is error_type
+        assert isinstance(error, requests.HTTPError) and error.response.status_code == status_code
+
+    def test_atlas_field_errors(self):
+        error = api_error(make_response(INVALID_DEFINITION, status_code=400))
+
+        assert error.field_errors == [("definitions.0.target", "This field is required."),
+                                      ("probes.0.requested", "Ensure this value is at most 1000.")]
+        assert str(error) == ("HTTP 400 Bad Request: There was a problem with your request "
+                              "(definitions.0.target: This field is required.; "
+                              "probes.0.requested: Ensure this value is at most 1000.)")
+
+    def test_per_field_error_mapping(self):
+        body = {"definitions": [{"interval": ["Ensure this value is greater than or equal to 60."]}]}
+        error = api_error(make_response(body, status_code=400))
+
+        assert error.field_errors == [("definitions.0.interval", "Ensure this value is greater than or equal to 60.")]
+
+    def test_unparsed_body_kept_as_text(self):
+        response = make_response(status_code=400)
+        response.json.side_effect = ValueError("not JSON")
+        response.text = "<html>Bad Request</html>"
+
+        error = api_error(response)
+        assert type(error) is ValidationError and error.field_errors == []
+        assert error_text(error) == "<html>Bad Request</html>"
+
+    @pytest.mark.parametrize("value, seconds", [
+        ("30", 30.0), ("Wed, 01 Jan 2025 00:01:00 GMT", 60.0), ("Tue, 31 Dec 2024 23:00:00 GMT", 0.0),
+        ("soon", None), (None, None)
+    ])
+    def test_retry_after(self, value, seconds):
+        assert parse_retry_after(value, datetime(2025, 1, 1, tzinfo=timezone.utc)) == seconds
+
+    def test_rate_limited_carries_retry_after(self):
+        assert api_error(make_response(status_code=429, headers={"Retry-After": "30"})).retry_after == 30.0
+        assert api_error(make_response(status_code=429)).retry_after is None
+
+
+# === Test: Client and CLI reactions ===
+
+class TestApiErrorReactions:
+    @patch("measurement_client.client.requests.Session.request")
+    def test_request_raises_typed_error(self, mock_request, client):
+        mock_request.return_value = make_response(INVALID_DEFINITION, status_code=400)
+
+        with pytest.raises(ValidationError) as raised:
+            client._request_with_backoff(f"{client.base_url}/measurements/", method="POST", json={})
+        assert raised.value.field_errors[0][0] == "definitions.0.target"
+        assert mock_request.call_count == 1
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_create_stops_when_key_refused(self, mock_request, client):
+        mock_request.return_value = make_response({"error": {"detail": "Invalid API key"}}, status_code=401)
+        client.create_config = {"measurements": [{"type": "ping", "target": f"{name}.example.com"}
+                                                 for name in ("a", "b", "c")]}
+        client.load_config = MagicMock()
+
+        summary = client.create_measurements()
+
+        assert mock_request.call_count == 1
+        assert [(failure["target"], failure["reason"]) for failure in summary["failed"]] == [
+            ("a.example.com", "unauthorized"), ("b.example.com", "unauthorized"), ("c.example.com", "unauthorized")
+        ]
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_rejected_definition_logs_field_errors(self, mock_request, client):
+        mock_request.return_value = make_response(INVALID_DEFINITION, status_code=400)
+        client.create_config = {"measurements": [{"type": "ping", "target": "a.example.com"},
+                                                 {"type": "ping", "target": "b.example.com"}]}
+        client.load_config = MagicMock()
+
+        with patch("measurement_client.client.logger") as mock_logger:
+            summary = client.create_measurements()
+
+        assert mock_request.call_count == 2  # a rejected definition does not stop the others
+        assert [failure["reason"] for failure in summary["failed"]] == ["failed", "failed"]
+        assert any("definitions.0.target: This field is required." in c.args[0]
+                   for c in mock_logger.error.call_args_list)
+
+    @pytest.mark.parametrize("status_code, headers, advice", [
+        (401, None, "RIPE_ATLAS_API_KEY"),
+        (402, None, "sintra credits"),
+        (429, {"Retry-After": "42"}, "wait 42s"),
+        (400, None, "  definitions.0.target: This field is required.")
+    ])
+    @patch("sintra.logger")
+    def test_cli_advice(self, mock_logger, status_code, headers, advice):
+        body = INVALID_DEFINITION if status_code == 400 else None
+        sintra.report_api_error(api_error(make_response(body, status_code=status_code, headers=headers)))
+
+        assert any(advice in c.args[0] for c in mock_logger.error.call_args_list)
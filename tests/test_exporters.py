@@ -0,0 +1,348 @@
+"""
+Unit tests for exporting fetched results as per-probe rows.
+"""
+import csv
+import io
+import json
+import math
+from datetime import datetime, timezone
+from pathlib import Path
+import pytest
+import yaml
+from measurement_client.exporters import (
+    RowWriter, result_rows, annotate_rows, write_rows, result_matrix, write_matrix, write_chartjs,
+    align_results, ResultTable, to_table
+)
+
+GOLDEN_DIR = Path(__file__).parent / "golden"
+
+
+def make_processed(results):
+    """Wrap probe results into the structure saved by `sintra fetch`."""
+    return {"measurement_id": 1001, "measurement_type": "ping", "target": "8.8.8.8", "results": results}
+
+
+def make_probe(probe_id, avg_rtt, loss):
+    rtts = [] if avg_rtt is None else [avg_rtt]
+    return {
+        "probe_id": probe_id,
+        "measurement_type": "ping",
+        "target_address": "8.8.8.8",
+        "latency_stats": {"avg": avg_rtt, "min": avg_rtt, "max": avg_rtt, "rtts": rtts},
+        "packet_loss_percentage": loss,
+        "packets_sent": 3,
+        "packets_received": len(rtts)
+    }
+
+
+@pytest.fixture
+def rows():
+    return result_rows(make_processed([
+        make_probe(1, 20.0, 0.0),
+        make_probe(2, 300.0, 0.0),
+        make_probe(3, 40.0, 33.3),
+        make_probe(4, None, 100.0)
+    ]))
+
+
+class TestAnnotation:
+    def test_rows_flag_rule_matches(self, rows):
+        annotate_rows(rows, latency_threshold_ms=250.0, loss_threshold_pct=0.0)
+        by_probe = {r["probe_id"]: r["anomaly"] for r in rows}
+        assert by_probe[1] == []
+        assert by_probe[2] == ["latency_spike"]
+        assert by_probe[3] == ["packet_loss"]
+        assert by_probe[4] == ["packet_loss", "unreachable_host"]
+
+    def test_thresholds_are_configurable(self, rows):
+        annotate_rows(rows, latency_threshold_ms=500.0, loss_threshold_pct=50.0)
+        by_probe = {r["probe_id"]: r["anomaly"] for r in rows}
+        assert by_probe[2] == []
+        assert by_probe[3] == []
+
+    def test_annotated_csv(self, rows):
+        annotate_rows(rows)
+        out = io.StringIO()
+        write_rows(rows, "csv", out)
+
+        parsed = list(csv.DictReader(io.StringIO(out.getvalue())))
+        assert parsed[0]["anomaly"] == ""
+        assert parsed[1]["anomaly"] == "latency_spike"
+        assert parsed[3]["anomaly"] == "packet_loss;unreachable_host"
+        assert parsed[1]["rtt_avg"] == "300.0"
+
+    def test_annotated_json(self, rows):
+        annotate_rows(rows)
+        out = io.StringIO()
+        write_rows(rows, "json", out)
+
+        parsed = json.loads(out.getvalue())
+        assert parsed[1]["anomaly"] == ["latency_spike"]
+        assert parsed[1]["measurement_id"] == 1001
+
+    def test_unannotated_csv_has_no_anomaly_column(self, rows):
+        out = io.StringIO()
+        write_rows(rows, "csv", out)
+        assert "anomaly" not in out.getvalue().splitlines()[0]
+
+
+# === Test: Enriched columns ===
+
+class TestEnrichedExport:
+    def test_enriched_results_add_columns(self):
+        probe = dict(make_probe(1, 20.0, 0.0), firmware_version=5080,
+                     probe_hardware_version="v4", probe_is_anchor=False)
+        out = io.StringIO()
+
+        write_rows(result_rows(make_processed([probe])), "csv", out)
+
+        row = next(csv.DictReader(io.StringIO(out.getvalue())))
+        assert (row["probe_firmware"], row["probe_hardware_version"], row["probe_is_anchor"]) == ("5080", "v4", "False")
+
+    def test_plain_results_have_no_enriched_columns(self, rows):
+        out = io.StringIO()
+        write_rows(rows, "csv", out)
+
+        assert "probe_firmware" not in out.getvalue().splitlines()[0]
+
+
+# === Test: Probe x time matrix ===
+
+class TestResultMatrix:
+    @staticmethod
+    def row(probe_id, timestamp, rtt_avg):
+        return {"probe_id": probe_id, "timestamp": timestamp, "rtt_avg": rtt_avg}
+
+    def test_pivot_buckets_and_missing_cells(self):
+        base = 1700000000 - 1700000000 % 600
+        rows = [
+            self.row(2, base + 10, 20.0),
+            self.row(2, base + 70, 30.0),            # same 10 minute bucket, averaged
+            self.row(1, base + 1300, 5.0),           # third bucket only
+            self.row(1, base + 620, None),           # 100% loss: bucket exists, cell stays NaN
+        ]
+
+        probes, times, values = result_matrix(rows, resolution=600)
+
+        assert probes == [1, 2]
+        assert times == [datetime.fromtimestamp(base + i * 600, timezone.utc) for i in range(3)]
+        assert math.isnan(values[0][0]) and math.isnan(values[0][1])
+        assert values[0][2] == 5.0
+        assert values[1][0] == 25.0
+        assert math.isnan(values[1][1]) and math.isnan(values[1][2])
+
+    def test_iso_timestamps_are_utc(self):
+        probes, times, _ = result_matrix([self.row(7, "2023-11-14T22:13:20", 1.0)], resolution=3600)
+        assert probes == [7]
+        assert times == [datetime(2023, 11, 14, 22, tzinfo=timezone.utc)]
+
+    def test_empty_and_invalid_resolution(self):
+        assert result_matrix([]) == ([], [], [])
+        with pytest.raises(ValueError, match="greater than zero"):
+            result_matrix([], resolution=0)
+
+    def test_csv_writes_nan_cells(self):
+        times = [datetime(2024, 1, 1, h, tzinfo=timezone.utc) for h in (0, 1)]
+        out = io.StringIO()
+        write_matrix([1], times, [[12.3456, math.nan]], out)
+
+        assert out.getvalue().splitlines() == [
+            "probe_id,2024-01-01T00:00:00Z,2024-01-01T01:00:00Z",
+            "1,12.346,NaN"
+        ]
+
+
+# === Test: Aligning probes to common instants ===
+
+CHART_BASE = 1704067200  # 2024-01-01T00:00:00Z
+
+
+def at(epoch):
+    return datetime.fromtimestamp(epoch, timezone.utc)
+
+
+class TestAlignResults:
+    def test_offset_samples_snap_to_nearest_bucket(self):
+        rows = [
+            {"probe_id": 1, "timestamp": CHART_BASE - 20, "rtt_avg": 10.0},          # just before the hour
+            {"probe_id": 2, "timestamp": CHART_BASE + 45, "rtt_avg": 20.0},          # just after it
+            {"probe_id": 1, "timestamp": CHART_BASE + 3600 + 1799, "rtt_avg": 12.0}, # still nearer 01:00
+            {"probe_id": 2, "timestamp": CHART_BASE + 3600 - 30, "rtt_avg": 22.0},
+            {"probe_id": 2, "timestamp": CHART_BASE + 3600 + 30, "rtt_avg": 24.0},   # same cell, averaged
+        ]
+
+        aligned = align_results(rows, bucket=3600)
+
+        assert aligned == {
+            at(CHART_BASE): {1: 10.0, 2: 20.0},
+            at(CHART_BASE + 3600): {1: 12.0, 2: 23.0},
+        }
+
+    def test_halfway_goes_to_later_bucket(self):
+        rows = [{"probe_id": 1, "timestamp": CHART_BASE + 300, "rtt_avg": 1.0},
+                {"probe_id": 1, "timestamp": CHART_BASE + 900, "rtt_avg": 3.0}]
+
+        aligned = align_results(rows, bucket=600)
+
+        assert aligned == {at(CHART_BASE + 600): {1: 1.0}, at(CHART_BASE + 1200): {1: 3.0}}
+
+    def test_gaps_are_explicit(self):
+        rows = [{"probe_id": 1, "timestamp": CHART_BASE, "rtt_avg": 5.0},
+                {"probe_id": 2, "timestamp": CHART_BASE + 1800, "rtt_avg": None},
+                {"probe_id": 2, "timestamp": CHART_BASE + 3 * 900, "rtt_avg": 7.0}]
+
+        aligned = align_results(rows, bucket=900)
+
+        assert list(aligned) == [at(CHART_BASE + i * 900) for i in range(4)]
+        assert aligned[at(CHART_BASE + 900)] == {1: None, 2: None}
+        assert aligned[at(CHART_BASE + 1800)] == {1: None, 2: None}
+        assert aligned[at(CHART_BASE + 2700)] == {1: None, 2: 7.0}
+
+    def test_other_field_and_empty(self):
+        rows = [{"probe_id": 1, "timestamp": "2024-01-01T00:00:10", "packet_loss_percentage": 50.0}]
+        assert align_results(rows, 60, "packet_loss_percentage") == {at(CHART_BASE): {1: 50.0}}
+        assert align_results([]) == {}
+        with pytest.raises(ValueError):
+            align_results(rows, bucket=0)
+
+
+# === Test: Chart.js output ===
+
+def chart_rows():
+    """Two probes over three hours: probe 2 misses the second hour, probe 1 loses packets in the third."""
+    return [
+        {"probe_id": 1, "timestamp": CHART_BASE + 60, "rtt_avg": 10.0, "packet_loss_percentage": 0.0},
+        {"probe_id": 1, "timestamp": CHART_BASE + 1800, "rtt_avg": 14.0, "packet_loss_percentage": 0.0},
+        {"probe_id": 1, "timestamp": CHART_BASE + 3700, "rtt_avg": 11.5, "packet_loss_percentage": 0.0},
+        {"probe_id": 1, "timestamp": CHART_BASE + 7300, "rtt_avg": 40.25, "packet_loss_percentage": 33.3},
+        {"probe_id": 2, "timestamp": CHART_BASE + 120, "rtt_avg": 80.0, "packet_loss_percentage": 0.0},
+        {"probe_id": 2, "timestamp": CHART_BASE + 7400, "rtt_avg": None, "packet_loss_percentage": 100.0},
+    ]
+
+
+class TestChartJs:
+    @pytest.mark.parametrize("metric", ["rtt_avg", "loss", "jitter"])
+    def test_matches_golden(self, metric):
+        out = io.StringIO()
+        write_chartjs(*result_matrix(chart_rows(), 3600, metric), out, metric)
+
+        assert out.getvalue() == (GOLDEN_DIR / f"chartjs_{metric}.json").read_text()
+
+    def test_datasets_line_up_with_labels(self):
+        out = io.StringIO()
+        write_chartjs(*result_matrix(chart_rows(), 3600), out)
+
+        chart = json.loads(out.getvalue())
+        assert chart["labels"] == ["2024-01-01T00:00:00Z", "2024-01-01T01:00:00Z", "2024-01-01T02:00:00Z"]
+        assert [d["label"] for d in chart["datasets"]] == ["Probe 1", "Probe 2"]
+        assert all(len(d["data"]) == len(chart["labels"]) for d in chart["datasets"])
+        assert chart["datasets"][1]["data"] == [80.0, None, None]
+
+    def test_empty(self):
+        out = io.StringIO()
+        write_chartjs(*result_matrix([]), out)
+        assert json.loads(out.getvalue()) == {"metric": "rtt_avg", "units": "ms", "labels": [], "datasets": []}
+
+    def test_unknown_metric(self):
+        with pytest.raises(ValueError, match="Unsupported metric"):
+            result_matrix(chart_rows(), 3600, "mos")
+
+
+# === Test: YAML output ===
+
+class TestYamlExport:
+    def test_rows_match_golden(self, rows):
+        annotate_rows(rows)
+        out = io.StringIO()
+        write_rows(rows, "yaml", out)
+
+        assert out.getvalue() == (GOLDEN_DIR / "fetch_rows.yaml").read_text()
+
+    def test_streamed_rows_match_batch(self, rows):
+        annotate_rows(rows)
+        out = io.StringIO()
+        writer = RowWriter("yaml", out)
+        for row in rows:
+            writer.write(row)
+        writer.close()
+
+        assert out.getvalue() == (GOLDEN_DIR / "fetch_rows.yaml").read_text()
+
+    def test_empty_is_empty_list(self):
+        out = io.StringIO()
+        write_rows([], "yaml", out)
+        assert yaml.safe_load(out.getvalue()) == []
+
+    def test_shared_values_not_aliased(self, rows):
+        shared = ["latency_spike"]
+        for row in rows:
+            row["anomaly"] = shared
+        out = io.StringIO()
+        write_rows(rows, "yaml", out)
+        assert "&id" not in out.getvalue() and "*id" not in out.getvalue()
+
+    def test_large_result_set(self):
+        rows = result_rows(make_processed([make_probe(i, 10.0 + i % 50, 0.0) for i in range(5000)]))
+        out = io.StringIO()
+        writer = RowWriter("yaml", out)
+        for row in rows:
+            writer.write(row)
+        writer.close()
+
+        parsed = yaml.safe_load(out.getvalue())
+        assert len(parsed) == 5000
+        assert parsed[4999]["probe_id"] == 4999
+
+
+# === Test: Columnar result table ===
+
+class TestResultTable:
+    def test_columns_and_typed_rows(self, rows):
+        rows[0]["probe_id"] = "1"  # e.g. read back from CSV
+        table = to_table(rows)
+
+        assert table.columns[:3] == ["measurement_id", "measurement_type", "probe_id"]
+        assert len(table) == 4
+        assert table.column("probe_id") == [1, 2, 3, 4]
+        assert table.column("rtt_avg") == [20.0, 300.0, 40.0, None]
+        assert table.rows[0][table.columns.index("packets_sent")] == 3
+
+    def test_iterate_as_dicts(self, rows):
+        records = list(to_table(rows))
+
+        assert records[2]["probe_id"] == 3
+        assert records[2]["packet_loss_percentage"] == 33.3
+        assert set(records[0]) == set(to_table(rows).columns)
+
+    def test_filter_returns_new_table(self, rows):
+        table = to_table(rows)
+
+        lossy = table.filter(lambda row: (row["packet_loss_percentage"] or 0) > 0)
+
+        assert lossy.column("probe_id") == [3, 4]
+        assert len(table) == 4
+
+    def test_csv_matches_row_export(self, rows):
+        annotate_rows(rows)
+        expected = io.StringIO()
+        write_rows(rows, "csv", expected)
+
+        rendered = to_table(rows).to_csv()
+
+        assert rendered == expected.getvalue()
+        assert list(csv.DictReader(io.StringIO(rendered)))[1]["anomaly"] == "latency_spike"
+
+    def test_json_to_stream(self, rows):
+        out = io.StringIO()
+
+        assert to_table(rows).filter(lambda row: row["probe_id"] == 2).to_json(out) is None
+
+        parsed = json.loads(out.getvalue())
+        assert len(parsed) == 1
+        assert parsed[0]["rtt_avg"] == 300.0
+
+    def test_unknown_column_and_ragged_rows_rejected(self, rows):
+        with pytest.raises(KeyError, match="rtt_median"):
+            to_table(rows).column("rtt_median")
+        with pytest.raises(ValueError, match="2 columns"):
+            ResultTable(["probe_id", "rtt_avg"], [(1,)])
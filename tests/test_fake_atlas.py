@@ -0,0 +1,147 @@
+"""
+Unit tests for the in-memory FakeAtlasAPI and for running the client and CLI helpers against it.
+"""
+import pytest
+from unittest.mock import MagicMock, patch
+import sintra
+from measurement_client.api_metrics import RttHistogram
+from measurement_client.client import SintraMeasurementClient
+from measurement_client.errors import NotFoundError, QuotaExceededError, ValidationError
+from measurement_client.fake_atlas import FIRST_MEASUREMENT_ID, FakeAtlasAPI
+
+API = "https://atlas.ripe.net/api/v2"
+PROBES = [{"id": 1, "country_code": "NL", "asn_v4": 3333, "status": {"id": 1, "name": "Connected"}},
+          {"id": 2, "country_code": "DE", "asn_v4": 3320, "status": {"id": 1, "name": "Connected"}},
+          {"id": 3, "country_code": "NL", "asn_v4": 1136, "status": {"id": 2, "name": "Disconnected"}}]
+
+
+def ping(probe_id, timestamp, avg, sent=3, rcvd=3):
+    return {"type": "ping", "prb_id": probe_id, "timestamp": timestamp, "avg": avg, "min": avg, "max": avg,
+            "sent": sent, "rcvd": rcvd, "dst_addr": "192.0.2.1", "from": "198.51.100.1", "af": 4,
+            "result": [{"rtt": avg}] * rcvd}
+
+
+@pytest.fixture
+def atlas():
+    return FakeAtlasAPI(probes=PROBES)
+
+
+@pytest.fixture
+def client(atlas, tmp_path, monkeypatch):
+    monkeypatch.setenv("RIPE_ATLAS_API_KEY", "test-key")
+    monkeypatch.chdir(tmp_path)
+    return SintraMeasurementClient(api=atlas)
+
+
+# === Test: Fake API ===
+
+class TestFakeAtlasAPI:
+    def test_create_then_read(self, atlas):
+        response = atlas.request("POST", f"{API}/measurements/", json={
+            "definitions": [{"type": "ping", "target": "example.com"}], "probes": [{"requested": 5}]
+        })
+        assert response.status_code == 201
+        assert response.json() == {"measurements": [FIRST_MEASUREMENT_ID]}
+
+        measurement = atlas.request("GET", f"{API}/measurements/{FIRST_MEASUREMENT_ID}/").json()
+        assert measurement["target"] == "example.com" and measurement["status"]["name"] == "Ongoing"
+        assert measurement["probes_requested"] == 5
+
+    def test_rejected_definition_uses_atlas_error_body(self, atlas):
+        response = atlas.request("POST", f"{API}/measurements/", json={"definitions": [{"type": "ping"}]})
+
+        assert response.status_code == 400
+        pointers = [error["source"]["pointer"] for error in response.json()["error"]["errors"]]
+        assert pointers == ["/definitions/0/target", "/probes"]
+        assert atlas.measurements == {}
+
+    def test_probe_filters(self, atlas):
+        def ids(**params):
+            return [probe["id"] for probe in atlas.request("GET", f"{API}/probes/", params=params).json()["results"]]
+
+        assert ids(country_code="NL") == [1, 3]
+        assert ids(country_code="NL", status=1) == [1]
+        assert ids(id__in="2,3") == [2, 3]
+        assert ids(asn_v4=3320, page_size=500, tags="home") == [2]  # unknown fields do not filter
+
+    def test_requests_recorded_and_failures_injected(self, atlas):
+        atlas.fail_next(503, times=2)
+
+        statuses = [atlas.request("GET", f"{API}/credits/?format=json").status_code for _ in range(3)]
+        assert statuses == [503, 503, 200]
+        assert atlas.requests[-1] == ("GET", "/api/v2/credits/", {"format": "json"}, None)
+
+    def test_unknown_endpoint_and_method(self, atlas):
+        assert atlas.request("GET", f"{API}/anchors/").status_code == 404
+        assert atlas.request("PUT", f"{API}/credits/").status_code == 405
+
+
+# === Test: Client against the fake ===
+
+class TestClientWithFakeAtlas:
+    def test_default_api_is_the_session(self, monkeypatch):
+        monkeypatch.setenv("RIPE_ATLAS_API_KEY", "test-key")
+        client = SintraMeasurementClient()
+        assert client.api is client.session
+
+    def test_measurement_lifecycle(self, client, atlas):
+        client.create_config = {"measurements": [{"type": "ping", "target": "example.com", "interval": 300,
+                                                  "probes": {"type": "country", "value": "NL", "requested": 2}}]}
+        client.load_config = MagicMock()
+
+        summary = client.create_measurements()
+        measurement_id = summary["created"][0]["measurement_id"]
+        assert measurement_id == FIRST_MEASUREMENT_ID and summary["failed"] == []
+
+        atlas.add_results(measurement_id, [ping(1, 1700000000, 10.0), ping(2, 1700000060, 30.0),
+                                           ping(1, 1700000300, 12.0)])
+        assert [result.avg_rtt for result in client.fetch_results(measurement_id, start=1700000000,
+                                                                  stop=1700000400)] == [10.0, 30.0, 12.0]
+        assert [result.avg_rtt for result in client.latest_results(measurement_id)] == [12.0, 30.0]
+
+        assert client.stop_measurement(measurement_id) is True
+        assert client.measurement_status(measurement_id)["stopped"] is True
+        assert client.stop_measurement(measurement_id) is False
+
+    def test_retries_through_injected_failures(self, client, atlas):
+        atlas.fail_next(503, times=2)
+
+        with patch("measurement_client.client.Context.wait"):
+            assert client.get_credit_balance()["current_balance"] == 100000
+        assert len(atlas.requests) == 3
+
+    @pytest.mark.parametrize("status_code, body, error_type", [
+        (400, {"error": {"detail": "Not enough credits to schedule this measurement"}}, QuotaExceededError),
+        (400, {"error": {"detail": "Invalid", "errors": [{"source": {"pointer": "/probes"}, "detail": "Bad"}]}},
+         ValidationError)
+    ])
+    def test_injected_errors_are_typed(self, client, atlas, status_code, body, error_type):
+        atlas.fail_next(status_code, body)
+
+        with pytest.raises(error_type):
+            client._request_with_backoff(f"{client.base_url}/measurements/", method="POST", json={})
+
+    def test_missing_measurement(self, client):
+        assert client.measurement_status(1) is None
+        with pytest.raises(NotFoundError):
+            client.fetch_latest_results(1)
+
+    def test_status_check(self, client, atlas):
+        measurement_id = atlas.add_measurement({"type": "ping", "target": "example.com"})
+        atlas.add_results(measurement_id, [ping(1, 1700000000, 10.0), ping(2, 1700000000, 0, rcvd=0)])
+
+        states = client.status_check(measurement_id)
+        assert states["counts"] == {"up": 1, "down": 1, "unknown": 0}
+        assert states["probes"]["2"]["alert_reasons"] == ["loss"]
+
+
+# === Test: CLI helpers against the fake ===
+
+class TestCliWithFakeAtlas:
+    def test_poll_latest_rtts(self, client, atlas):
+        measurement_id = atlas.add_measurement({"type": "ping", "target": "example.com"})
+        atlas.add_results(measurement_id, [ping(1, 1700000000, 10.0), ping(2, 1700000000, 30.0)])
+        histogram = RttHistogram()
+
+        assert sintra.poll_latest_rtts(client, [measurement_id, 42], histogram) == 2
+        assert sintra.poll_latest_rtts(client, [measurement_id], histogram) == 0
@@ -0,0 +1,194 @@
+"""
+Unit tests for the ETag / Last-Modified response cache and its use by the client and CLI.
+"""
+import os
+import pytest
+from unittest.mock import patch
+import sintra
+from measurement_client.client import SintraMeasurementClient
+from measurement_client.fake_atlas import FakeAtlasAPI, _response
+from measurement_client.http_cache import CachingAtlasAPI
+
+API = "https://atlas.ripe.net/api/v2"
+HEADERS = {"Authorization": "Key test-key"}
+
+
+def ping(probe_id, timestamp, avg):
+    return {"type": "ping", "prb_id": probe_id, "timestamp": timestamp, "avg": avg, "sent": 1, "rcvd": 1,
+            "result": [{"rtt": avg}]}
+
+
+@pytest.fixture
+def atlas():
+    return FakeAtlasAPI()
+
+
+@pytest.fixture
+def cache(atlas, tmp_path):
+    return CachingAtlasAPI(atlas, tmp_path / "http_cache")
+
+
+class LastModifiedAPI:
+    """Serves one body with Last-Modified and answers 304 to a matching If-Modified-Since."""
+
+    LAST_MODIFIED = "Wed, 01 Jan 2025 00:00:00 GMT"
+
+    def __init__(self):
+        self.sent_headers = []
+
+    def request(self, method, url, headers=None, **kwargs):
+        self.sent_headers.append(dict(headers or {}))
+        if (headers or {}).get("If-Modified-Since") == self.LAST_MODIFIED:
+            return _response(url, 304, headers={"X-RateLimit-Remaining": "7"})
+        return _response(url, 200, {"id": 1, "type": "ping"}, headers={"Last-Modified": self.LAST_MODIFIED})
+
+
+# === Test: Conditional requests ===
+
+class TestCachingAtlasAPI:
+    def test_unchanged_metadata_served_from_cache(self, cache, atlas):
+        measurement_id = atlas.add_measurement({"type": "ping", "target": "example.com"})
+        url = f"{API}/measurements/{measurement_id}/"
+
+        first = cache.request("GET", url, headers=HEADERS).json()
+        second = cache.request("GET", url, headers=HEADERS)
+
+        assert second.status_code == 200 and second.json() == first
+        assert (cache.stores, cache.hits) == (1, 1)
+
+    def test_changed_data_downloaded_again(self, cache, atlas):
+        measurement_id = atlas.add_measurement({"type": "ping", "target": "example.com"})
+        url = f"{API}/measurements/{measurement_id}/"
+        cache.request("GET", url, headers=HEADERS)
+
+        atlas.measurements[measurement_id]["description"] = "renamed"
+
+        assert cache.request("GET", url, headers=HEADERS).json()["description"] == "renamed"
+        assert (cache.stores, cache.hits) == (2, 0)
+        assert cache.request("GET", url, headers=HEADERS).json()["description"] == "renamed"
+        assert cache.hits == 1
+
+    def test_streamed_result_pages(self, cache, atlas):
+        measurement_id = atlas.add_measurement({"type": "ping", "target": "example.com"})
+        atlas.add_results(measurement_id, [ping(1, 1700000000, 10.0), ping(2, 1700000060, 20.0)])
+        url = f"{API}/measurements/{measurement_id}/results/"
+        params = {"start": 1700000000, "stop": 1700000099}
+
+        bodies = []
+        for _ in range(2):
+            response = cache.request("GET", url, headers=HEADERS, params=params, stream=True)
+            bodies.append(b"".join(response.iter_content(chunk_size=16)))
+            response.close()
+
+        assert bodies[0] == bodies[1] and b"1700000060" in bodies[0]
+        assert cache.hits == 1
+        # Another page is another entry
+        cache.request("GET", url, headers=HEADERS, params={"start": 1700000100, "stop": 1700000199})
+        assert cache.stores == 2
+
+    @pytest.mark.parametrize("params", [{"start": 1700000000}, {"start": 1700000000, "stop": 4102444800}])
+    def test_windows_reaching_now_not_stored(self, cache, atlas, params):
+        measurement_id = atlas.add_measurement({"type": "ping", "target": "example.com"})
+        atlas.add_results(measurement_id, [ping(1, 1700000000, 10.0)])
+
+        response = cache.request("GET", f"{API}/measurements/{measurement_id}/results/", headers=HEADERS,
+                                 params=params)
+
+        assert response.json()[0]["avg"] == 10.0
+        assert cache.stores == 0 and not cache.directory.exists()
+
+    def test_least_recently_used_evicted(self, atlas, tmp_path):
+        cache = CachingAtlasAPI(atlas, tmp_path / "http_cache")
+        urls = [f"{API}/measurements/{atlas.add_measurement({'type': 'ping', 'target': 'example.com'})}/"
+                for _ in range(3)]
+        cache.request("GET", urls[0], headers=HEADERS)
+        entry_size = next(cache.directory.glob("*.cache")).stat().st_size
+        cache.max_bytes = 2 * entry_size + entry_size // 2
+
+        cache.request("GET", urls[1], headers=HEADERS)
+        for path, age in zip(sorted(cache.directory.glob("*.cache"), key=lambda p: p.stat().st_mtime), (20, 10)):
+            os.utime(path, (path.stat().st_atime, path.stat().st_mtime - age))
+        cache.request("GET", urls[0], headers=HEADERS)  # revalidated, so now the most recently used
+        cache.request("GET", urls[2], headers=HEADERS)
+
+        assert len(list(cache.directory.glob("*.cache"))) == 2
+        cache.request("GET", urls[0], headers=HEADERS)
+        cache.request("GET", urls[1], headers=HEADERS)
+        assert (cache.stores, cache.hits) == (4, 2)
+
+    def test_last_modified_and_rate_limit_headers(self, tmp_path):
+        api = LastModifiedAPI()
+        cache = CachingAtlasAPI(api, tmp_path)
+
+        cache.request("GET", f"{API}/measurements/1/")
+        response = cache.request("GET", f"{API}/measurements/1/")
+
+        assert api.sent_headers[1]["If-Modified-Since"] == LastModifiedAPI.LAST_MODIFIED
+        assert response.json() == {"id": 1, "type": "ping"}
+        assert response.headers["X-RateLimit-Remaining"] == "7"
+        assert response.headers["Last-Modified"] == LastModifiedAPI.LAST_MODIFIED
+
+    def test_only_cacheable_gets_stored(self, cache, atlas):
+        measurement_id = atlas.add_measurement({"type": "ping", "target": "example.com"})
+
+        cache.request("GET", f"{API}/credits/", headers=HEADERS)
+        cache.request("GET", f"{API}/measurements/{measurement_id}/latest/", headers=HEADERS)
+        cache.request("DELETE", f"{API}/measurements/{measurement_id}/", headers=HEADERS)
+        cache.request("GET", f"{API}/measurements/999/", headers=HEADERS)  # 404
+
+        assert cache.stores == 0 and not cache.directory.exists()
+
+    def test_entries_per_api_key(self, cache, atlas):
+        measurement_id = atlas.add_measurement({"type": "ping", "target": "example.com"})
+        url = f"{API}/measurements/{measurement_id}/"
+
+        cache.request("GET", url, headers=HEADERS)
+        cache.request("GET", url, headers={"Authorization": "Key other-key"})
+
+        assert (cache.stores, cache.hits) == (2, 0)
+        assert cache.clear() == 2
+
+    def test_vanished_entry_downloaded_again(self, cache, atlas):
+        measurement_id = atlas.add_measurement({"type": "ping", "target": "example.com"})
+        url = f"{API}/measurements/{measurement_id}/"
+        cache.request("GET", url, headers=HEADERS)
+
+        with patch.object(CachingAtlasAPI, "_open_entry", return_value=None):
+            response = cache.request("GET", url, headers=HEADERS)
+
+        assert response.status_code == 200 and response.json()["id"] == measurement_id
+        assert len(atlas.requests) == 3
+
+
+# === Test: Client and CLI wiring ===
+
+class TestClientHttpCache:
+    def test_repeated_fetches_revalidated(self, atlas, tmp_path, monkeypatch):
+        monkeypatch.setenv("RIPE_ATLAS_API_KEY", "test-key")
+        monkeypatch.chdir(tmp_path)
+        client = SintraMeasurementClient(api=atlas, http_cache=True)
+        measurement_id = atlas.add_measurement({"type": "ping", "target": "example.com"})
+        atlas.add_results(measurement_id, [ping(1, 1700000000, 10.0)])
+
+        runs = [[result.avg_rtt for result in client.fetch_results(measurement_id, 1700000000, 1700000099)]
+                for _ in range(2)]
+        client.measurement_status(measurement_id)
+        client.measurement_status(measurement_id)
+
+        assert runs == [[10.0], [10.0]]
+        assert (client.api.stores, client.api.hits) == (2, 2)
+        assert client.api.directory == client.http_cache_dir
+
+    def test_disabled_by_default(self, atlas, tmp_path, monkeypatch):
+        monkeypatch.setenv("RIPE_ATLAS_API_KEY", "test-key")
+        monkeypatch.chdir(tmp_path)
+        assert SintraMeasurementClient(api=atlas).api is atlas
+
+    @pytest.mark.parametrize("flags, enabled", [([], True), (["--no-http-cache"], False)])
+    @patch("sintra.SintraMeasurementClient")
+    def test_cli_flag(self, mock_client_cls, flags, enabled):
+        mock_client_cls.return_value.list_measurements.return_value = []
+
+        sintra.handle_list_command(sintra.create_parser().parse_args(flags + ["list"]))
+
+        assert mock_client_cls.call_args.kwargs["http_cache"] is enabled
@@ -0,0 +1,109 @@
+"""
+Unit tests for the --debug-http request/response dump.
+"""
+import gzip
+import io
+import json
+import threading
+from http.server import BaseHTTPRequestHandler, HTTPServer
+import pytest
+import requests
+from measurement_client.client import SintraMeasurementClient
+from measurement_client.http_debug import REDACTED, format_exchange, http_debug_hook
+
+
+def make_exchange(url="https://atlas.ripe.net/api/v2/measurements/", method="POST", headers=None,
+                  json_body=None, status_code=201, response_headers=None, content=b'{"measurements": [1]}'):
+    response = requests.Response()
+    response.request = requests.Request(method, url, headers=headers or {}, json=json_body).prepare()
+    response.status_code = status_code
+    response.reason = "Created"
+    response.headers.update(response_headers or {})
+    response._content = content
+    return response
+
+
+class _EchoHandler(BaseHTTPRequestHandler):
+    def do_POST(self):
+        self.rfile.read(int(self.headers.get("Content-Length", 0)))
+        body = json.dumps({"measurements": [42]}).encode()
+        self.send_response(201)
+        self.send_header("Content-Type", "application/json")
+        self.send_header("Set-Cookie", "sessionid=abc123")
+        self.send_header("Content-Length", str(len(body)))
+        self.end_headers()
+        self.wfile.write(body)
+
+    def log_message(self, *args):
+        pass
+
+
+@pytest.fixture
+def echo_api():
+    server = HTTPServer(("127.0.0.1", 0), _EchoHandler)
+    thread = threading.Thread(target=server.serve_forever, daemon=True)
+    thread.start()
+    yield f"http://127.0.0.1:{server.server_port}/api/v2"
+    server.shutdown()
+    server.server_close()
+
+
+# === Test: Exchange dump ===
+
+class TestFormatExchange:
+    def test_dump_contains_request_and_response(self):
+        dump = format_exchange(make_exchange(json_body={"definitions": [{"type": "ping"}]}))
+
+        assert "> POST https://atlas.ripe.net/api/v2/measurements/" in dump
+        assert '"definitions": [{"type": "ping"}]' in dump
+        assert "< 201 Created" in dump
+        assert '< {"measurements": [1]}' in dump
+
+    def test_credentials_redacted_everywhere(self):
+        response = make_exchange(
+            url="https://atlas.ripe.net/api/v2/measurements/?key=secret-key&format=json",
+            headers={"Authorization": "Key secret-key", "Proxy-Authorization": "Basic dXNlcjpwYXNz"},
+            json_body={"note": "echoing secret-key back"},
+            response_headers={"Set-Cookie": "sessionid=abc123"},
+        )
+
+        dump = format_exchange(response, secrets=["secret-key"])
+
+        assert "secret-key" not in dump
+        assert "dXNlcjpwYXNz" not in dump
+        assert "abc123" not in dump
+        assert f"> Authorization: {REDACTED}" in dump
+        assert f"key={REDACTED}" in dump
+        assert "format=json" in dump
+
+    def test_streamed_and_gzip_bodies_not_dumped(self):
+        assert "<streamed body not shown>" in format_exchange(make_exchange(), streamed=True)
+        assert "bytes of gzip data>" in format_exchange(make_exchange(content=gzip.compress(b"[]")))
+
+
+# === Test: Client wiring ===
+
+class TestDebugHttpClient:
+    def test_client_dumps_exchange_with_key_redacted(self, tmp_path, monkeypatch, capsys, echo_api):
+        monkeypatch.setenv("RIPE_ATLAS_API_KEY", "test-secret-key")
+        monkeypatch.chdir(tmp_path)
+        client = SintraMeasurementClient(api_base=echo_api, debug_http=True)
+
+        client._request_with_backoff(f"{echo_api}/measurements/", method="POST", json={"is_oneoff": True})
+
+        dump = capsys.readouterr().err
+        assert f"> POST {echo_api}/measurements/" in dump
+        assert f"> Authorization: {REDACTED}" in dump
+        assert '"is_oneoff": true' in dump
+        assert "< 201" in dump
+        assert "test-secret-key" not in dump
+
+    def test_off_by_default(self, client):
+        assert client.session.hooks["response"] == []
+
+    def test_dump_goes_to_given_stream(self):
+        out = io.StringIO()
+
+        http_debug_hook(["atlas-key"], stream=out)(make_exchange(headers={"Authorization": "Key atlas-key"}))
+
+        assert f"> Authorization: {REDACTED}" in out.getvalue()
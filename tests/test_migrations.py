@@ -0,0 +1,93 @@
+"""
+Unit tests for config schema migrations.
+"""
+import pytest
+from unittest.mock import patch
+from measurement_client.migrations import CURRENT_CONFIG_VERSION, migrate_config, check_config_version
+
+# A config written before version 2, with probe sources copied from an Atlas payload
+V1_CONFIG = {
+    "measurements": [
+        {"type": "ping", "target": "8.8.8.8", "probes": {"type": "country", "value": "NL", "requested": 10}},
+        {"type": "ping", "target": "1.1.1.1", "probes": {"type": "probes", "value": "101,102"}},
+        {"type": "traceroute", "target": "9.9.9.9", "probes": {"area": "WW", "count": 5}}
+    ]
+}
+
+
+# === Test: Migrating old configs ===
+
+class TestMigrateConfig:
+    def test_atlas_style_sources_are_renamed(self):
+        migrated, changes = migrate_config(V1_CONFIG)
+
+        probes = [m["probes"] for m in migrated["measurements"]]
+        assert probes == [
+            {"country": "NL", "count": 10},
+            {"ids": [101, 102]},
+            {"area": "WW", "count": 5}
+        ]
+        assert migrated["version"] == CURRENT_CONFIG_VERSION
+        assert list(migrated)[0] == "version"
+        assert len(changes) == 3
+        assert "measurements[0].probes: type/value/requested -> country + count" in changes[0]
+
+    def test_input_is_not_modified(self):
+        migrate_config(V1_CONFIG)
+        assert V1_CONFIG["measurements"][0]["probes"]["type"] == "country"
+
+    def test_current_config_is_unchanged(self):
+        current = {"version": CURRENT_CONFIG_VERSION, "measurements": [{"target": "8.8.8.8"}]}
+
+        migrated, changes = migrate_config(current)
+
+        assert migrated == current
+        assert changes == []
+
+    def test_unsupported_source_left_alone(self):
+        config = {"measurements": [{"target": "8.8.8.8", "probes": {"type": "asn", "value": 3333, "requested": 2}}]}
+
+        migrated, changes = migrate_config(config)
+
+        assert migrated["measurements"][0]["probes"] == {"type": "asn", "value": 3333, "requested": 2}
+        assert "unsupported source type 'asn'" in changes[0]
+
+    def test_newer_version_rejected(self):
+        with pytest.raises(ValueError, match="newer than the supported version"):
+            migrate_config({"version": CURRENT_CONFIG_VERSION + 1})
+
+
+# === Test: Version-gated loading ===
+
+class TestConfigVersionGate:
+    @patch("measurement_client.migrations.logger")
+    def test_missing_version_defaults_to_1_with_warning(self, mock_logger):
+        assert check_config_version({"measurements": []}, "c.yaml") == 1
+        assert "sintra migrate --config c.yaml" in mock_logger.warning.call_args.args[0]
+
+    @patch("measurement_client.migrations.logger")
+    def test_current_version_loads_quietly(self, mock_logger):
+        assert check_config_version({"version": CURRENT_CONFIG_VERSION}, "c.yaml") == CURRENT_CONFIG_VERSION
+        mock_logger.warning.assert_not_called()
+
+    @patch("measurement_client.migrations.logger")
+    def test_old_version_warns(self, mock_logger):
+        assert check_config_version({"version": 1}, "c.yaml") == 1
+        assert "is version 1" in mock_logger.warning.call_args.args[0]
+
+    def test_future_version_rejected(self):
+        with pytest.raises(ValueError, match="newer than this Sintra supports"):
+            check_config_version({"version": CURRENT_CONFIG_VERSION + 1}, "c.yaml")
+
+    @pytest.mark.parametrize("version", ["2", 0, True])
+    def test_invalid_version_rejected(self, version):
+        with pytest.raises(ValueError, match="Invalid config version"):
+            check_config_version({"version": version}, "c.yaml")
+
+    def test_client_refuses_future_config(self, client, tmp_path):
+        config_file = tmp_path / "future.yaml"
+        config_file.write_text(f"version: {CURRENT_CONFIG_VERSION + 1}\nmeasurements:\n  - target: 8.8.8.8\n")
+        client.config_path = str(config_file)
+
+        with pytest.raises(ValueError, match="newer than this Sintra supports"):
+            client.load_config("create")
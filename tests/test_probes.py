@@ -0,0 +1,576 @@
+"""
+Unit tests for probe search and best-probe selection.
+"""
+import json
+import pytest
+from unittest.mock import patch
+from measurement_client.probes import (
+    rank_probes, probe_query_filters, PROBE_SCORERS, probe_participation, participation_is_low,
+    probe_metadata, load_probe_sets, resolve_probe_set_ref, probe_city, ProbeCache
+)
+from tests.conftest import make_response
+
+
+CONNECTED = {"id": 1, "name": "Connected"}
+DISCONNECTED = {"id": 2, "name": "Disconnected"}
+
+MOCK_PROBES = [
+    {"id": 10, "status": CONNECTED, "total_uptime": 1000, "status_since": 1700000000, "last_connected": 1700000500},
+    {"id": 11, "status": CONNECTED, "total_uptime": 9000, "status_since": 1600000000, "last_connected": 1700000100},
+    {"id": 12, "status": DISCONNECTED, "total_uptime": 99999, "status_since": 1500000000, "last_connected": 1700000900},
+    {"id": 13, "status": CONNECTED, "total_uptime": 5000, "status_since": 1650000000, "last_connected": 1700000200},
+]
+
+
+class TestRankProbes:
+    def test_uptime_picks_top_connected(self):
+        assert rank_probes(MOCK_PROBES, 2, "uptime") == [11, 13]
+
+    def test_connected_since_prefers_oldest_connection(self):
+        assert rank_probes(MOCK_PROBES, 3, "connected_since") == [11, 13, 10]
+
+    def test_last_seen_includes_disconnected(self):
+        assert rank_probes(MOCK_PROBES, 2, "last_seen") == [12, 10]
+
+    def test_fewer_candidates_than_requested(self):
+        assert rank_probes(MOCK_PROBES, 10, "uptime") == [11, 13, 10]
+
+    def test_unknown_score_rejected(self):
+        with pytest.raises(ValueError, match="Unknown probe score"):
+            rank_probes(MOCK_PROBES, 2, "fastest")
+
+    def test_custom_scorer_can_be_registered(self, monkeypatch):
+        monkeypatch.setitem(PROBE_SCORERS, "lowest_id", lambda p: -p["id"])
+        assert rank_probes(MOCK_PROBES, 2, "lowest_id") == [10, 11]
+
+
+# === Test: Probe diversity limits ===
+
+def located_probe(probe_id, uptime, asn, latitude, longitude):
+    return {"id": probe_id, "status": CONNECTED, "total_uptime": uptime, "asn_v4": asn,
+            "geometry": {"type": "Point", "coordinates": [longitude, latitude]}}
+
+
+DIVERSE_PROBES = [
+    located_probe(1, 900, 3320, 52.52, 13.40),  # Berlin
+    located_probe(2, 800, 3320, 52.51, 13.41),  # Berlin
+    located_probe(3, 700, 3320, 48.14, 11.58),  # Munich
+    located_probe(4, 600, 6805, 52.50, 13.39),  # Berlin
+    located_probe(5, 500, 6805, 50.11, 8.68),   # Frankfurt
+]
+
+
+class TestProbeDiversity:
+    def test_max_per_asn(self):
+        assert rank_probes(DIVERSE_PROBES, 4, "uptime", {"max_per_asn": 1}) == [1, 4]
+
+    def test_max_per_city(self):
+        assert rank_probes(DIVERSE_PROBES, 4, "uptime", {"max_per_city": 1}) == [1, 3, 5]
+
+    def test_limits_combine(self):
+        assert rank_probes(DIVERSE_PROBES, 5, "uptime", {"max_per_asn": 2, "max_per_city": 2}) == [1, 2, 5]
+
+    def test_probes_without_value_unlimited(self):
+        probes = [dict(probe, asn_v4=None, geometry=None) for probe in DIVERSE_PROBES]
+        assert probe_city(probes[0]) is None
+        assert rank_probes(probes, 3, "uptime", {"max_per_asn": 1, "max_per_city": 1}) == [1, 2, 3]
+
+    def test_city_from_top_level_coordinates(self):
+        assert probe_city({"latitude": 52.52, "longitude": 13.40}) == probe_city(DIVERSE_PROBES[0])
+
+    def test_unknown_limit_rejected(self):
+        with pytest.raises(ValueError, match="Unknown probe diversity limit"):
+            rank_probes(DIVERSE_PROBES, 2, "uptime", {"max_per_region": 1})
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_probe_query_applies_limits(self, mock_request, client):
+        mock_request.return_value = make_response({"next": None, "results": DIVERSE_PROBES})
+        config = {"type": "ping", "target": "8.8.8.8",
+                  "probe_query": {"strategy": "best", "count": 3, "max_per_asn": 1}}
+
+        assert client._resolve_probe_query(config)["probes"] == {"ids": [1, 4]}
+
+    @pytest.mark.parametrize("limit", [0, -1, "2", True])
+    def test_validation_rejects_bad_limit(self, client, limit):
+        client.create_config = {"measurements": [
+            {"type": "ping", "target": "8.8.8.8", "probe_query": {"strategy": "best", "max_per_city": limit}}
+        ]}
+        with pytest.raises(ValueError, match="probe_query max_per_city must be a positive integer"):
+            client._validate_create_config()
+
+
+class TestProbeQuery:
+    def test_filters_from_config(self):
+        filters = probe_query_filters({"strategy": "best", "tags": ["system-ipv6-works", "home"], "country": "NL"})
+        assert filters == {"tags": "system-ipv6-works,home", "country_code": "NL", "status": 1}
+
+    def test_geo_radius_filter(self):
+        filters = probe_query_filters({"geo": {"latitude": 52.37, "longitude": 4.89, "radius_km": 50}})
+        assert filters == {"radius": "52.37,4.89:50", "status": 1}
+
+    @pytest.mark.parametrize("geo", [
+        {"latitude": 91, "longitude": 0, "radius_km": 10},
+        {"latitude": 0, "longitude": -181, "radius_km": 10},
+        {"latitude": 0, "longitude": 0, "radius_km": 0},
+        {"latitude": 0, "longitude": 0},
+        "52.37,4.89:50",
+    ])
+    def test_validation_rejects_bad_geo(self, client, geo):
+        client.create_config = {"measurements": [
+            {"type": "ping", "target": "8.8.8.8", "probe_query": {"strategy": "best", "geo": geo}}
+        ]}
+        with pytest.raises(ValueError, match="probe_query geo"):
+            client._validate_create_config()
+
+    def test_ipv6_requires_ipv6_probes(self):
+        assert probe_query_filters({"country": "NL"}, af=6)["tags"] == "system-ipv6-works"
+        assert probe_query_filters({"tags": ["home"]}, af=6)["tags"] == "home,system-ipv6-works"
+        assert probe_query_filters({"tags": ["system-ipv6-works"]}, af=6)["tags"] == "system-ipv6-works"
+        assert "tags" not in probe_query_filters({"country": "NL"})
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_search_follows_pagination(self, mock_request, client):
+        mock_request.side_effect = [
+            make_response({"next": "https://atlas.ripe.net/api/v2/probes/?page=2", "results": MOCK_PROBES[:2]}),
+            make_response({"next": None, "results": MOCK_PROBES[2:]}),
+        ]
+        probes = client.search_probes({"country_code": "NL"})
+        assert [p["id"] for p in probes] == [10, 11, 12, 13]
+        assert mock_request.call_args_list[1].args[1] == "https://atlas.ripe.net/api/v2/probes/?page=2"
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_best_strategy_freezes_ids_into_definition(self, mock_request, client):
+        mock_request.return_value = make_response({"next": None, "results": MOCK_PROBES})
+        config = {
+            "type": "ping",
+            "target": "8.8.8.8",
+            "probe_query": {"strategy": "best", "tags": ["home"], "count": 2}
+        }
+
+        resolved = client._resolve_probe_query(config)
+
+        assert "probe_query" not in resolved
+        assert resolved["probes"] == {"ids": [11, 13]}
+        assert resolved["probe_selector"] == {"probe_query": config["probe_query"]}
+        assert client._create_source_configuration(resolved) == {"type": "probes", "value": "11,13", "requested": 2}
+
+    def test_validation_rejects_unknown_strategy(self, client):
+        client.create_config = {"measurements": [
+            {"type": "ping", "target": "8.8.8.8", "probe_query": {"strategy": "random"}}
+        ]}
+        with pytest.raises(ValueError, match="Invalid probe_query strategy"):
+            client._validate_create_config()
+
+
+# === Test: Probe participation ===
+
+def processed_with_probes(probe_ids, probes_requested=None):
+    return {
+        "measurement_id": 123,
+        "probes_requested": probes_requested,
+        "results": [{"probe_id": probe_id} for probe_id in probe_ids]
+    }
+
+
+class TestProbeParticipation:
+    def test_partial_participation_from_atlas_request(self):
+        participation = probe_participation(processed_with_probes([1, 2, 3], probes_requested=10))
+
+        assert participation == {"requested": 10, "participating": 3, "ratio": 0.3}
+        assert participation_is_low(participation)
+
+    def test_full_participation_is_not_low(self):
+        participation = probe_participation(processed_with_probes([1, 2, 3, 4, 5], probes_requested=5))
+
+        assert participation["ratio"] == 1.0
+        assert not participation_is_low(participation)
+
+    def test_falls_back_to_saved_config(self):
+        saved_by_count = {"config": {"probes": {"country": "NL", "count": 4}}}
+        saved_by_ids = {"config": {"probes": {"ids": [1, 2, 3, 4, 5]}}}
+
+        assert probe_participation(processed_with_probes([1, 2, 3]), saved_by_count)["requested"] == 4
+        assert probe_participation(processed_with_probes([1, 2, 3]), saved_by_ids)["requested"] == 5
+
+    def test_unknown_request_has_no_ratio(self):
+        participation = probe_participation(processed_with_probes([1, 1, 2]))
+
+        assert participation == {"requested": None, "participating": 2, "ratio": None}
+        assert not participation_is_low(participation)
+
+
+# === Test: Probe metadata enrichment ===
+
+def api_probe(probe_id, country, asn, slugs, is_anchor=False):
+    return {
+        "id": probe_id, "country_code": country, "asn_v4": asn, "is_anchor": is_anchor,
+        "status": CONNECTED, "tags": [{"name": slug, "slug": slug} for slug in slugs]
+    }
+
+
+class TestProbeMetadata:
+    def test_hardware_version_from_system_tags(self):
+        metadata = probe_metadata(api_probe(1, "NL", 3333, ["home", "system-ipv4-works", "system-v3"]))
+
+        assert metadata == {
+            "hardware_version": "v3", "is_anchor": False, "system_tags": ["system-ipv4-works", "system-v3"]
+        }
+
+    def test_anchor_without_version_tag(self):
+        metadata = probe_metadata(api_probe(2, "DE", 3320, ["system-anchor"], is_anchor=True))
+
+        assert metadata["hardware_version"] == "anchor"
+        assert metadata["is_anchor"] is True
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_enriched_results_use_cached_probe_lookups(self, mock_request, client):
+        """Enrichment attaches metadata per probe and reuses lookups across measurements."""
+        mock_request.return_value = make_response({"results": [
+            api_probe(1, "NL", 3333, ["system-v4"]),
+            api_probe(2, "DE", 3320, ["system-anchor"], is_anchor=True)
+        ]})
+        client.enrich = True
+        raw = [
+            {"prb_id": 1, "fw": 5080, "timestamp": 1700000000, "result": [{"rtt": 5.0}]},
+            {"prb_id": 2, "fw": 5090, "timestamp": 1700000000, "result": [{"rtt": 9.0}]}
+        ]
+        info = {"type": "ping", "target": "8.8.8.8"}
+
+        first = client._process_all_results_with_regions(raw, 1, info)
+        second = client._process_all_results_with_regions(raw, 2, info)
+
+        by_probe = {r["probe_id"]: r for r in first["results"]}
+        assert by_probe[1]["probe_hardware_version"] == "v4"
+        assert by_probe[1]["firmware_version"] == 5080
+        assert by_probe[1]["probe_asn"] == 3333
+        assert by_probe[2]["probe_is_anchor"] is True
+        assert [r["probe_hardware_version"] for r in second["results"]] == ["v4", "anchor"]
+        assert mock_request.call_count == 1
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_not_enriched_by_default(self, mock_request, client):
+        mock_request.return_value = make_response({"results": [api_probe(1, "NL", 3333, ["system-v4"])]})
+        raw = [{"prb_id": 1, "fw": 5080, "timestamp": 1700000000, "result": [{"rtt": 5.0}]}]
+
+        processed = client._process_all_results_with_regions(raw, 1, {"type": "ping"})
+
+        assert "probe_hardware_version" not in processed["results"][0]
+
+
+# === Test: Probe-set library ===
+
+PROBE_SET_LIBRARY = """
+probe_sets:
+  eu-core:
+    probes:
+      area: West
+      count: 20
+  best-nl:
+    probe_query:
+      strategy: best
+      country: NL
+      count: 5
+"""
+
+
+@pytest.fixture
+def probe_set_file(tmp_path):
+    path = tmp_path / "probe_sets.yaml"
+    path.write_text(PROBE_SET_LIBRARY)
+    return path
+
+
+class TestProbeSets:
+    def test_reference_resolved(self, probe_set_file):
+        probe_sets = load_probe_sets(str(probe_set_file))
+
+        resolved = resolve_probe_set_ref({"target": "example.com", "probe_set_ref": "eu-core"}, probe_sets, 0)
+
+        assert resolved == {"target": "example.com", "probes": {"area": "West", "count": 20}, "probe_set": "eu-core"}
+
+    def test_resolved_sets_are_independent_copies(self, probe_set_file):
+        probe_sets = load_probe_sets(str(probe_set_file))
+        first = resolve_probe_set_ref({"probe_set_ref": "best-nl"}, probe_sets, 0)
+        first["probe_query"]["count"] = 99
+
+        assert resolve_probe_set_ref({"probe_set_ref": "best-nl"}, probe_sets, 1)["probe_query"]["count"] == 5
+
+    def test_definition_without_reference_unchanged(self):
+        measurement = {"target": "example.com", "probes": {"country": "JP"}}
+        assert resolve_probe_set_ref(measurement, None, 0) is measurement
+
+    def test_unknown_reference_lists_known_sets(self, probe_set_file):
+        with pytest.raises(ValueError, match="Measurement 2: Unknown probe set 'us-east'. Known sets: best-nl, eu-core"):
+            resolve_probe_set_ref({"probe_set_ref": "us-east"}, load_probe_sets(str(probe_set_file)), 2)
+
+    def test_reference_without_library(self):
+        with pytest.raises(ValueError, match="requires a probe-set file"):
+            resolve_probe_set_ref({"probe_set_ref": "eu-core"}, None, 0)
+
+    def test_reference_cannot_be_combined_with_probes(self, probe_set_file):
+        with pytest.raises(ValueError, match="Cannot specify 'probe_set_ref' together"):
+            resolve_probe_set_ref({"probe_set_ref": "eu-core", "probes": {"count": 3}},
+                                  load_probe_sets(str(probe_set_file)), 0)
+
+    @pytest.mark.parametrize("library", [
+        "eu-core: {probes: {count: 5}}\n",
+        "probe_sets:\n  broken: {count: 5}\n",
+        "probe_sets:\n  both: {probes: {count: 5}, probe_query: {strategy: best}}\n",
+    ])
+    def test_malformed_library_rejected(self, tmp_path, library):
+        path = tmp_path / "probe_sets.yaml"
+        path.write_text(library)
+        with pytest.raises(ValueError):
+            load_probe_sets(str(path))
+
+    def test_client_resolves_on_load(self, client, probe_set_file, tmp_path):
+        config = tmp_path / "create_config.yaml"
+        config.write_text("measurements:\n  - target: example.com\n    probe_set_ref: eu-core\n")
+        client.config_path = str(config)
+        client.probe_set_file = str(probe_set_file)
+
+        client.load_config("create")
+
+        assert client.create_config["measurements"][0]["probes"] == {"area": "West", "count": 20}
+        _, atlas_request = client._build_atlas_request(client.create_config["measurements"][0], 0)
+        assert atlas_request["probes"] == [{"type": "area", "value": "West", "requested": 20}]
+
+    def test_client_rejects_unknown_reference_on_load(self, client, probe_set_file, tmp_path):
+        config = tmp_path / "create_config.yaml"
+        config.write_text("measurements:\n  - target: example.com\n    probe_set_ref: missing\n")
+        client.config_path = str(config)
+        client.probe_set_file = str(probe_set_file)
+
+        with pytest.raises(ValueError, match="Unknown probe set 'missing'"):
+            client.load_config("create")
+
+
+# === Test: On-disk probe cache ===
+
+class FakeClock:
+    def __init__(self, now=1700000000.0):
+        self.now = now
+
+    def __call__(self):
+        return self.now
+
+
+class TestProbeCache:
+    def test_population_survives_reload(self, tmp_path):
+        clock = FakeClock()
+        cache = ProbeCache(tmp_path / "probe_cache.json", ttl=3600, clock=clock)
+        cache.put([api_probe(1, "NL", 3333, ["system-v3"]), api_probe(2, "DE", 3320, [])])
+        cache.save()
+
+        reloaded = ProbeCache(tmp_path / "probe_cache.json", ttl=3600, clock=clock)
+
+        assert len(reloaded) == 2
+        assert reloaded.get(1)["country_code"] == "NL"
+        assert reloaded.get(3) is None
+
+    def test_entries_expire_after_ttl(self, tmp_path):
+        clock = FakeClock()
+        cache = ProbeCache(tmp_path / "probe_cache.json", ttl=3600, clock=clock)
+        cache.put([api_probe(1, "NL", 3333, [])])
+        clock.now += 1800
+        cache.put([api_probe(2, "DE", 3320, [])])
+        cache.save()
+
+        clock.now += 2000
+        reloaded = ProbeCache(tmp_path / "probe_cache.json", ttl=3600, clock=clock)
+
+        assert reloaded.get(1) is None
+        assert reloaded.get(2)["country_code"] == "DE"
+        assert len(reloaded) == 1
+
+    def test_search_needs_fresh_full_sync(self, tmp_path):
+        clock = FakeClock()
+        cache = ProbeCache(tmp_path / "probe_cache.json", ttl=3600, clock=clock)
+        probes = [api_probe(1, "NL", 3333, ["home"]), api_probe(2, "NL", 1136, []),
+                  dict(api_probe(3, "NL", 3333, ["home"]), status=DISCONNECTED)]
+
+        cache.put(probes)
+        assert cache.search({"country_code": "NL"}) is None
+
+        cache.put(probes, complete=True)
+        matches = cache.search({"country_code": "NL", "asn": 3333, "status": 1, "tags": "home"})
+        assert [probe["id"] for probe in matches] == [1]
+        assert cache.search({"country_code": "NL", "is_anchor": True}) is None  # filter it cannot apply
+
+        clock.now += 3600
+        assert cache.search({"country_code": "NL"}) is None
+
+    def test_unreadable_file_ignored(self, tmp_path):
+        path = tmp_path / "probe_cache.json"
+        path.write_text("{not json")
+        assert len(ProbeCache(path)) == 0
+
+
+class TestClientProbeCache:
+    @patch("measurement_client.client.requests.Session.request")
+    def test_sync_then_select_without_api_calls(self, mock_request, client):
+        mock_request.return_value = make_response({"results": MOCK_PROBES, "next": None})
+
+        assert client.sync_probe_cache() == 4
+        assert client.probe_cache.path.exists()
+        mock_request.reset_mock()
+
+        assert client.select_best_probes({"status": 1}, 2) == [11, 13]
+        mock_request.assert_not_called()
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_enrichment_hits_cache_across_clients(self, mock_request, client):
+        mock_request.return_value = make_response({"results": [api_probe(1, "NL", 3333, ["system-v4"])]})
+        client._batch_fetch_probe_info([1])
+        assert mock_request.call_count == 1
+
+        client.probe_info_cache.clear()  # as in a new run
+        info = client._batch_fetch_probe_info([1])
+
+        assert info[1]["asn"] == 3333
+        assert info[1]["hardware_version"] == "v4"
+        assert mock_request.call_count == 1
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_single_lookup_uses_cache(self, mock_request, client):
+        mock_request.return_value = make_response(api_probe(7, "DE", 3320, []))
+        assert client._get_probe_info(7)["asn"] == 3320
+
+        info = client._get_probe_info(7)
+
+        assert info["country_code"] == "DE"
+        assert mock_request.call_count == 1
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_probe_details_fetches_each_probe_once(self, mock_request, client):
+        mock_request.return_value = make_response({"results": [api_probe(1, "NL", 3333, []),
+                                                               api_probe(2, "JP", 2497, [])]})
+
+        details = client.probe_details([1, 2, 1])
+
+        assert details[2]["country"] == "Japan"
+        assert mock_request.call_count == 1
+        assert client.probe_details([2]) == {2: details[2]}
+        assert mock_request.call_count == 1
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_expired_entries_fetched_again(self, mock_request, client):
+        mock_request.return_value = make_response({"results": [api_probe(1, "NL", 3333, [])]})
+        client.probe_cache.clock = FakeClock()
+        client._batch_fetch_probe_info([1])
+
+        client.probe_info_cache.clear()
+        client.probe_cache.clock.now += client.probe_cache.ttl
+        client._batch_fetch_probe_info([1])
+
+        assert mock_request.call_count == 2
+
+
+# === Test: Disconnected probe replacement ===
+
+class TestProbeReplacement:
+    @staticmethod
+    def _save_info(client, config):
+        info_file = client.created_measurements_dir / "measurement_555_info.json"
+        info_file.write_text(json.dumps({"measurement_id": 555, "target": "8.8.8.8", "config": config}))
+        return info_file
+
+    @staticmethod
+    def _api(participants, candidates):
+        """Fake Atlas API: the measurement's probes, their statuses, and a search for candidates."""
+        def request(method, url, params=None, **kwargs):
+            if url.endswith("/measurements/555/"):
+                return make_response({"id": 555, "probes": [{"id": probe["id"]} for probe in participants]})
+            if url.endswith("/participation-requests/"):
+                return make_response([{"id": 1}], status_code=201)
+            if "id__in" in (params or {}):
+                return make_response({"next": None, "results": participants})
+            return make_response({"next": None, "results": candidates})
+        return request
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_probe_query_replaced_from_search(self, mock_request, client):
+        info_file = self._save_info(client, {"type": "ping", "probes": {"ids": [11, 12]},
+                                             "probe_selector": {"probe_query": {"strategy": "best", "count": 2}}})
+        participants = [MOCK_PROBES[1], MOCK_PROBES[2]]  # 12 is disconnected
+        mock_request.side_effect = self._api(participants, MOCK_PROBES)
+
+        report = client.replace_disconnected_probes(555)
+
+        assert report == {"measurement_id": 555, "disconnected": [12], "added": [13], "replaced": True}
+        post = [c for c in mock_request.call_args_list if c.args[0] == "POST"][0]
+        assert post.kwargs["json"] == [
+            {"type": "probes", "value": "13", "requested": 1, "action": "add"},
+            {"type": "probes", "value": "12", "requested": 1, "action": "remove"}
+        ]
+        assert json.loads(info_file.read_text())["config"]["probes"] == {"ids": [11, 13]}
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_atlas_selector_requested_again(self, mock_request, client):
+        self._save_info(client, {"type": "ping", "probes": {"country": "NL", "count": 2}})
+        mock_request.side_effect = self._api([MOCK_PROBES[1], MOCK_PROBES[2]], [])
+
+        report = client.replace_disconnected_probes(555)
+
+        assert report["added"] == {"type": "country", "value": "NL", "requested": 1}
+        post = [c for c in mock_request.call_args_list if c.args[0] == "POST"][0]
+        assert post.kwargs["json"][0] == {"type": "country", "value": "NL", "requested": 1, "action": "add"}
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_dry_run_changes_nothing(self, mock_request, client):
+        self._save_info(client, {"type": "ping", "probes": {"area": "WW", "count": 2}})
+        mock_request.side_effect = self._api([MOCK_PROBES[2]], [])
+
+        report = client.replace_disconnected_probes(555, dry_run=True)
+
+        assert report["replaced"] is False
+        assert report["disconnected"] == [12]
+        assert not [c for c in mock_request.call_args_list if c.args[0] == "POST"]
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_plain_ids_and_connected_probes_left_alone(self, mock_request, client):
+        self._save_info(client, {"type": "ping", "probes": {"ids": [11, 12]}})
+        mock_request.side_effect = self._api([MOCK_PROBES[1], MOCK_PROBES[2]], MOCK_PROBES)
+        assert client.replace_disconnected_probes(555)["added"] is None
+
+        mock_request.side_effect = self._api([MOCK_PROBES[1]], MOCK_PROBES)
+        assert client.replace_disconnected_probes(555)["disconnected"] == []
+        assert not [c for c in mock_request.call_args_list if c.args[0] == "POST"]
+
+
+# === Test: Adding and removing probes ===
+
+class TestProbeModification:
+    @patch("measurement_client.client.requests.Session.request")
+    def test_add_and_remove_ids_update_saved_info(self, mock_request, client):
+        info_file = TestProbeReplacement._save_info(client, {"type": "ping", "probes": {"ids": [11, 12]}})
+        mock_request.return_value = make_response({"request_ids": [1]}, status_code=201)
+
+        client.add_probes(555, {"ids": [13, 11]})
+        client.remove_probes(555, [12])
+
+        posts = [c for c in mock_request.call_args_list if c.args[0] == "POST"]
+        assert posts[0].args[1].endswith("/measurements/555/participation-requests/")
+        assert posts[0].kwargs["json"] == [{"type": "probes", "value": "13,11", "requested": 2, "action": "add"}]
+        assert posts[1].kwargs["json"] == [{"type": "probes", "value": "12", "requested": 1, "action": "remove"}]
+        assert json.loads(info_file.read_text())["config"]["probes"] == {"ids": [11, 13]}
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_add_from_selector_follows_saved_address_family(self, mock_request, client):
+        info_file = TestProbeReplacement._save_info(client, {"type": "ping", "af": 6,
+                                                             "probes": {"country": "NL", "count": 2}})
+        mock_request.return_value = make_response({"request_ids": [1]}, status_code=201)
+
+        source = client.add_probes(555, {"asn": "AS3320", "count": 3})
+
+        assert source == {"type": "asn", "value": 3320, "requested": 3, "tags": {"include": ["system-ipv6-works"]}}
+        assert mock_request.call_args.kwargs["json"] == [dict(source, action="add")]
+        assert json.loads(info_file.read_text())["config"]["probes"] == {"country": "NL", "count": 2}
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_invalid_probes_rejected_before_request(self, mock_request, client):
+        with pytest.raises(ValueError, match="Invalid probes"):
+            client.add_probes(555, {"area": "Moon"})
+        with pytest.raises(ValueError, match="No probe IDs"):
+            client.remove_probes(555, [])
+        mock_request.assert_not_called()
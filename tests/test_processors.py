@@ -0,0 +1,149 @@
+"""
+Unit tests for the per-type result summarizers and their registry.
+"""
+import pytest
+from measurement_client import processors
+from measurement_client.processors import (
+    RESULT_SUMMARIZERS, summarize_result, process_ping_result, process_traceroute_result, process_dns_result,
+    process_http_result, process_ntp_result, validate_result_schema
+)
+
+
+# === Test: Summarizer dispatch ===
+
+class TestSummarizeResult:
+    @pytest.mark.parametrize("measurement_type, summarizer", [
+        ("ping", "process_ping_result"),
+        ("traceroute", "process_traceroute_result"),
+        ("dns", "process_dns_result"),
+        ("http", "process_http_result"),
+        ("ntp", "process_ntp_result"),
+    ])
+    def test_dispatches_on_result_type(self, monkeypatch, measurement_type, summarizer):
+        assert RESULT_SUMMARIZERS[measurement_type] is getattr(processors, summarizer)
+        calls = []
+        monkeypatch.setitem(RESULT_SUMMARIZERS, measurement_type, lambda result: calls.append(result) or "summary")
+
+        assert summarize_result({"type": measurement_type}) == "summary"
+        assert calls == [{"type": measurement_type}]
+
+    def test_explicit_type_overrides_result_type(self):
+        summary = summarize_result({"type": "ping", "result": [{}, {}]}, "traceroute")
+        assert summary["hops_count"] == 2
+
+    def test_unknown_type_gets_default_summary(self):
+        summary = summarize_result({"type": "wifi"})
+        assert summary["latency_stats"]["avg"] is None
+        assert summary["packet_loss_percentage"] is None
+
+    def test_new_type_registered_by_entry(self, monkeypatch):
+        monkeypatch.setitem(RESULT_SUMMARIZERS, "wifi", lambda result: {"latency_stats": {"avg": result["rt"]}})
+        assert summarize_result({"type": "wifi", "rt": 42.0})["latency_stats"]["avg"] == 42.0
+
+
+# === Test: Per-type summarizers ===
+
+class TestSummarizers:
+    def test_ping(self):
+        summary = process_ping_result({"result": [{"rtt": 10.0}, {"rtt": 20.0}, {"x": "*"}, {"rtt": 30.0}]})
+        assert summary["latency_stats"] == {"min": 10.0, "max": 30.0, "avg": 20.0, "median": 20.0}
+        assert summary["packet_loss_percentage"] == 25.0
+
+    def test_traceroute(self):
+        summary = process_traceroute_result({"result": [{"hop": 1}, {"hop": 2}, {"hop": 3}]})
+        assert summary["hops_count"] == 3
+        assert summary["latency_stats"]["avg"] is None
+
+    def test_dns_single_answer(self):
+        summary = process_dns_result({"type": "dns", "result": {"rt": 12.5, "ANCOUNT": 2}})
+        assert summary["latency_stats"]["avg"] == 12.5
+        assert summary["answer_count"] == 2
+        assert summary["packet_loss_percentage"] == 0.0
+
+    def test_dns_resultset_with_timeout(self):
+        summary = process_dns_result({"type": "dns", "resultset": [
+            {"result": {"rt": 10.0, "ANCOUNT": 1}},
+            {"result": {"rt": 30.0, "ANCOUNT": 1}},
+            {"error": {"timeout": 5000}},
+            {"result": {"rt": 20.0, "ANCOUNT": 0}},
+        ]})
+        assert summary["latency_stats"] == {"min": 10.0, "max": 30.0, "avg": 20.0, "median": 20.0}
+        assert summary["packet_loss_percentage"] == 25.0
+        assert summary["answer_count"] == 2
+
+    def test_http_with_failed_request(self):
+        summary = process_http_result({"type": "http", "result": [
+            {"res": 200, "rt": 120.0}, {"res": 503, "rt": 80.0}, {"err": "connect: timeout"}, {"res": 200, "rt": 100.0}
+        ]})
+        assert summary["latency_stats"] == {"min": 80.0, "max": 120.0, "avg": 100.0, "median": 100.0}
+        assert summary["packet_loss_percentage"] == 25.0
+        assert summary["status_codes"] == [200, 503]
+
+    def test_ntp_offset_and_stratum(self):
+        summary = process_ntp_result({"type": "ntp", "stratum": 2, "result": [
+            {"rtt": 0.020, "offset": -0.004}, {"x": "*"}, {"rtt": 0.030, "offset": -0.002}, {"rtt": 0.025, "offset": 0.001}
+        ]})
+        assert summary["latency_stats"]["min"] == pytest.approx(20.0)
+        assert summary["latency_stats"]["avg"] == pytest.approx(25.0)
+        assert summary["packet_loss_percentage"] == 25.0
+        assert summary["offset_ms"] == pytest.approx(-2.0)
+        assert summary["stratum"] == 2
+
+    def test_ntp_no_reply(self):
+        summary = process_ntp_result({"type": "ntp", "result": [{"x": "*"}, {"x": "*"}]})
+        assert summary["packet_loss_percentage"] == 100.0
+        assert summary["offset_ms"] is None
+        assert summary["stratum"] is None
+
+    def test_dns_empty_resultset(self):
+        summary = process_dns_result({"type": "dns", "resultset": []})
+        assert summary["latency_stats"]["avg"] is None
+        assert summary["answer_count"] == 0
+
+
+# === Test: Result schema drift ===
+
+PING_RESULTS = [
+    {"type": "ping", "prb_id": 1, "timestamp": 1700000000, "from": "192.0.2.1", "dst_addr": "198.51.100.1",
+     "result": [{"rtt": 10.0}, {"x": "*"}]},
+    {"type": "ping", "prb_id": 2, "timestamp": 1700000000, "from": "192.0.2.2", "dst_addr": "198.51.100.1",
+     "result": [{"x": "*"}]},
+]
+
+# The same results after a hypothetical API change: rtt renamed, prb_id sent as a string
+DRIFTED_PING_RESULTS = [
+    dict(result, prb_id=str(result["prb_id"]),
+         result=[{"round_trip": reply["rtt"]} if "rtt" in reply else reply for reply in result["result"]])
+    for result in PING_RESULTS
+]
+
+
+class TestResultSchema:
+    def test_expected_results_have_no_drift(self):
+        assert validate_result_schema(PING_RESULTS, "ping") == []
+        dns_result = {"type": "dns", "prb_id": 1, "timestamp": 1, "from": "192.0.2.1", "resultset": [
+            {"result": {"rt": 10.0, "ANCOUNT": 1}}, {"error": {"timeout": 5000}}
+        ]}
+        assert validate_result_schema([dns_result]) == []
+
+    def test_drifted_fixture_flagged(self):
+        drift = validate_result_schema(DRIFTED_PING_RESULTS, "ping")
+
+        assert {"field": "result[].rtt", "problem": "missing", "results": 2,
+                "expected": "int or float", "found": None} in drift
+        assert {"field": "prb_id", "problem": "type", "results": 2, "expected": "int", "found": "str"} in drift
+        assert len(drift) == 2
+
+    def test_missing_required_field_counted_per_result(self):
+        results = [dict(PING_RESULTS[0]), {k: v for k, v in PING_RESULTS[1].items() if k != "dst_addr"}]
+
+        assert validate_result_schema(results, "ping") == [
+            {"field": "dst_addr", "problem": "missing", "results": 1, "expected": "str", "found": None}
+        ]
+
+    def test_dns_answer_shape_checked(self):
+        drift = validate_result_schema([{"type": "dns", "prb_id": 1, "timestamp": 1, "from": "192.0.2.1",
+                                         "result": {"rt": "12"}}])
+
+        assert {(entry["field"], entry["problem"]) for entry in drift} == {("result.rt", "type"),
+                                                                           ("result.ANCOUNT", "missing")}
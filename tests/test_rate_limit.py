@@ -0,0 +1,133 @@
+"""
+Unit tests for the client-side rate limiter and its use by the client.
+"""
+import pytest
+import requests
+import yaml
+from unittest.mock import patch
+from measurement_client.client import SintraMeasurementClient
+from measurement_client.rate_limit import MAX_RATE_LIMIT_PAUSE, RateLimiter
+from tests.conftest import make_response
+
+
+class FakeClock:
+    """Stands in for time.monotonic and the limiter's wait, so waits advance time instantly."""
+
+    def __init__(self):
+        self.now = 1000.0
+        self.waits = []
+
+    def monotonic(self):
+        return self.now
+
+    def wait(self, seconds):
+        self.waits.append(round(seconds, 6))
+        self.now += seconds
+
+
+@pytest.fixture
+def clock():
+    clock = FakeClock()
+    with patch("measurement_client.rate_limit.time.monotonic", clock.monotonic):
+        yield clock
+
+
+# === Test: Token bucket ===
+
+class TestRateLimiter:
+    def test_burst_then_steady_rate(self, clock):
+        limiter = RateLimiter(requests_per_second=2, burst=3)
+
+        for _ in range(5):
+            limiter.acquire(clock.wait)
+
+        assert clock.waits == [0.5, 0.5]  # three at once, then one every half second
+
+    def test_unlimited_by_default(self, clock):
+        limiter = RateLimiter()
+        for _ in range(100):
+            limiter.acquire(clock.wait)
+        assert clock.waits == []
+
+    @pytest.mark.parametrize("kwargs", [{"requests_per_second": 0}, {"requests_per_second": 1, "burst": 0}])
+    def test_invalid_settings_rejected(self, kwargs):
+        with pytest.raises(ValueError, match="Rate limit"):
+            RateLimiter(**kwargs)
+
+
+# === Test: Rate-limit headers ===
+
+class TestRateLimitHeaders:
+    def test_retry_after_on_429_holds_requests(self, clock):
+        limiter = RateLimiter()
+        limiter.observe(429, {"Retry-After": "7"})
+
+        limiter.acquire(clock.wait)
+        assert clock.waits == [7.0]
+
+    def test_exhausted_limit_waits_for_reset(self, clock):
+        limiter = RateLimiter()
+        limiter.observe(200, {"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "12"})
+        limiter.acquire(clock.wait)
+
+        with patch("measurement_client.rate_limit.time.time", return_value=1_700_000_000.0):
+            limiter.observe(200, {"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1700000030"})  # Unix time
+        limiter.acquire(clock.wait)
+
+        assert clock.waits == [12.0, 30.0]
+
+    @pytest.mark.parametrize("status_code, headers", [
+        (200, {"X-RateLimit-Remaining": "4", "X-RateLimit-Reset": "12"}),
+        (200, {"Retry-After": "7"}),  # only honoured on 429
+        (429, {"Retry-After": "Wed, 21 Oct 2015 07:28:00 GMT"}),
+        (200, {})
+    ])
+    def test_headers_without_pause(self, clock, status_code, headers):
+        limiter = RateLimiter()
+        limiter.observe(status_code, headers)
+        limiter.acquire(clock.wait)
+        assert clock.waits == []
+
+    def test_pause_capped(self, clock):
+        limiter = RateLimiter()
+        limiter.observe(429, {"Retry-After": "86400"})
+        limiter.acquire(clock.wait)
+        assert clock.waits == [MAX_RATE_LIMIT_PAUSE]
+
+
+# === Test: Client wiring ===
+
+class TestClientRateLimit:
+    def test_limits_from_config(self, tmp_path, monkeypatch):
+        monkeypatch.setenv("RIPE_ATLAS_API_KEY", "test-key")
+        config_path = tmp_path / "fetch_config.yaml"
+        config_path.write_text(yaml.safe_dump({"measurement_ids": [1], "rate_limit": {"requests_per_second": 4,
+                                                                                       "burst": 8}}))
+
+        client = SintraMeasurementClient(config_path=str(config_path))
+        assert (client.rate_limiter.requests_per_second, client.rate_limiter.burst) == (4, 8)
+
+        client = SintraMeasurementClient(config_path=str(config_path), requests_per_second=1)
+        assert (client.rate_limiter.requests_per_second, client.rate_limiter.burst) == (1, 8)
+
+    def test_malformed_config_rejected(self, tmp_path, monkeypatch):
+        monkeypatch.setenv("RIPE_ATLAS_API_KEY", "test-key")
+        config_path = tmp_path / "fetch_config.yaml"
+        config_path.write_text(yaml.safe_dump({"rate_limit": {"per_minute": 60}}))
+
+        with pytest.raises(ValueError, match="rate_limit"):
+            SintraMeasurementClient(config_path=str(config_path))
+
+    @patch("measurement_client.client.requests.Session.request")
+    def test_429_holds_later_requests(self, mock_request, client, clock):
+        """A 429 answered to one caller makes every caller of the client wait for Retry-After."""
+        limited = make_response(status_code=429, headers={"Retry-After": "5"})
+        mock_request.side_effect = [limited, make_response({"id": 1})]
+
+        with patch("measurement_client.client.Context.wait", side_effect=clock.wait):
+            with pytest.raises(requests.HTTPError):
+                client._request_with_backoff(f"{client.base_url}/measurements/1/", max_retries=0)
+            client._request_with_backoff(f"{client.base_url}/measurements/2/")
+
+        assert clock.waits == [5.0]
+        assert mock_request.call_count == 2
@@ -0,0 +1,204 @@
+"""
+Unit tests for decoding raw Atlas results into typed results.
+"""
+import base64
+
+import pytest
+from measurement_client.dns_wire import decode_abuf
+from measurement_client.result_types import (
+    DnsRecord, PingResult, RESULT_DECODERS, TracerouteReply, decode_dns_result, decode_ping_result, decode_result,
+    decode_traceroute_result
+)
+
+PING = {
+    "af": 4, "avg": 12.5, "dst_addr": "8.8.8.8", "dst_name": "8.8.8.8", "dup": 0, "from": "192.0.2.1",
+    "fw": 5080, "max": 15.0, "min": 10.0, "msm_id": 1001, "prb_id": 6042, "proto": "ICMP", "rcvd": 2,
+    "result": [{"rtt": 10.0}, {"x": "*"}, {"rtt": 15.0}], "sent": 3, "size": 48, "src_addr": "10.0.0.2",
+    "step": 240, "timestamp": 1700000000, "ttl": 117, "type": "ping"
+}
+
+
+# === Test: Ping results ===
+
+class TestPingResult:
+    def test_decodes_atlas_ping(self):
+        ping = decode_ping_result(PING)
+
+        assert ping == PingResult(probe_id=6042, timestamp=1700000000, measurement_id=1001, target="8.8.8.8",
+                                  source="10.0.0.2", af=4, sent=3, received=2, min_rtt=10.0, avg_rtt=12.5,
+                                  max_rtt=15.0, rtts=[10.0, None, 15.0])
+        assert ping.packet_loss == pytest.approx(100 / 3)
+
+    def test_no_replies(self):
+        ping = decode_ping_result(dict(PING, rcvd=0, min=-1, avg=-1, max=-1,
+                                       result=[{"x": "*"}, {"error": "sendto failed: Network is unreachable"}]))
+
+        assert (ping.min_rtt, ping.avg_rtt, ping.max_rtt) == (None, None, None)
+        assert ping.rtts == [None, None]
+        assert ping.errors == ["sendto failed: Network is unreachable"]
+        assert ping.packet_loss == 100.0
+
+    def test_counts_and_stats_derived_from_packets(self):
+        ping = decode_ping_result({"type": "ping", "prb_id": 1, "timestamp": 1,
+                                   "result": [{"rtt": 4.0}, {"rtt": 8.0}, {"x": "*"}, {"rtt": -3}]})
+
+        assert (ping.sent, ping.received) == (4, 2)
+        assert (ping.min_rtt, ping.avg_rtt, ping.max_rtt) == (4.0, 6.0, 8.0)
+        assert decode_ping_result({"type": "ping"}).packet_loss is None
+
+    @pytest.mark.parametrize("raw", [{"type": "traceroute"}, ["not", "a", "result"]])
+    def test_non_ping_rejected(self, raw):
+        with pytest.raises(ValueError, match="Expected a ping result"):
+            decode_ping_result(raw)
+
+
+TRACEROUTE = {
+    "af": 4, "dst_addr": "93.184.216.34", "dst_name": "example.com", "endtime": 1700000005, "from": "192.0.2.1",
+    "msm_id": 2002, "paris_id": 1, "prb_id": 6042, "proto": "UDP", "src_addr": "10.0.0.2",
+    "timestamp": 1700000000, "type": "traceroute",
+    "result": [
+        {"hop": 1, "result": [{"from": "10.0.0.1", "rtt": 1.2, "size": 76, "ttl": 64},
+                              {"from": "10.0.0.1", "rtt": 1.4, "size": 76, "ttl": 64}, {"x": "*"}]},
+        {"hop": 2, "result": [{"x": "*"}, {"x": "*"}, {"x": "*"}]},
+        {"hop": 3, "result": [{"from": "93.184.216.34", "rtt": 20.5, "size": 28, "ttl": 55},
+                              {"from": "198.51.100.7", "rtt": 22.0, "size": 28, "ttl": 250}]}
+    ]
+}
+
+
+# === Test: Traceroute results ===
+
+class TestTracerouteResult:
+    def test_decodes_hops_and_reached_destination(self):
+        traceroute = decode_traceroute_result(TRACEROUTE)
+
+        assert (traceroute.probe_id, traceroute.measurement_id, traceroute.protocol) == (6042, 2002, "UDP")
+        assert traceroute.target == "93.184.216.34"
+        assert [hop.hop for hop in traceroute.hops] == [1, 2, 3]
+        assert traceroute.hops[0].addresses == ["10.0.0.1"]
+        assert traceroute.hops[0].rtts == [1.2, 1.4]
+        assert traceroute.hops[0].replies[0] == TracerouteReply(address="10.0.0.1", rtt=1.2, ttl=64)
+        assert all(reply.timed_out for reply in traceroute.hops[1].replies)
+        assert traceroute.hops[2].addresses == ["93.184.216.34", "198.51.100.7"]
+        assert traceroute.destination_reached is True
+        assert traceroute.icmp_errors == []
+
+    def test_icmp_errors_and_unreached_destination(self):
+        raw = dict(TRACEROUTE, result=[
+            TRACEROUTE["result"][0],
+            {"hop": 2, "result": [{"from": "10.0.0.9", "rtt": 5.0, "ttl": 63, "err": "H"},
+                                  {"from": "93.184.216.34", "rtt": 6.0, "ttl": 55, "err": 13}]},
+            {"hop": 255, "error": "sendto failed: Network is unreachable"}
+        ])
+
+        traceroute = decode_traceroute_result(raw)
+
+        assert traceroute.icmp_errors == ["H", "13"]
+        assert traceroute.hops[-1].error == "sendto failed: Network is unreachable"
+        assert traceroute.hops[-1].replies == []
+        assert traceroute.destination_reached is False
+
+        raw["result"] = raw["result"][:2]
+        assert decode_traceroute_result(raw).destination_reached is False  # the target replied with an error
+
+    def test_non_traceroute_rejected(self):
+        with pytest.raises(ValueError, match="Expected a traceroute result"):
+            decode_traceroute_result({"type": "ping"})
+
+
+# www.example.com A: a CNAME to example.com and its A record, both with compressed names
+ABUF = "EjSBgAABAAIAAAAAA3d3dwdleGFtcGxlA2NvbQAAAQABwAwABQABAAABLAACwBDAEAABAAEAAAA8AARduNgi"
+# nope.example AAAA: NXDOMAIN with no records
+NXDOMAIN_ABUF = "AAeBgwABAAAAAAAABG5vcGUHZXhhbXBsZQAAHAAB"
+
+DNS = {
+    "af": 4, "dst_addr": "9.9.9.9", "from": "192.0.2.1", "fw": 5080, "msm_id": 3003, "prb_id": 6042,
+    "proto": "UDP", "timestamp": 1700000000, "type": "dns",
+    "result": {"ANCOUNT": 2, "ARCOUNT": 0, "ID": 4660, "NSCOUNT": 0, "QDCOUNT": 1, "abuf": ABUF, "rt": 21.4,
+               "size": 64}
+}
+
+
+# === Test: DNS results ===
+
+class TestDnsResult:
+    def test_decodes_abuf_answers(self):
+        dns = decode_dns_result(DNS)
+
+        assert (dns.probe_id, dns.measurement_id) == (6042, 3003)
+        [response] = dns.responses
+        assert (response.resolver, response.protocol, response.af) == ("9.9.9.9", "UDP", 4)
+        assert (response.rcode, response.response_time, response.error) == ("NOERROR", 21.4, None)
+        assert dns.answers == [DnsRecord(name="www.example.com", type="CNAME", ttl=300, data="example.com"),
+                               DnsRecord(name="example.com", type="A", ttl=60, data="93.184.216.34")]
+
+    def test_resultset_with_errors(self):
+        raw = {"type": "dns", "prb_id": 6042, "timestamp": 1700000000, "msm_id": 3003, "resultset": [
+            {"af": 4, "dst_addr": "127.0.0.1", "proto": "UDP", "result": {"abuf": NXDOMAIN_ABUF, "rt": 3.0}},
+            {"af": 6, "dst_addr": "::1", "proto": "UDP", "error": {"timeout": 5000}},
+            {"af": 4, "dst_addr": "10.0.0.1", "proto": "TCP", "result": {"abuf": "not base64!", "rt": 1.0}}
+        ]}
+
+        nxdomain, timeout, garbled = decode_dns_result(raw).responses
+
+        assert (nxdomain.resolver, nxdomain.rcode, nxdomain.answers) == ("127.0.0.1", "NXDOMAIN", [])
+        assert (timeout.resolver, timeout.af, timeout.rcode) == ("::1", 6, None)
+        assert timeout.error == "timeout: 5000"
+        assert garbled.error.startswith("Cannot decode DNS message")
+        assert garbled.response_time == 1.0
+
+    def test_truncated_message_reported_as_error(self):
+        raw = dict(DNS, result={"abuf": ABUF[:40], "rt": 2.0})
+
+        response = decode_dns_result(raw).responses[0]
+
+        assert response.rcode is None
+        assert response.error.startswith("Cannot decode DNS message")
+
+    def test_non_dns_rejected(self):
+        with pytest.raises(ValueError, match="Expected a dns result"):
+            decode_dns_result({"type": "ping"})
+
+
+class TestDnsWire:
+    def test_decodes_header_and_questions(self):
+        message = decode_abuf(ABUF)
+
+        assert (message["id"], message["rcode"], message["truncated"]) == (0x1234, "NOERROR", False)
+        assert message["questions"] == [{"name": "www.example.com", "type": "A"}]
+        assert (message["authority"], message["additional"]) == ([], [])
+
+    def test_compression_loop_rejected(self):
+        # One question whose name is a pointer to itself
+        looping = bytes([0, 1, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0, 0xC0, 12, 0, 1, 0, 1])
+
+        with pytest.raises(ValueError, match="name compression loop"):
+            decode_abuf(base64.b64encode(looping).decode())
+
+    def test_long_names_decoded_up_to_the_limit(self):
+        # 64 one-character labels (129 bytes) at offset 12, repeated through a pointer: 255 bytes in all
+        header = bytes([0, 1, 0x81, 0x80, 0, 2, 0, 0, 0, 0, 0, 0])
+        labels = b"\x01a" * 64
+        fits = header + labels + b"\0\0\1\0\1" + b"\x01b" * 63 + b"\xc0\x0c\0\1\0\1"
+        too_long = header + labels + b"\0\0\1\0\1" + b"\x01b" * 64 + b"\xc0\x0c\0\1\0\1"
+
+        questions = decode_abuf(base64.b64encode(fits).decode())["questions"]
+
+        assert [question["name"].count(".") + 1 for question in questions] == [64, 127]
+        with pytest.raises(ValueError, match="longer than 255 bytes"):
+            decode_abuf(base64.b64encode(too_long).decode())
+
+
+# === Test: Decoder dispatch ===
+
+class TestDecodeResult:
+    def test_dispatches_on_result_type(self):
+        assert RESULT_DECODERS["ping"] is decode_ping_result
+        assert RESULT_DECODERS["traceroute"] is decode_traceroute_result
+        assert RESULT_DECODERS["dns"] is decode_dns_result
+        assert decode_result(PING).avg_rtt == 12.5
+        assert decode_result(TRACEROUTE).destination_reached is True
+
+    def test_unknown_type_rejected(self):
+        with pytest.raises(ValueError, match="No typed decoder for wifi results"):
+            decode_result({"type": "wifi"})
@@ -0,0 +1,156 @@
+"""
+Unit tests for the cron schedule parser and the scheduler loop.
+"""
+import threading
+from datetime import datetime, timezone
+import pytest
+import requests
+from unittest.mock import patch, MagicMock
+import sintra
+from measurement_client.scheduler import CronSchedule, Scheduler
+
+
+def utc(*args):
+    return datetime(*args, tzinfo=timezone.utc)
+
+
+# === Test: Cron expressions ===
+
+class TestCronSchedule:
+    def test_fields_parsed(self):
+        schedule = CronSchedule("*/15 9-17 * 1,6 1-5")
+        assert schedule.minutes == {0, 15, 30, 45}
+        assert schedule.hours == set(range(9, 18))
+        assert schedule.months == {1, 6}
+        assert schedule.weekdays == {1, 2, 3, 4, 5}
+
+    def test_sunday_as_seven(self):
+        assert CronSchedule("0 0 * * 7").weekdays == {0}
+
+    @pytest.mark.parametrize("expression, after, expected", [
+        ("0 * * * *", utc(2024, 1, 1, 10, 0), utc(2024, 1, 1, 11, 0)),
+        ("0 * * * *", utc(2024, 1, 1, 10, 59, 30), utc(2024, 1, 1, 11, 0)),
+        ("30 2 * * *", utc(2024, 1, 1, 3, 0), utc(2024, 1, 2, 2, 30)),
+        ("0 0 1 * *", utc(2024, 1, 15, 0, 0), utc(2024, 2, 1, 0, 0)),
+        ("0 12 * * 1", utc(2024, 1, 3, 0, 0), utc(2024, 1, 8, 12, 0)),        # next Monday
+        ("0 0 29 2 *", utc(2024, 3, 1, 0, 0), utc(2028, 2, 29, 0, 0)),
+    ])
+    def test_next_run(self, expression, after, expected):
+        assert CronSchedule(expression).next_run(after) == expected
+
+    def test_day_of_month_or_day_of_week(self):
+        """With both day fields restricted, either one matching is enough (as in cron)."""
+        schedule = CronSchedule("0 0 15 * 1")
+        assert schedule.next_run(utc(2024, 1, 2)) == utc(2024, 1, 8)    # Monday before the 15th
+        assert schedule.next_run(utc(2024, 1, 12)) == utc(2024, 1, 15)  # the 15th (also a Monday)
+        assert schedule.matches(utc(2024, 2, 15))                       # a Thursday
+
+    @pytest.mark.parametrize("expression", ["* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *",
+                                            "5-1 * * * *", "a * * * *", "0 0 31 2 *"])
+    def test_invalid_expressions_rejected(self, expression):
+        with pytest.raises(ValueError, match="(?i)cron"):
+            CronSchedule(expression).next_run(utc(2024, 1, 1))
+
+
+# === Test: Scheduler runs ===
+
+class TestScheduler:
+    def test_run_once_returns_job_result(self):
+        scheduler = Scheduler(CronSchedule("* * * * *"), lambda: {"created": [1]})
+
+        assert scheduler.run_once() == {"created": [1]}
+        assert scheduler.runs == 1
+
+    def test_failing_run_is_logged_not_raised(self):
+        def job():
+            raise RuntimeError("boom")
+        scheduler = Scheduler(CronSchedule("* * * * *"), job)
+
+        assert scheduler.run_once() is None
+        assert scheduler.runs == 1
+
+    def test_overlapping_run_skipped(self):
+        started, release = threading.Event(), threading.Event()
+
+        def job():
+            started.set()
+            release.wait(5)
+            return "done"
+        scheduler = Scheduler(CronSchedule("* * * * *"), job)
+
+        worker = threading.Thread(target=scheduler.run_once)
+        worker.start()
+        started.wait(5)
+        assert scheduler.run_once() is None
+        release.set()
+        worker.join()
+
+        assert scheduler.runs == 1
+        assert scheduler.skipped == 1
+
+    def test_run_forever_runs_at_scheduled_minute(self):
+        runs = []
+        scheduler = Scheduler(CronSchedule("* * * * *"), lambda: runs.append(1))
+        clock = iter([utc(2024, 1, 1, 0, 0, 30)] + [utc(2024, 1, 1, 0, 1, 0)] * 10)
+
+        def now():
+            if runs:
+                scheduler._stop.set()
+            return next(clock)
+
+        with patch.object(scheduler._stop, "wait"):
+            thread = threading.Thread(target=scheduler.run_forever, kwargs={"now": now})
+            thread.start()
+            thread.join(5)
+            scheduler.stop()
+
+        assert runs == [1]
+
+
+# === Test: schedule command ===
+
+class TestScheduleCommand:
+    @patch("sintra.SintraMeasurementClient")
+    @patch("measurement_client.scheduler.Scheduler.run_forever", side_effect=KeyboardInterrupt)
+    def test_interrupt_stops_cleanly(self, mock_run_forever, mock_client_cls, tmp_path):
+        config_file = tmp_path / "create_config.yaml"
+        config_file.write_text("measurements: []\n")
+        args = sintra.create_parser().parse_args(["schedule", "--cron", "0 * * * *", "--config", str(config_file)])
+
+        sintra.handle_schedule_command(args)
+
+        mock_run_forever.assert_called_once()
+        mock_client_cls.return_value.create_measurements.assert_not_called()
+
+    def test_invalid_cron_rejected(self):
+        args = sintra.create_parser().parse_args(["schedule", "--cron", "every hour"])
+
+        with pytest.raises(ValueError, match="expected 5 fields"):
+            sintra.handle_schedule_command(args)
+
+    def test_each_run_stops_expired_measurements_first(self):
+        client = MagicMock()
+        client.expired_measurements.return_value = [111, 222]
+        client.stop_measurement.side_effect = [True, requests.ConnectionError("down")]
+        client.create_measurements.return_value = {"created": []}
+
+        assert sintra.scheduled_create_run(client) == {"created": []}
+        assert [c.args[0] for c in client.stop_measurement.call_args_list] == [111, 222]
+        client.create_measurements.assert_called_once()
+
+    def test_each_run_skips_running_duplicates(self):
+        client = MagicMock(skip_duplicates=False)
+        client.expired_measurements.return_value = []
+
+        sintra.scheduled_create_run(client)
+
+        assert client.skip_duplicates is True
+        client.create_measurements.assert_called_once()
+
+    def test_force_not_accepted(self):
+        with pytest.raises(SystemExit):
+            sintra.create_parser().parse_args(["schedule", "--cron", "0 * * * *", "--force"])
+
+    def test_run_summary_line(self):
+        summary = {"created": [{"id": 1}], "failed": [], "timed_out": 0}
+        assert sintra.summarize_create_run(summary) == "1 created, 0 failed, 0 timed out"
@@ -0,0 +1,242 @@
+"""
+Unit tests for streaming result decoding and export.
+
+The large-response test compares peak traced allocation of the streaming
+path against decoding the whole body at once, so it doubles as a benchmark.
+"""
+import gzip
+import io
+import json
+import random
+import threading
+import time
+import tracemalloc
+import pytest
+from measurement_client.errors import CorruptBodyError
+from measurement_client.streaming import ReorderBuffer, gunzip_chunks, iter_json_array
+from measurement_client.exporters import RowWriter, SplitRowWriter, raw_result_row
+
+
+def chunked(data: bytes, size: int):
+    for i in range(0, len(data), size):
+        yield data[i:i + size]
+
+
+def ping_row(i: int):
+    return {
+        "prb_id": 1000 + i % 50,
+        "type": "ping",
+        "timestamp": 1700000000 + i,
+        "dst_addr": "8.8.8.8",
+        "sent": 3,
+        "rcvd": 3,
+        "result": [{"rtt": 10.5 + i % 7}, {"rtt": 11.0}, {"rtt": 12.25}]
+    }
+
+
+def synthetic_body(rows: int):
+    """Yield a results array of `rows` ping results without building it in memory."""
+    yield b"["
+    for i in range(rows):
+        yield (b"," if i else b"") + json.dumps(ping_row(i)).encode()
+    yield b"]"
+
+
+def rechunk(chunks, size: int):
+    buffer = b""
+    for chunk in chunks:
+        buffer += chunk
+        while len(buffer) >= size:
+            yield buffer[:size]
+            buffer = buffer[size:]
+    if buffer:
+        yield buffer
+
+
+# === Test: Incremental JSON array decoding ===
+
+class TestIterJsonArray:
+    @pytest.mark.parametrize("chunk_size", [1, 7, 64, 65536])
+    def test_rows_survive_any_chunking(self, chunk_size):
+        rows = [ping_row(i) for i in range(25)] + [{"n": 12345}, {"s": "a, ] [ é"}]
+        body = json.dumps(rows).encode()
+
+        assert list(iter_json_array(chunked(body, chunk_size))) == rows
+
+    def test_gzip_body_is_decompressed(self):
+        rows = [ping_row(i) for i in range(10)]
+        body = gzip.compress(json.dumps(rows).encode())
+
+        assert list(iter_json_array(chunked(body, 16))) == rows
+
+    @pytest.mark.parametrize("chunk_size", [1, 16, 65536])
+    def test_multi_member_and_double_gzip(self, chunk_size):
+        rows = [ping_row(i) for i in range(10)]
+        body = json.dumps(rows).encode()
+        multi_member = gzip.compress(body[:100]) + gzip.compress(body[100:])
+
+        assert list(iter_json_array(chunked(multi_member, chunk_size))) == rows
+        assert list(iter_json_array(chunked(gzip.compress(multi_member), chunk_size))) == rows
+
+    @pytest.mark.parametrize("damage", [lambda body: body + b"garbage", lambda body: body[:-12],
+                                        lambda body: body[:20] + bytes(20) + body[40:]])
+    def test_damaged_gzip_raises(self, damage):
+        body = gzip.compress(json.dumps([ping_row(i) for i in range(10)]).encode())
+
+        with pytest.raises(CorruptBodyError, match="Cannot decompress"):
+            b"".join(gunzip_chunks(chunked(damage(body), 16)))
+
+    def test_empty_array(self):
+        assert list(iter_json_array([b" [ ] "])) == []
+
+    def test_truncated_body_raises(self):
+        with pytest.raises(ValueError, match="not closed"):
+            list(iter_json_array([b'[{"a": 1}, {"b": 2}']))
+
+    def test_non_array_body_raises(self):
+        with pytest.raises(ValueError, match="Expected a JSON array"):
+            list(iter_json_array([b'{"error": "nope"}']))
+
+    def test_large_response_has_bounded_allocation(self):
+        """Streaming 40k rows should peak far below decoding the whole body."""
+        rows = 40000
+
+        tracemalloc.start()
+        count = 0
+        for _ in iter_json_array(rechunk(synthetic_body(rows), 65536)):
+            count += 1
+        _, streaming_peak = tracemalloc.get_traced_memory()
+        tracemalloc.stop()
+
+        body = b"".join(synthetic_body(rows))
+        tracemalloc.start()
+        decoded = json.loads(body)
+        _, buffered_peak = tracemalloc.get_traced_memory()
+        tracemalloc.stop()
+
+        assert count == len(decoded) == rows
+        assert streaming_peak < 2 * 1024 * 1024
+        assert streaming_peak * 10 < buffered_peak
+
+
+# === Test: Streaming export ===
+
+class TestRowWriter:
+    def test_json_output_is_a_valid_array(self):
+        out = io.StringIO()
+        writer = RowWriter("json", out)
+        for i in range(3):
+            writer.write(raw_result_row(ping_row(i), 42, "ping"))
+        writer.close()
+
+        rows = json.loads(out.getvalue())
+        assert [r["rtt_min"] for r in rows] == [10.5, 11.0, 11.0]
+        assert rows[0]["measurement_id"] == 42
+        assert rows[0]["packets_sent"] == 3
+
+    def test_empty_json_output(self):
+        out = io.StringIO()
+        writer = RowWriter("json", out)
+        writer.close()
+
+        assert json.loads(out.getvalue()) == []
+
+    def test_csv_joins_anomalies(self):
+        out = io.StringIO()
+        writer = RowWriter("csv", out, ["probe_id", "anomaly"])
+        writer.write({"probe_id": 1, "anomaly": ["latency_spike", "packet_loss"]})
+        writer.close()
+
+        assert out.getvalue().splitlines() == ["probe_id,anomaly", "1,latency_spike;packet_loss"]
+
+
+# === Test: Ordered output from concurrent sources ===
+
+class TestReorderBuffer:
+    def test_later_sources_held_until_earlier_finish(self):
+        emitted = []
+        ordered = ReorderBuffer(emitted.append)
+
+        ordered.put(2, "c1")
+        ordered.put(1, "b1")
+        ordered.put(0, "a1")
+        assert emitted == ["a1"]
+        ordered.finish(2)
+        ordered.put(1, "b2")
+        assert emitted == ["a1"]
+        ordered.finish(0)
+        assert emitted == ["a1", "b1", "b2"]
+        ordered.put(1, "b3")
+        ordered.finish(1)
+
+        assert emitted == ["a1", "b1", "b2", "b3", "c1"]
+        assert ordered.buffered == 0
+
+    def test_shuffled_completion_keeps_source_order(self):
+        emitted = []
+        ordered = ReorderBuffer(emitted.append, max_buffered=5)
+        rng = random.Random(7)
+        delays = [[rng.uniform(0, 0.003) for _ in range(10)] for _ in range(6)]
+        peak = []
+
+        def produce(index):
+            for n, delay in enumerate(delays[index]):
+                time.sleep(delay)
+                ordered.put(index, (index, n))
+                peak.append(ordered.buffered)
+            ordered.finish(index)
+
+        # Start in index order, as the fetch thread pool does, but finish in any order
+        threads = [threading.Thread(target=produce, args=(i,)) for i in range(6)]
+        for thread in threads:
+            thread.start()
+        for thread in threads:
+            thread.join(10)
+
+        assert emitted == [(index, n) for index in range(6) for n in range(10)]
+        assert max(peak) <= 5
+
+    def test_invalid_buffer_size(self):
+        with pytest.raises(ValueError):
+            ReorderBuffer(print, max_buffered=0)
+
+
+# === Test: Per-probe split export ===
+
+class TestSplitRowWriter:
+    @staticmethod
+    def read_jsonl(path):
+        return [json.loads(line) for line in path.read_text().splitlines()]
+
+    def test_rows_distributed_by_probe(self, tmp_path):
+        writer = SplitRowWriter(str(tmp_path / "archive"), max_open_files=2)
+        for i in range(12):
+            writer.write(raw_result_row(ping_row(i % 4), 42, "ping"))   # probes 1000-1003, interleaved
+        writer.write({"probe_id": None, "rtt_avg": 1.0})
+        writer.close()
+
+        files = sorted(p.name for p in (tmp_path / "archive").iterdir())
+        assert files == ["1000.jsonl", "1001.jsonl", "1002.jsonl", "1003.jsonl", "unknown.jsonl"]
+        for probe_id in range(1000, 1004):
+            rows = self.read_jsonl(tmp_path / "archive" / f"{probe_id}.jsonl")
+            assert len(rows) == 3
+            assert {row["probe_id"] for row in rows} == {probe_id}
+        assert writer.count == 13
+        assert len(writer.files) == 5
+
+    def test_handles_are_bounded(self, tmp_path):
+        writer = SplitRowWriter(str(tmp_path), max_open_files=3)
+        for probe_id in range(50):
+            writer.write({"probe_id": probe_id})
+            assert len(writer._handles) <= 3
+        writer.close()
+
+        assert len(list(tmp_path.iterdir())) == 50
+
+    def test_existing_files_are_appended(self, tmp_path):
+        for run in range(2):
+            writer = SplitRowWriter(str(tmp_path))
+            writer.write({"probe_id": 7, "run": run})
+            writer.close()
+
+        assert [row["run"] for row in self.read_jsonl(tmp_path / "7.jsonl")] == [0, 1]
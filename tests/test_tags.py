@@ -0,0 +1,82 @@
+"""
+Unit tests for measurement tag derivation.
+"""
+import io
+import yaml
+from measurement_client.client import SintraMeasurementClient
+from measurement_client.tags import slugify_tag, derive_tags, measurement_tags, target_tag, config_tag
+
+SAMPLE = {"type": "ping", "target": "example.com", "interval": 300, "af": 6}
+
+
+# === Test: Tag derivation ===
+
+class TestTags:
+    def test_derived_tag_set_for_sample_definition(self):
+        tags = derive_tags(SAMPLE, "ping", "example.com")
+
+        assert tags == ["sintra", "type-ping", target_tag("example.com"), "interval-300", "af-6"]
+        assert target_tag("example.com") == target_tag("example.com")
+        assert target_tag("example.com") != target_tag("example.org")
+
+    def test_oneoff_replaces_interval(self):
+        tags = derive_tags(dict(SAMPLE, is_oneoff=True), "ping", "example.com")
+
+        assert "oneoff" in tags
+        assert not any(tag.startswith("interval-") for tag in tags)
+
+    def test_tags_are_valid_slugs(self):
+        assert slugify_tag("  My Tag! (prod) ") == "my-tag-prod"
+        assert slugify_tag("already_ok-1") == "already_ok-1"
+
+    def test_user_tags_merged_without_duplicates(self):
+        tags = measurement_tags(dict(SAMPLE, tags=["Sintra", "Team A"]), "ping", "example.com")
+
+        assert tags[:2] == ["sintra", "team-a"]
+        assert tags.count("sintra") == 1
+
+    def test_auto_tags_disabled(self):
+        assert measurement_tags(dict(SAMPLE, tags=["team-a"]), "ping", "example.com", auto_tags=False) == ["team-a"]
+        assert measurement_tags(SAMPLE, "ping", "example.com", auto_tags=False) is None
+
+    def test_definition_carries_tags(self, client):
+        definition = client._create_measurement_object(SAMPLE, "ping", "example.com")
+        assert "type-ping" in definition["tags"]
+
+        client.auto_tags = False
+        definition = client._create_measurement_object(SAMPLE, "ping", "example.com")
+        assert "tags" not in definition
+
+
+# === Test: Config file tag ===
+
+class TestConfigTag:
+    def test_tag_from_config_basename(self):
+        assert config_tag("configs/Prod EU.yaml") == "cfg-prod-eu"
+        assert config_tag("create_config.yml") == "cfg-create_config"
+        assert config_tag(None) is None
+
+    def test_named_config_file_tagged(self, tmp_path, monkeypatch):
+        monkeypatch.setenv("RIPE_ATLAS_API_KEY", "test-key")
+        monkeypatch.chdir(tmp_path)
+        (tmp_path / "prod-eu.yaml").write_text(yaml.safe_dump({"measurements": []}))
+        client = SintraMeasurementClient(config_path="prod-eu.yaml")
+
+        definition = client._create_measurement_object(SAMPLE, "ping", "example.com")
+        assert "cfg-prod-eu" in definition["tags"]
+
+        client.tag_config_name = False
+        definition = client._create_measurement_object(SAMPLE, "ping", "example.com")
+        assert not any(tag.startswith("cfg-") for tag in definition["tags"])
+
+    def test_stdin_config_not_tagged(self, tmp_path, monkeypatch):
+        monkeypatch.setenv("RIPE_ATLAS_API_KEY", "test-key")
+        monkeypatch.chdir(tmp_path)
+        config = {"measurements": [{"type": "ping", "target": "example.com"}]}
+        monkeypatch.setattr("sys.stdin", io.StringIO(yaml.safe_dump(config)))
+        client = SintraMeasurementClient(config_path="-")
+        client.load_config("create")
+
+        definition = client._create_measurement_object(SAMPLE, "ping", "example.com")
+        assert client.create_config["measurements"][0]["target"] == "example.com"
+        assert not any(tag.startswith("cfg-") for tag in definition["tags"])
@@ -0,0 +1,72 @@
+"""
+Unit tests for the dashboard's sparklines, latest-result summaries and shared state.
+"""
+from datetime import datetime, timezone
+import pytest
+from measurement_client.tui import SPARK_CHARS, DashboardState, sparkline, summarize_latest
+
+
+def ping(probe_id, avg, rcvd=3):
+    return {"type": "ping", "prb_id": probe_id, "timestamp": 1700000000, "from": "192.0.2.1", "avg": avg,
+            "sent": 3, "rcvd": rcvd, "result": [{"rtt": avg}] * rcvd + [{"x": "*"}] * (3 - rcvd)}
+
+
+# === Test: Sparklines ===
+
+class TestSparkline:
+    def test_scaled_between_lowest_and_highest(self):
+        assert sparkline([10.0, 20.0, 30.0]) == SPARK_CHARS[0] + SPARK_CHARS[3] + SPARK_CHARS[-1]
+
+    def test_missing_samples_left_blank(self):
+        assert sparkline([1.0, None, 2.0]) == SPARK_CHARS[0] + " " + SPARK_CHARS[-1]
+        assert sparkline([None, None]) == "  "
+
+    def test_flat_series_drawn_mid_height(self):
+        assert sparkline([5.0, 5.0]) == SPARK_CHARS[len(SPARK_CHARS) // 2] * 2
+
+    def test_only_last_width_values(self):
+        assert sparkline([100.0] + [1.0, 2.0], width=2) == SPARK_CHARS[0] + SPARK_CHARS[-1]
+        assert sparkline([]) == ""
+
+
+# === Test: Latest-result summaries ===
+
+class TestSummarizeLatest:
+    def test_per_probe_and_overall(self):
+        summary = summarize_latest([ping(1, 10.0), ping(2, 30.0, rcvd=2)])
+
+        assert summary["probes"][1] == {"probe_id": 1, "timestamp": 1700000000, "rtt_avg": 10.0, "loss": 0.0,
+                                        "source_address": "192.0.2.1"}
+        assert summary["probes"][2]["loss"] == pytest.approx(100 / 3)
+        assert summary["rtt_avg"] == 20.0
+        assert summary["loss"] == pytest.approx(50 / 3)
+
+    def test_rows_without_probe_skipped(self):
+        summary = summarize_latest([{"type": "ping", "timestamp": 1700000000}])
+
+        assert summary == {"probes": {}, "rtt_avg": None, "loss": None}
+
+    def test_latest_row_per_probe_wins(self):
+        summary = summarize_latest([ping(1, 10.0), ping(1, 50.0)])
+
+        assert list(summary["probes"]) == [1]
+        assert summary["rtt_avg"] == 50.0
+
+
+# === Test: Dashboard state ===
+
+class TestDashboardState:
+    def test_snapshot_holds_history_and_last_poll(self):
+        state = DashboardState([1001], history_size=2)
+        polled = datetime(2025, 1, 1, tzinfo=timezone.utc)
+
+        state.update(1001, {"rtt_avg": 10.0, "loss": 0.0})
+        state.record_error(1001, "timeout")
+        state.update(1001, {"rtt_avg": 12.0, "loss": 50.0})
+        state.mark_polled(polled)
+        snapshot = state.snapshot()
+
+        assert snapshot["rtt_history"] == {1001: [None, 12.0]}
+        assert snapshot["loss_history"] == {1001: [None, 50.0]}
+        assert snapshot["errors"] == {}
+        assert snapshot["last_poll"] == polled